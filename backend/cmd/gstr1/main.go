@@ -0,0 +1,78 @@
+// Command gstr1 exports a seller's GSTR-1 return for a month or quarter as
+// a JSON file for the GST offline tool.
+//
+// Usage:
+//
+//	go run ./cmd/gstr1 -seller accounts@example.com -period 2025-04
+//	go run ./cmd/gstr1 -seller accounts@example.com -period 2025-26-Q1 -out q1.json
+//
+// Documents left out of the return because of missing or invalid fields
+// are listed on stderr, and the command then exits with status 1.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"invoice-generator/internal/config"
+	"invoice-generator/internal/database"
+	"invoice-generator/internal/gst"
+	"invoice-generator/internal/models"
+	"invoice-generator/internal/services"
+)
+
+func main() {
+	sellerEmail := flag.String("seller", "", "email of the seller whose return to export")
+	periodFlag := flag.String("period", "", "return period: YYYY-MM for a month or YYYY-YY-Qn for a quarter")
+	out := flag.String("out", "", "output file (default GSTR1_<gstin>_<MMYYYY>.json)")
+	flag.Parse()
+
+	if *sellerEmail == "" || *periodFlag == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	period, err := gst.ParseReturnPeriod(*periodFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cfg := config.Load()
+	cfg.ServerMode = "release" // Keep SQL logging out of the output
+	if err := database.Initialize(cfg); err != nil {
+		log.Fatal("Failed to initialize database:", err)
+	}
+
+	var seller models.User
+	if err := database.GetDB().Where("email = ?", *sellerEmail).First(&seller).Error; err != nil {
+		log.Fatalf("Seller %s not found", *sellerEmail)
+	}
+
+	gstr1, returnErrors, err := services.NewGSTR1Service().GenerateGSTR1(seller.ID, period)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	path := *out
+	if path == "" {
+		path = fmt.Sprintf("GSTR1_%s_%s.json", gstr1.GSTIN, gstr1.Period)
+	}
+	data, err := json.MarshalIndent(gstr1, "", "  ")
+	if err != nil {
+		log.Fatal("Failed to encode return:", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		log.Fatal("Failed to write return:", err)
+	}
+	fmt.Printf("GSTR-1 for %s written to %s\n", period.Label, path)
+
+	if len(returnErrors) > 0 {
+		fmt.Fprintf(os.Stderr, "%d problem(s) left documents out of the return:\n", len(returnErrors))
+		for _, e := range returnErrors {
+			fmt.Fprintf(os.Stderr, "  %s %s: %s %s\n", e.DocumentType, e.DocumentNumber, e.Field, e.Message)
+		}
+		os.Exit(1)
+	}
+}
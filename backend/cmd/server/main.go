@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"log"
 
 	"invoice-generator/internal/config"
 	"invoice-generator/internal/database"
+	"invoice-generator/internal/einvoice"
 	"invoice-generator/internal/handlers"
 	"invoice-generator/internal/routes"
 	"invoice-generator/internal/services"
@@ -25,6 +27,25 @@ func main() {
 	invoiceService := services.NewInvoiceService()
 	dashboardService := services.NewDashboardService()
 	catalogService := services.NewCatalogService()
+	pdfService := services.NewPDFService()
+	numberingService := services.NewNumberingService()
+	noteService := services.NewAdjustmentNoteService()
+	revisionService := services.NewInvoiceRevisionService()
+	recurringService := services.NewRecurringInvoiceService(invoiceService)
+	quotationService := services.NewQuotationService(invoiceService)
+	partyService := services.NewPartyService()
+	gstr1Service := services.NewGSTR1Service()
+	reportService := services.NewReportService()
+	// E-invoices are registered through the local IRP stub; swap in an
+	// einvoice.Client for your GSP to report them to the IRP
+	einvoiceService := services.NewEInvoiceService(invoiceService, einvoice.NewStubClient([]byte(cfg.IRPStubKey)))
+	ewayBillService := services.NewEWayBillService(invoiceService)
+	paymentService := services.NewPaymentService(invoiceService, noteService)
+	creditService := services.NewCreditService(invoiceService, partyService)
+	receiptService := services.NewReceiptService(invoiceService)
+	bankService := services.NewBankReconciliationService(invoiceService)
+	upiService := services.NewUPIService(invoiceService)
+	idempotencyService := services.NewIdempotencyService(cfg.IdempotencyTTL)
 
 	// Initialize handlers
 	h := handlers.NewHandlers(
@@ -32,10 +53,32 @@ func main() {
 		invoiceService,
 		dashboardService,
 		catalogService,
+		pdfService,
+		numberingService,
+		noteService,
+		revisionService,
+		recurringService,
+		quotationService,
+		partyService,
+		gstr1Service,
+		reportService,
+		einvoiceService,
+		ewayBillService,
+		paymentService,
+		creditService,
+		receiptService,
+		bankService,
+		upiService,
 	)
 
+	// Start background jobs
+	if cfg.SchedulerInterval > 0 {
+		recurringService.StartScheduler(context.Background(), cfg.SchedulerInterval)
+		log.Printf("Recurring invoice scheduler running every %s", cfg.SchedulerInterval)
+	}
+
 	// Setup routes
-	r := routes.SetupRoutes(cfg, h, jwtSecret)
+	r := routes.SetupRoutes(cfg, h, jwtSecret, idempotencyService)
 
 	// Start server
 	port := ":" + cfg.ServerPort
@@ -0,0 +1,81 @@
+// Package bankstatement reads bank statements exported as CSV, OFX or
+// MT940 into a common list of transactions for reconciliation.
+package bankstatement
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"invoice-generator/internal/constants"
+	"invoice-generator/internal/money"
+)
+
+// MaxFileSize is the largest statement file accepted
+const MaxFileSize = 10 << 20
+
+// Statement is the account and transactions read from a statement file
+type Statement struct {
+	AccountNumber string
+	Transactions  []Transaction
+}
+
+// Transaction is one line of a statement. Credits (money received) have
+// positive amounts and debits negative ones.
+type Transaction struct {
+	Date          time.Time
+	Amount        money.Amount
+	Reference     string // Cheque number, UTR or the customer's reference
+	BankReference string // The bank's own ID for the transaction, if any
+	Description   string // Narration
+}
+
+// Parse reads a statement in the given format. CSV files need a column
+// mapping; it is ignored for the other formats.
+func Parse(format string, r io.Reader, mapping *CSVMapping) (*Statement, error) {
+	switch strings.ToUpper(format) {
+	case constants.StatementFormatCSV:
+		if mapping == nil {
+			return nil, errors.New("a column mapping is required for CSV statements")
+		}
+		return ParseCSV(r, *mapping)
+	case constants.StatementFormatOFX:
+		return ParseOFX(r)
+	case constants.StatementFormatMT940:
+		return ParseMT940(r)
+	}
+	return nil, fmt.Errorf("format must be one of %s", strings.Join(constants.ValidStatementFormats, ", "))
+}
+
+// parseAmount parses an amount as banks print it: with thousands
+// separators, an optional currency, a trailing Cr or Dr, or in brackets
+// when negative
+func parseAmount(s string) (money.Amount, error) {
+	value := strings.TrimSpace(s)
+	value = strings.TrimPrefix(strings.TrimPrefix(value, "INR"), "₹")
+	value = strings.NewReplacer(",", "", " ", "").Replace(value)
+
+	negative := false
+	if strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")") {
+		negative = true
+		value = value[1 : len(value)-1]
+	}
+	switch upper := strings.ToUpper(value); {
+	case strings.HasSuffix(upper, "DR"):
+		negative = true
+		value = value[:len(value)-2]
+	case strings.HasSuffix(upper, "CR"):
+		value = value[:len(value)-2]
+	}
+
+	amount, err := money.ParseAmount(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
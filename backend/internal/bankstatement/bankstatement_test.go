@@ -0,0 +1,101 @@
+package bankstatement
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"invoice-generator/internal/money"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		in   string
+		want money.Amount
+	}{
+		{"1500", 150000},
+		{"1500.5", 150050},
+		{"-20.00", -2000},
+		{"1,18,000.50", 11800050},
+		{"12,34,56,789.00", 123456789_00},
+		{"1,234,567.89", 123456789},
+		{"₹ 2,500.00", 250000},
+		{"INR 1,00,000", 10000000},
+		{"2,500.00 Cr", 250000},
+		{"2,500.00CR", 250000},
+		{"5.90 Dr", -590},
+		{"5.90DR", -590},
+		{"(75,000.00)", -7500000},
+		{" 1 000.00 ", 100000},
+	}
+
+	for _, tt := range tests {
+		got, err := parseAmount(tt.in)
+		if err != nil {
+			t.Errorf("parseAmount(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseAmount(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "Cr", "abc", "1.2.3", "12-50"} {
+		if _, err := parseAmount(in); err == nil {
+			t.Errorf("parseAmount(%q) succeeded, want an error", in)
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if _, err := Parse("xls", strings.NewReader(""), nil); err == nil {
+		t.Error("Parse accepted an unknown format")
+	}
+	if _, err := Parse("csv", strings.NewReader(readFixture(t, "hdfc.csv")), nil); err == nil {
+		t.Error("Parse accepted a CSV statement without a column mapping")
+	}
+
+	statement, err := Parse("ofx", strings.NewReader(readFixture(t, "sbi.ofx")), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statement.Transactions) != 3 {
+		t.Errorf("got %d transactions, want 3", len(statement.Transactions))
+	}
+}
+
+// readFixture returns the contents of a statement in testdata
+func readFixture(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// date returns midnight UTC on a day, as statement dates are parsed
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// checkStatement compares a parsed statement with the expected account
+// number and transactions
+func checkStatement(t *testing.T, got *Statement, account string, want []Transaction) {
+	t.Helper()
+	if got.AccountNumber != account {
+		t.Errorf("account number = %q, want %q", got.AccountNumber, account)
+	}
+	if len(got.Transactions) != len(want) {
+		t.Fatalf("got %d transactions, want %d:\n%+v", len(got.Transactions), len(want), got.Transactions)
+	}
+	for i, w := range want {
+		g := got.Transactions[i]
+		if !g.Date.Equal(w.Date) || g.Amount != w.Amount || g.Reference != w.Reference ||
+			g.BankReference != w.BankReference || g.Description != w.Description {
+			t.Errorf("transaction %d:\n got %+v\nwant %+v", i, g, w)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package bankstatement
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"invoice-generator/internal/money"
+)
+
+// CSVMapping names the columns of a bank's CSV export. Columns are matched
+// to the header row by name, ignoring case. Amounts are read either from a
+// single signed Amount column or from separate Credit and Debit columns.
+type CSVMapping struct {
+	Date        string `json:"date"`
+	Amount      string `json:"amount"`
+	Credit      string `json:"credit"`
+	Debit       string `json:"debit"`
+	Reference   string `json:"reference"`
+	Description string `json:"description"`
+	DateFormat  string `json:"date_format"` // e.g. "DD/MM/YYYY"; common formats are tried if empty
+	SkipRows    int    `json:"skip_rows"`   // Lines before the header row, such as the account summary
+}
+
+// defaultDateLayouts are tried in turn when a mapping has no date format
+var defaultDateLayouts = []string{
+	"02/01/2006", "02-01-2006", "02.01.2006", "02/01/06", "02-01-06",
+	"2006-01-02", "02-Jan-2006", "02 Jan 2006", "02-Jan-06", "02 Jan 06",
+}
+
+// dateFormatTokens turns a date format such as "DD/MM/YYYY" into a Go
+// time layout
+var dateFormatTokens = strings.NewReplacer("YYYY", "2006", "YY", "06", "MMM", "Jan", "MM", "01", "DD", "02")
+
+// ParseCSV reads a CSV statement using the given column mapping. Rows
+// without a date, such as totals and separators, are skipped.
+func ParseCSV(r io.Reader, mapping CSVMapping) (*Statement, error) {
+	if mapping.Date == "" {
+		return nil, errors.New("mapping must name the date column")
+	}
+	if (mapping.Amount == "") == (mapping.Credit == "" && mapping.Debit == "") {
+		return nil, errors.New("mapping must name either the amount column or the credit and debit columns")
+	}
+	if mapping.SkipRows < 0 {
+		return nil, errors.New("skip_rows cannot be negative")
+	}
+	layouts := defaultDateLayouts
+	if mapping.DateFormat != "" {
+		layouts = []string{dateFormatTokens.Replace(mapping.DateFormat)}
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+
+	for i := 0; i < mapping.SkipRows; i++ {
+		if _, err := reader.Read(); err != nil {
+			return nil, errors.New("file has fewer rows than skip_rows")
+		}
+	}
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("file has no header row")
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		key := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if _, exists := columns[key]; !exists {
+			columns[key] = i
+		}
+	}
+	column := func(name string) (int, error) {
+		if name == "" {
+			return -1, nil
+		}
+		if i, ok := columns[strings.ToLower(strings.TrimSpace(name))]; ok {
+			return i, nil
+		}
+		return -1, fmt.Errorf("column %q not found in the header row", name)
+	}
+
+	var indexes [6]int
+	for i, name := range []string{mapping.Date, mapping.Amount, mapping.Credit, mapping.Debit, mapping.Reference, mapping.Description} {
+		if indexes[i], err = column(name); err != nil {
+			return nil, err
+		}
+	}
+	dateCol, amountCol, creditCol, debitCol, referenceCol, descriptionCol := indexes[0], indexes[1], indexes[2], indexes[3], indexes[4], indexes[5]
+
+	statement := &Statement{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		cell := func(i int) string {
+			if i < 0 || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		if cell(dateCol) == "" {
+			continue
+		}
+		date, err := parseDate(cell(dateCol), layouts)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		var amount money.Amount
+		if amountCol >= 0 {
+			if cell(amountCol) == "" {
+				continue
+			}
+			if amount, err = parseAmount(cell(amountCol)); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+		} else {
+			credit, debit := cell(creditCol), cell(debitCol)
+			if credit == "" && debit == "" {
+				continue
+			}
+			if credit != "" {
+				value, err := parseAmount(credit)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", line, err)
+				}
+				amount += value.Abs()
+			}
+			if debit != "" {
+				value, err := parseAmount(debit)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", line, err)
+				}
+				amount -= value.Abs()
+			}
+		}
+		if amount.IsZero() {
+			continue
+		}
+
+		statement.Transactions = append(statement.Transactions, Transaction{
+			Date:        date,
+			Amount:      amount,
+			Reference:   cell(referenceCol),
+			Description: cell(descriptionCol),
+		})
+	}
+
+	return statement, nil
+}
+
+// parseDate parses a statement date with the first layout that fits,
+// ignoring any time of day after the date
+func parseDate(value string, layouts []string) (time.Time, error) {
+	for _, layout := range layouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, nil
+		}
+		if !strings.Contains(layout, " ") {
+			if first, _, found := strings.Cut(value, " "); found {
+				if date, err := time.Parse(layout, first); err == nil {
+					return date, nil
+				}
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
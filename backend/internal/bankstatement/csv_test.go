@@ -0,0 +1,104 @@
+package bankstatement
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCSVCreditDebitColumns(t *testing.T) {
+	mapping := CSVMapping{
+		Date:        "Date",
+		Credit:      "Deposit Amt.",
+		Debit:       "Withdrawal Amt.",
+		Reference:   "chq./ref.no.",
+		Description: "NARRATION",
+		DateFormat:  "DD/MM/YY",
+		SkipRows:    2,
+	}
+	statement, err := ParseCSV(strings.NewReader(readFixture(t, "hdfc.csv")), mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkStatement(t, statement, "", []Transaction{
+		{Date: date(2025, 4, 5), Amount: 11800050, Reference: "UTR12345678", Description: "NEFT CR-UTIB0000123-ACME TRADERS-INV 25-26 00012"},
+		{Date: date(2025, 4, 6), Amount: 123456700, Reference: "000123", Description: "CHQ DEP - 000123 - KOTAK"},
+		{Date: date(2025, 4, 7), Amount: -50000, Description: "ATM WDL-SBI MUMBAI"},
+	})
+}
+
+func TestParseCSVSignedAmount(t *testing.T) {
+	mapping := CSVMapping{
+		Date:        "Transaction Date",
+		Amount:      "Amount (INR)",
+		Reference:   "Cheque Number",
+		Description: "Transaction Remarks",
+	}
+	// Prefix a byte order mark, as Excel does when saving CSV as UTF-8
+	statement, err := ParseCSV(strings.NewReader("\ufeff"+readFixture(t, "icici.csv")), mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkStatement(t, statement, "", []Transaction{
+		{Date: date(2025, 4, 5), Amount: 250000, Description: "UPI/512345678901/ACME TRADERS"},
+		{Date: date(2025, 4, 6), Amount: 10000000, Description: "NEFT/N096250123/SHARMA AND SONS"},
+		{Date: date(2025, 4, 7), Amount: -590, Description: "IMPS CHARGES"},
+		{Date: date(2025, 4, 8), Amount: -7500000, Reference: "000456", Description: "CHQ RETURN 000456"},
+	})
+}
+
+func TestParseCSVErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		mapping CSVMapping
+		want    string
+	}{
+		{
+			name:    "no date column",
+			data:    "Date,Amount\n",
+			mapping: CSVMapping{Amount: "Amount"},
+			want:    "date column",
+		},
+		{
+			name:    "amount and credit columns",
+			data:    "Date,Amount,Credit\n",
+			mapping: CSVMapping{Date: "Date", Amount: "Amount", Credit: "Credit"},
+			want:    "either the amount column",
+		},
+		{
+			name:    "missing column",
+			data:    "Date,Amount\n",
+			mapping: CSVMapping{Date: "Date", Amount: "Value"},
+			want:    `column "Value" not found`,
+		},
+		{
+			name:    "invalid date",
+			data:    "Date,Amount\n31/02/2025,100\n",
+			mapping: CSVMapping{Date: "Date", Amount: "Amount", DateFormat: "DD/MM/YYYY"},
+			want:    "line 2: invalid date",
+		},
+		{
+			name:    "invalid amount",
+			data:    "Date,Amount\n01/04/2025,100\n02/04/2025,1O0\n",
+			mapping: CSVMapping{Date: "Date", Amount: "Amount"},
+			want:    "line 3: invalid amount",
+		},
+		{
+			name:    "too few rows",
+			data:    "Date,Amount\n",
+			mapping: CSVMapping{Date: "Date", Amount: "Amount", SkipRows: 3},
+			want:    "fewer rows than skip_rows",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseCSV(strings.NewReader(tt.data), tt.mapping)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
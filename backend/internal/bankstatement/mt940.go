@@ -0,0 +1,124 @@
+package bankstatement
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"invoice-generator/internal/money"
+)
+
+// mt940Field matches the first line of a field, such as ":61:2504050405C1500,00NTRFUTR123//HDFC0042"
+var mt940Field = regexp.MustCompile(`^:(\d{2}[A-Z]?):(.*)$`)
+
+// mt940Line splits a :61: statement line into its value date, optional
+// entry date, debit/credit mark, optional funds code, amount, transaction
+// type and references
+var mt940Line = regexp.MustCompile(`^(\d{6})(\d{4})?(RC|RD|C|D)([A-Z])?(\d+,\d{0,2})([A-Z][A-Z0-9]{3})(.*)$`)
+
+// ParseMT940 reads a SWIFT MT940 customer statement. Each :61: statement
+// line becomes a transaction, with the following :86: field as its
+// description.
+func ParseMT940(r io.Reader) (*Statement, error) {
+	statement := &Statement{}
+	var tag string
+	var value strings.Builder
+	var current *Transaction
+
+	flush := func() error {
+		defer value.Reset()
+		switch tag {
+		case "25":
+			statement.AccountNumber = strings.TrimSpace(value.String())
+		case "61":
+			transaction, err := mt940Transaction(value.String())
+			if err != nil {
+				return err
+			}
+			statement.Transactions = append(statement.Transactions, transaction)
+			current = &statement.Transactions[len(statement.Transactions)-1]
+		case "86":
+			if current != nil {
+				// Narrative lines are wrapped at a fixed width, so they are
+				// joined without spaces
+				narrative := strings.ReplaceAll(value.String(), "\n", "")
+				current.Description = strings.TrimSpace(current.Description + " " + narrative)
+			}
+		default:
+			current = nil
+		}
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		// Skip the SWIFT message header and trailer
+		if strings.HasPrefix(line, "{") || line == "-" || strings.HasPrefix(line, "-}") {
+			continue
+		}
+		if match := mt940Field.FindStringSubmatch(line); match != nil {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			tag = match[1]
+			value.WriteString(match[2])
+			continue
+		}
+		if tag != "" {
+			value.WriteString("\n" + line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	if statement.AccountNumber == "" && len(statement.Transactions) == 0 {
+		return nil, errors.New("file is not an MT940 statement")
+	}
+	return statement, nil
+}
+
+// mt940Transaction parses the value of a :61: field. A second line, if
+// present, holds supplementary details and is kept as the description.
+func mt940Transaction(value string) (Transaction, error) {
+	first, details, _ := strings.Cut(value, "\n")
+	match := mt940Line.FindStringSubmatch(strings.TrimSpace(first))
+	if match == nil {
+		return Transaction{}, fmt.Errorf("invalid statement line %q", first)
+	}
+
+	date, err := time.Parse("060102", match[1])
+	if err != nil {
+		return Transaction{}, fmt.Errorf("invalid date in statement line %q", first)
+	}
+	amount, err := money.ParseAmount(strings.Replace(match[5], ",", ".", 1))
+	if err != nil {
+		return Transaction{}, fmt.Errorf("invalid amount in statement line %q", first)
+	}
+	// RC reverses a credit and RD a debit
+	if match[3] == "D" || match[3] == "RC" {
+		amount = -amount
+	}
+
+	reference, bankReference, _ := strings.Cut(match[7], "//")
+	reference = strings.TrimSpace(reference)
+	if strings.EqualFold(reference, "NONREF") {
+		reference = ""
+	}
+
+	return Transaction{
+		Date:          date,
+		Amount:        amount,
+		Reference:     reference,
+		BankReference: strings.TrimSpace(bankReference),
+		Description:   strings.TrimSpace(details),
+	}, nil
+}
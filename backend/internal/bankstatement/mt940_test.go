@@ -0,0 +1,55 @@
+package bankstatement
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMT940(t *testing.T) {
+	want := []Transaction{
+		{
+			Date:          date(2025, 4, 5),
+			Amount:        11800050,
+			Reference:     "UTR12345678",
+			BankReference: "HDFC0001",
+			// The :86: narrative continues on a second line, wrapped
+			// mid-word, after the :61: supplementary details
+			Description: "NEFT CR ACME TRADERS NEFT CR-UTIB0000123-ACME TRADERS-INV 25-26 00012",
+		},
+		{Date: date(2025, 4, 6), Amount: -50000, Description: "ATM WDL SBI MUMBAI"},
+		{Date: date(2025, 4, 7), Amount: -11800050, Reference: "UTR12345678", BankReference: "HDFC0002", Description: "NEFT RETURN ACCOUNT CLOSED"},
+		{Date: date(2025, 4, 8), Amount: 50000, BankReference: "HDFC0003"},
+	}
+
+	data := readFixture(t, "hdfc.sta")
+	for name, data := range map[string]string{"LF": data, "CRLF": strings.ReplaceAll(data, "\n", "\r\n")} {
+		t.Run(name, func(t *testing.T) {
+			statement, err := ParseMT940(strings.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkStatement(t, statement, "50100012345678", want)
+		})
+	}
+}
+
+func TestParseMT940Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"not MT940", "Date,Amount\n01/04/2025,100\n", "not an MT940 statement"},
+		{"invalid statement line", ":25:123\n:61:2504051500,00NTRF\n", "invalid statement line"},
+		{"invalid date", ":25:123\n:61:251305C1500,00NTRFNONREF\n", "invalid date"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseMT940(strings.NewReader(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package bankstatement
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ofxTag matches an OFX tag and the text after it. OFX 1 files are SGML
+// and leave elements unclosed, so values run to the next tag.
+var ofxTag = regexp.MustCompile(`<(/?)([A-Za-z0-9.]+)>([^<]*)`)
+
+// ParseOFX reads an OFX statement, either SGML (OFX 1) or XML (OFX 2)
+func ParseOFX(r io.Reader) (*Statement, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	statement := &Statement{}
+	var fields map[string]string
+	for _, match := range ofxTag.FindAllStringSubmatch(string(data), -1) {
+		closing, tag, value := match[1] == "/", strings.ToUpper(match[2]), html.UnescapeString(strings.TrimSpace(match[3]))
+
+		switch {
+		case tag == "STMTTRN" && !closing:
+			fields = map[string]string{}
+		case tag == "STMTTRN" && closing:
+			if fields == nil {
+				continue
+			}
+			transaction, err := ofxTransaction(fields)
+			if err != nil {
+				return nil, err
+			}
+			statement.Transactions = append(statement.Transactions, transaction)
+			fields = nil
+		case closing:
+		case tag == "ACCTID" && statement.AccountNumber == "":
+			statement.AccountNumber = value
+		case fields != nil:
+			fields[tag] = value
+		}
+	}
+
+	if statement.AccountNumber == "" && len(statement.Transactions) == 0 {
+		return nil, errors.New("file is not an OFX statement")
+	}
+	return statement, nil
+}
+
+// ofxTransaction builds a transaction from the elements of a STMTTRN
+// aggregate
+func ofxTransaction(fields map[string]string) (Transaction, error) {
+	posted := fields["DTPOSTED"]
+	if len(posted) < 8 {
+		return Transaction{}, fmt.Errorf("transaction %s: invalid date %q", fields["FITID"], posted)
+	}
+	date, err := time.Parse("20060102", posted[:8])
+	if err != nil {
+		return Transaction{}, fmt.Errorf("transaction %s: invalid date %q", fields["FITID"], posted)
+	}
+	amount, err := parseAmount(fields["TRNAMT"])
+	if err != nil {
+		return Transaction{}, fmt.Errorf("transaction %s: %w", fields["FITID"], err)
+	}
+
+	reference := fields["CHECKNUM"]
+	if reference == "" {
+		reference = fields["REFNUM"]
+	}
+	description := strings.TrimSpace(fields["NAME"] + " " + fields["MEMO"])
+
+	return Transaction{
+		Date:          date,
+		Amount:        amount,
+		Reference:     reference,
+		BankReference: fields["FITID"],
+		Description:   description,
+	}, nil
+}
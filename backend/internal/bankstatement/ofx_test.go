@@ -0,0 +1,53 @@
+package bankstatement
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseOFXSGML(t *testing.T) {
+	// OFX 1 leaves elements such as <TRNAMT> unclosed
+	statement, err := ParseOFX(strings.NewReader(readFixture(t, "sbi.ofx")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkStatement(t, statement, "30012345678", []Transaction{
+		{Date: date(2025, 4, 5), Amount: 11800050, Reference: "000123", BankReference: "SBI2504050001", Description: "ACME TRADERS & CO CHQ DEP"},
+		{Date: date(2025, 4, 6), Amount: 2500000, Reference: "UTR98765432", BankReference: "SBI2504060007", Description: "SHARMA AND SONS"},
+		{Date: date(2025, 4, 7), Amount: -1770, BankReference: "SBI2504070003", Description: "SMS CHARGES"},
+	})
+}
+
+func TestParseOFXXML(t *testing.T) {
+	statement, err := ParseOFX(strings.NewReader(readFixture(t, "axis.ofx")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkStatement(t, statement, "917020012345678", []Transaction{
+		{Date: date(2025, 4, 8), Amount: 9999, Reference: "UTR11112222", BankReference: "AX0001", Description: "GUPTA & GUPTA"},
+		{Date: date(2025, 4, 9), Amount: -150000, BankReference: "AX0002", Description: "RTGS TO SUPPLIER RENT APRIL"},
+	})
+}
+
+func TestParseOFXErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"not OFX", "Date,Amount\n01/04/2025,100\n", "not an OFX statement"},
+		{"invalid date", "<STMTTRN><DTPOSTED>2025<TRNAMT>1<FITID>F1</STMTTRN>", `transaction F1: invalid date "2025"`},
+		{"invalid amount", "<STMTTRN><DTPOSTED>20250401<TRNAMT>ten<FITID>F2</STMTTRN>", `transaction F2: invalid amount "ten"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseOFX(strings.NewReader(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
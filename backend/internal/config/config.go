@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -19,6 +20,18 @@ type Config struct {
 	ServerPort  string
 	ServerMode  string
 	CORSOrigins []string
+
+	// SchedulerInterval is how often the recurring invoice scheduler checks
+	// for due schedules; zero disables the scheduler in this process
+	SchedulerInterval time.Duration
+
+	// IdempotencyTTL is how long responses to requests made with an
+	// Idempotency-Key header are kept for replay
+	IdempotencyTTL time.Duration
+
+	// IRPStubKey is the key the local IRP stub signs e-invoice QR codes
+	// with
+	IRPStubKey string
 }
 
 // Load loads configuration from environment variables
@@ -37,6 +50,10 @@ func Load() *Config {
 		ServerPort:  getEnv("SERVER_PORT", "8080"),
 		ServerMode:  getEnv("SERVER_MODE", "debug"),
 		CORSOrigins: strings.Split(getEnv("CORS_ORIGINS", "http://localhost:3000,http://localhost:5173"), ","),
+
+		SchedulerInterval: getDurationEnv("SCHEDULER_INTERVAL", 15*time.Minute),
+		IdempotencyTTL:    getDurationEnv("IDEMPOTENCY_TTL", 24*time.Hour),
+		IRPStubKey:        getEnv("IRP_STUB_KEY", "irp-stub-signing-key"),
 	}
 }
 
@@ -47,3 +64,20 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getDurationEnv parses a duration such as "15m" from an environment
+// variable, falling back to the default when unset or invalid
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	if value == "0" {
+		return 0
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return defaultValue
+	}
+	return d
+}
@@ -1,5 +1,7 @@
 package constants
 
+import "time"
+
 // Invoice Types
 const (
 	InvoiceTypeCash   = "CASH"
@@ -7,6 +9,116 @@ const (
 	InvoiceTypeDebit  = "DEBIT"
 )
 
+// Adjustment Note Types
+const (
+	NoteTypeCredit = "CREDIT_NOTE"
+	NoteTypeDebit  = "DEBIT_NOTE"
+)
+
+// Document Types used for number series
+const (
+	DocumentTypeInvoice    = "INVOICE"
+	DocumentTypeCreditNote = NoteTypeCredit
+	DocumentTypeDebitNote  = NoteTypeDebit
+	DocumentTypeQuotation  = "QUOTATION"
+)
+
+// Number Series
+const (
+	DefaultInvoiceSeriesName    = "INV"
+	DefaultCreditNoteSeriesName = "CN"
+	DefaultDebitNoteSeriesName  = "DN"
+	DefaultQuotationSeriesName  = "QT"
+	DefaultNumberSeriesPattern  = "{SERIES}/{FY_SHORT}/{SEQ:5}"
+	MaxDocumentNumberLength     = 16
+	FinancialYearStartMonth     = 4 // April
+)
+
+// Invoice Status
+const (
+	InvoiceStatusDraft     = "DRAFT"
+	InvoiceStatusIssued    = "ISSUED"
+	InvoiceStatusVoid      = "VOID"
+	InvoiceStatusCancelled = "CANCELLED"
+)
+
+// Quotation Status
+const (
+	QuotationStatusOpen      = "OPEN"
+	QuotationStatusAccepted  = "ACCEPTED"
+	QuotationStatusRejected  = "REJECTED"
+	QuotationStatusExpired   = "EXPIRED"
+	QuotationStatusConverted = "CONVERTED" // Every line fully invoiced
+)
+
+// Quotations
+const (
+	DefaultQuotationValidityDays = 30
+)
+
+// Invoice Revision Actions
+const (
+	RevisionActionCreated   = "CREATED"
+	RevisionActionUpdated   = "UPDATED"
+	RevisionActionIssued    = "ISSUED"
+	RevisionActionVoided    = "VOIDED"
+	RevisionActionCancelled = "CANCELLED"
+	RevisionActionTransport = "TRANSPORT_UPDATED"
+)
+
+// Recurring Invoice Frequencies
+const (
+	FrequencyMonthly   = "MONTHLY"
+	FrequencyQuarterly = "QUARTERLY"
+	FrequencyYearly    = "YEARLY"
+	FrequencyCustom    = "CUSTOM" // Cron-like rule, see package recurrence
+)
+
+// Recurring Invoice Status
+const (
+	RecurringStatusActive    = "ACTIVE"
+	RecurringStatusPaused    = "PAUSED"
+	RecurringStatusCompleted = "COMPLETED"
+)
+
+// Recurring Invoice Run Status
+const (
+	RunStatusSuccess = "SUCCESS"
+	RunStatusFailed  = "FAILED"
+)
+
+// Recurring Invoice Scheduler
+const (
+	DefaultPreviewCount = 12
+	MaxPreviewCount     = 100
+	MaxCatchUpRuns      = 24 // Missed periods generated per schedule in one scheduler pass
+)
+
+// Discount Types
+const (
+	DiscountTypePercent = "PERCENT"
+	DiscountTypeFlat    = "FLAT"
+)
+
+// Idempotency Key Status
+const (
+	IdempotencyStatusInProgress = "IN_PROGRESS"
+	IdempotencyStatusCompleted  = "COMPLETED"
+)
+
+// Idempotency Keys
+const (
+	MaxIdempotencyKeyLength = 255
+	IdempotencyLockTimeout  = 5 * time.Minute // In-progress keys older than this are treated as abandoned
+)
+
+// Party Types
+const (
+	PartyTypeCustomer = "CUSTOMER"
+	PartyTypeVendor   = "VENDOR"
+	PartyTypeBoth     = "BOTH"
+)
+
 // Payment Status
 const (
 	PaymentStatusPending = "PENDING"
@@ -14,6 +126,12 @@ const (
 	PaymentStatusPaid    = "PAID"
 )
 
+// Supply Types
+const (
+	SupplyTypeIntraState = "INTRA_STATE"
+	SupplyTypeInterState = "INTER_STATE"
+)
+
 // Payment Methods
 const (
 	PaymentMethodCash         = "CASH"
@@ -21,6 +139,94 @@ const (
 	PaymentMethodCheque       = "CHEQUE"
 	PaymentMethodUPI          = "UPI"
 	PaymentMethodCard         = "CARD"
+	PaymentMethodCredit       = "CREDIT" // Paid from the party's credit balance
+)
+
+// Payment Types
+const (
+	PaymentTypeReceipt = "RECEIPT"
+	PaymentTypeRefund  = "REFUND"
+)
+
+// Payment Record Statuses. Cheques are received, deposited and then cleared
+// or bounced; other payments are cleared as soon as they are recorded.
+// Bounced and reversed payments no longer count towards the amount paid.
+const (
+	PaymentReceived  = "RECEIVED"
+	PaymentDeposited = "DEPOSITED"
+	PaymentCleared   = "CLEARED"
+	PaymentBounced   = "BOUNCED"
+	PaymentReversed  = "REVERSED"
+)
+
+// Payment Event Actions
+const (
+	PaymentActionRecorded  = "RECORDED"
+	PaymentActionDeposited = "DEPOSITED"
+	PaymentActionCleared   = "CLEARED"
+	PaymentActionBounced   = "BOUNCED"
+	PaymentActionReversed  = "REVERSED"
+)
+
+// Credit Entry Types
+const (
+	CreditEntryAdvance     = "ADVANCE"     // Received before invoicing, or left unallocated on a receipt
+	CreditEntryOverpayment = "OVERPAYMENT" // Excess of a payment over the amount due
+	CreditEntryApplied     = "APPLIED"     // Used to pay an invoice
+	CreditEntryReversal    = "REVERSAL"    // Undoes an earlier entry
+)
+
+// Bank Statement Formats
+const (
+	StatementFormatCSV   = "CSV"
+	StatementFormatOFX   = "OFX"
+	StatementFormatMT940 = "MT940"
+)
+
+// Bank Transaction Statuses
+const (
+	BankTransactionUnmatched  = "UNMATCHED"
+	BankTransactionProposed   = "PROPOSED"   // A match awaits confirmation
+	BankTransactionReconciled = "RECONCILED" // Matched to a payment or receipt
+	BankTransactionIgnored    = "IGNORED"    // Not a customer receipt, such as bank interest
+)
+
+// Bank Reconciliation Match Types
+const (
+	MatchTypeInvoice = "INVOICE" // An open invoice, paid on confirmation
+	MatchTypePayment = "PAYMENT" // A payment already recorded
+	MatchTypeReceipt = "RECEIPT" // A receipt already recorded
+)
+
+// Bank Reconciliation
+const (
+	DefaultMatchWindowDays = 7
+	MaxMatchWindowDays     = 90
+)
+
+// UPI QR Codes, in pixels per module
+const (
+	DefaultQRModuleSize = 8
+	MaxQRModuleSize     = 40
+)
+
+// Transport Modes
+const (
+	TransportModeRoad = "ROAD"
+	TransportModeRail = "RAIL"
+	TransportModeAir  = "AIR"
+	TransportModeShip = "SHIP"
+)
+
+// Vehicle Types
+const (
+	VehicleTypeRegular = "REGULAR"
+	VehicleTypeODC     = "ODC" // Over-dimensional cargo
+)
+
+// E-way Bills
+const (
+	MaxTransportDistanceKm = 4000
 )
 
 // Default Values
@@ -45,7 +251,9 @@ const (
 
 // HTTP Headers
 const (
-	AuthorizationHeader = "Authorization"
+	AuthorizationHeader    = "Authorization"
+	IdempotencyKeyHeader   = "Idempotency-Key"
+	IdempotentReplayHeader = "Idempotent-Replayed"
 )
 
 // Valid invoice types slice
@@ -55,6 +263,38 @@ var ValidInvoiceTypes = []string{
 	InvoiceTypeDebit,
 }
 
+// Valid invoice statuses slice
+var ValidInvoiceStatuses = []string{
+	InvoiceStatusDraft,
+	InvoiceStatusIssued,
+	InvoiceStatusVoid,
+	InvoiceStatusCancelled,
+}
+
+// Valid quotation statuses slice
+var ValidQuotationStatuses = []string{
+	QuotationStatusOpen,
+	QuotationStatusAccepted,
+	QuotationStatusRejected,
+	QuotationStatusExpired,
+	QuotationStatusConverted,
+}
+
+// Valid recurring invoice frequencies slice
+var ValidFrequencies = []string{
+	FrequencyMonthly,
+	FrequencyQuarterly,
+	FrequencyYearly,
+	FrequencyCustom,
+}
+
+// Valid party types slice
+var ValidPartyTypes = []string{
+	PartyTypeCustomer,
+	PartyTypeVendor,
+	PartyTypeBoth,
+}
+
 // Valid payment methods slice
 var ValidPaymentMethods = []string{
 	PaymentMethodCash,
@@ -62,6 +302,21 @@ var ValidPaymentMethods = []string{
 	PaymentMethodCheque,
 	PaymentMethodUPI,
 	PaymentMethodCard,
+	PaymentMethodCredit,
+}
+
+// Valid bank statement formats slice
+var ValidStatementFormats = []string{
+	StatementFormatCSV,
+	StatementFormatOFX,
+	StatementFormatMT940,
+}
+
+// InactivePaymentStatuses are the statuses of payments that no longer count
+// towards the amount paid on an invoice
+var InactivePaymentStatuses = []string{
+	PaymentBounced,
+	PaymentReversed,
 }
 
 // Valid payment statuses slice
@@ -37,9 +37,27 @@ func Initialize(cfg *config.Config) error {
 		&models.User{},
 		&models.Category{},
 		&models.Item{},
+		&models.Party{},
+		&models.PartyContact{},
 		&models.Invoice{},
 		&models.InvoiceLineItem{},
+		&models.InvoiceRevision{},
 		&models.Payment{},
+		&models.Receipt{},
+		&models.PaymentEvent{},
+		&models.CreditEntry{},
+		&models.BankStatement{},
+		&models.BankTransaction{},
+		&models.AdjustmentNote{},
+		&models.AdjustmentNoteLineItem{},
+		&models.NumberSeries{},
+		&models.NumberSequence{},
+		&models.RecurringInvoice{},
+		&models.RecurringInvoiceLineItem{},
+		&models.RecurringInvoiceRun{},
+		&models.Quotation{},
+		&models.QuotationLineItem{},
+		&models.IdempotencyKey{},
 	)
 	if err != nil {
 		return fmt.Errorf("failed to migrate database: %w", err)
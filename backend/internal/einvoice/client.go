@@ -0,0 +1,215 @@
+package einvoice
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+	"unicode/utf8"
+
+	"github.com/golang-jwt/jwt/v5"
+	"invoice-generator/internal/money"
+)
+
+// AckDateLayout formats acknowledgement dates as the IRP returns them
+const AckDateLayout = "2006-01-02 15:04:05"
+
+// Client registers e-invoices with the IRP. Implementations call the IRP
+// directly or through a GST Suvidha Provider (GSP); StubClient answers
+// locally for development and testing.
+type Client interface {
+	// GenerateIRN uploads an e-invoice and returns the IRP's
+	// acknowledgement. Uploading the same document again returns the IRN
+	// it was first registered under.
+	GenerateIRN(ctx context.Context, invoice *Invoice) (*Acknowledgement, error)
+
+	// CancelIRN cancels a registered IRN and returns the time the IRP
+	// cancelled it. The IRP only accepts cancellations within CancelWindow
+	// of registration; after that the invoice must be corrected with a
+	// credit note.
+	CancelIRN(ctx context.Context, cancellation *Cancellation) (*CancelAcknowledgement, error)
+}
+
+// CancelWindow is how long after registration the IRP accepts the
+// cancellation of an IRN
+const CancelWindow = 24 * time.Hour
+
+// maxCancelRemarksLength is the longest cancellation remark the IRP accepts
+const maxCancelRemarksLength = 100
+
+// Reason codes for cancelling an IRN
+const (
+	CancelReasonDuplicate        = "1"
+	CancelReasonDataEntryMistake = "2"
+	CancelReasonOrderCancelled   = "3"
+	CancelReasonOther            = "4"
+)
+
+// Cancellation asks the IRP to cancel an IRN
+type Cancellation struct {
+	IRN        string `json:"Irn"`
+	ReasonCode string `json:"CnlRsn"` // One of the CancelReason codes
+	Remarks    string `json:"CnlRem"`
+}
+
+// Validate checks a cancellation against the IRP's rules
+func (c *Cancellation) Validate() error {
+	if len(c.IRN) != 64 {
+		return errors.New("IRN must be 64 characters")
+	}
+	switch c.ReasonCode {
+	case CancelReasonDuplicate, CancelReasonDataEntryMistake, CancelReasonOrderCancelled, CancelReasonOther:
+	default:
+		return errors.New("reason code must be 1 (duplicate), 2 (data entry mistake), 3 (order cancelled) or 4 (other)")
+	}
+	if c.Remarks == "" {
+		return errors.New("remarks are required")
+	}
+	if utf8.RuneCountInString(c.Remarks) > maxCancelRemarksLength {
+		return fmt.Errorf("remarks must be at most %d characters", maxCancelRemarksLength)
+	}
+	return nil
+}
+
+// CancelAcknowledgement is the IRP's response to a cancelled IRN
+type CancelAcknowledgement struct {
+	IRN        string    `json:"irn"`
+	CancelDate time.Time `json:"cancel_date"`
+}
+
+// Acknowledgement is the IRP's response to a registered e-invoice
+type Acknowledgement struct {
+	IRN          string    `json:"irn"`
+	AckNo        string    `json:"ack_no"`
+	AckDate      time.Time `json:"ack_date"`
+	SignedQRCode string    `json:"signed_qr_code"` // JWT signed by the IRP, printed on the invoice as a QR code
+}
+
+// QRData is the content of the signed QR code, carried as a JSON string in
+// the "data" claim of the JWT
+type QRData struct {
+	SellerGSTIN  string  `json:"SellerGstin"`
+	BuyerGSTIN   string  `json:"BuyerGstin"`
+	DocNumber    string  `json:"DocNo"`
+	DocType      string  `json:"DocTyp"`
+	DocDate      string  `json:"DocDt"`
+	InvoiceValue float64 `json:"TotInvVal"`
+	ItemCount    int     `json:"ItemCnt"`
+	MainHSNCode  string  `json:"MainHsnCode"`
+	IRN          string  `json:"Irn"`
+	IRNDate      string  `json:"IrnDt"`
+}
+
+// IRN computes the Invoice Reference Number of a document: the SHA-256 hash
+// of the seller's GSTIN, the financial year, the document type and the
+// document number. The IRP computes it the same way, which is what makes
+// a document number unique per seller and year.
+func IRN(invoice *Invoice) (string, error) {
+	date, err := time.Parse(DateLayout, invoice.Document.Date)
+	if err != nil {
+		return "", fmt.Errorf("invalid document date %q", invoice.Document.Date)
+	}
+	year := date.Year()
+	if date.Month() < time.April {
+		year--
+	}
+	financialYear := fmt.Sprintf("%d-%02d", year, (year+1)%100)
+
+	sum := sha256.Sum256([]byte(invoice.Seller.GSTIN + financialYear + invoice.Document.Type + invoice.Document.Number))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// StubClient is a Client that registers e-invoices and cancels IRNs
+// locally without contacting the IRP. It validates the e-invoice, computes the IRN as the
+// IRP would and signs the QR code with its own key, so everything
+// downstream of the upload can be exercised. Its QR codes do not verify
+// against the IRP's public key.
+type StubClient struct {
+	key []byte
+}
+
+// NewStubClient creates a stub client that signs QR codes with key
+func NewStubClient(key []byte) *StubClient {
+	return &StubClient{key: key}
+}
+
+// GenerateIRN implements Client
+func (c *StubClient) GenerateIRN(ctx context.Context, invoice *Invoice) (*Acknowledgement, error) {
+	if err := invoice.Validate(); err != nil {
+		return nil, err
+	}
+	irn, err := IRN(invoice)
+	if err != nil {
+		return nil, err
+	}
+
+	// The acknowledgement number is derived from the IRN so that uploading
+	// the same document again gives the same acknowledgement
+	hash, _ := hex.DecodeString(irn)
+	ackNo := new(big.Int).SetBytes(hash[:8])
+	ackNo.Mod(ackNo, big.NewInt(1e14)).Add(ackNo, big.NewInt(1e14))
+	ackDate := time.Now().Truncate(time.Second)
+
+	signedQR, err := c.signQRCode(invoice, irn, ackDate)
+	if err != nil {
+		return nil, err
+	}
+	return &Acknowledgement{
+		IRN:          irn,
+		AckNo:        ackNo.String(),
+		AckDate:      ackDate,
+		SignedQRCode: signedQR,
+	}, nil
+}
+
+// CancelIRN implements Client
+func (c *StubClient) CancelIRN(ctx context.Context, cancellation *Cancellation) (*CancelAcknowledgement, error) {
+	if err := cancellation.Validate(); err != nil {
+		return nil, err
+	}
+	return &CancelAcknowledgement{
+		IRN:        cancellation.IRN,
+		CancelDate: time.Now().Truncate(time.Second),
+	}, nil
+}
+
+// signQRCode builds the QR code content of an e-invoice and signs it
+func (c *StubClient) signQRCode(invoice *Invoice, irn string, ackDate time.Time) (string, error) {
+	data, err := json.Marshal(NewQRData(invoice, irn, ackDate))
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"data": string(data),
+		"iss":  "NIC",
+	})
+	return token.SignedString(c.key)
+}
+
+// NewQRData builds the QR code content of a registered e-invoice. The
+// main HSN code is that of the line with the highest taxable value.
+func NewQRData(invoice *Invoice, irn string, ackDate time.Time) QRData {
+	data := QRData{
+		SellerGSTIN:  invoice.Seller.GSTIN,
+		BuyerGSTIN:   invoice.Buyer.GSTIN,
+		DocNumber:    invoice.Document.Number,
+		DocType:      invoice.Document.Type,
+		DocDate:      invoice.Document.Date,
+		InvoiceValue: invoice.Values.InvoiceValue.Float64(),
+		ItemCount:    len(invoice.Items),
+		IRN:          irn,
+		IRNDate:      ackDate.Format(AckDateLayout),
+	}
+	var largest money.Amount
+	for i, item := range invoice.Items {
+		if i == 0 || item.TaxableValue > largest {
+			data.MainHSNCode = item.HSNCode
+			largest = item.TaxableValue
+		}
+	}
+	return data
+}
@@ -0,0 +1,446 @@
+// Package einvoice builds e-invoices in the NIC schema (version 1.1)
+// accepted by the Invoice Registration Portal (IRP), checks them against
+// the schema's rules and registers them with the IRP through a pluggable
+// Client.
+package einvoice
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"invoice-generator/internal/gst"
+	"invoice-generator/internal/models"
+	"invoice-generator/internal/money"
+	"invoice-generator/internal/validation"
+)
+
+// SchemaVersion is the version of the NIC e-invoice schema produced
+const SchemaVersion = "1.1"
+
+// DateLayout formats dates as the schema expects, e.g. "05/04/2025"
+const DateLayout = "02/01/2006"
+
+// Codes used in the schema
+const (
+	TaxSchemeGST      = "GST"
+	SupplyTypeB2B     = "B2B"
+	DocTypeInvoice    = "INV"
+	DocTypeCreditNote = "CRN"
+	DocTypeDebitNote  = "DBN"
+	Yes               = "Y"
+	No                = "N"
+)
+
+// MaxItems is the largest number of lines an e-invoice may have
+const MaxItems = 1000
+
+// tolerance is the rounding difference the IRP accepts between a value and
+// the total it is checked against
+var tolerance = money.FromRupees(1)
+
+// docNumberPattern is the format of document numbers the IRP accepts: up
+// to 16 letters, digits, slashes and dashes, not starting with 0, / or -
+var docNumberPattern = regexp.MustCompile(`^[A-Za-z1-9][A-Za-z0-9/-]{0,15}$`)
+
+// Invoice is an e-invoice in the NIC schema
+type Invoice struct {
+	Version     string             `json:"Version"`
+	Transaction TransactionDetails `json:"TranDtls"`
+	Document    DocumentDetails    `json:"DocDtls"`
+	Seller      Party              `json:"SellerDtls"`
+	Buyer       Buyer              `json:"BuyerDtls"`
+	Ship        *Party             `json:"ShipDtls,omitempty"`
+	Items       []Item             `json:"ItemList"`
+	Values      ValueDetails       `json:"ValDtls"`
+}
+
+// TransactionDetails describes the kind of supply
+type TransactionDetails struct {
+	TaxScheme     string `json:"TaxSch"`
+	SupplyType    string `json:"SupTyp"`
+	ReverseCharge string `json:"RegRev"`
+	IGSTOnIntra   string `json:"IgstOnIntra"`
+}
+
+// DocumentDetails identifies the invoice or note
+type DocumentDetails struct {
+	Type   string `json:"Typ"`
+	Number string `json:"No"`
+	Date   string `json:"Dt"`
+}
+
+// Party is the seller, or the party goods are shipped to
+type Party struct {
+	GSTIN     string `json:"Gstin,omitempty"`
+	LegalName string `json:"LglNm"`
+	TradeName string `json:"TrdNm,omitempty"`
+	Address1  string `json:"Addr1"`
+	Address2  string `json:"Addr2,omitempty"`
+	Location  string `json:"Loc"`
+	Pincode   int    `json:"Pin"`
+	StateCode string `json:"Stcd"`
+	Phone     string `json:"Ph,omitempty"`
+	Email     string `json:"Em,omitempty"`
+}
+
+// Buyer is the recipient of the supply
+type Buyer struct {
+	GSTIN         string `json:"Gstin"`
+	LegalName     string `json:"LglNm"`
+	TradeName     string `json:"TrdNm,omitempty"`
+	PlaceOfSupply string `json:"Pos"`
+	Address1      string `json:"Addr1"`
+	Address2      string `json:"Addr2,omitempty"`
+	Location      string `json:"Loc"`
+	Pincode       int    `json:"Pin"`
+	StateCode     string `json:"Stcd"`
+	Phone         string `json:"Ph,omitempty"`
+	Email         string `json:"Em,omitempty"`
+}
+
+// Item is one line of an e-invoice
+type Item struct {
+	SerialNumber string         `json:"SlNo"`
+	Description  string         `json:"PrdDesc,omitempty"`
+	IsService    string         `json:"IsServc"`
+	HSNCode      string         `json:"HsnCd"`
+	Quantity     money.Quantity `json:"Qty"`
+	Unit         string         `json:"Unit,omitempty"`
+	UnitPrice    money.Amount   `json:"UnitPrice"`
+	TotalAmount  money.Amount   `json:"TotAmt"`   // Quantity x unit price
+	Discount     money.Amount   `json:"Discount"` // Line discount and share of the invoice discount
+	TaxableValue money.Amount   `json:"AssAmt"`
+	GSTRate      int            `json:"GstRt"`
+	IGST         money.Amount   `json:"IgstAmt"`
+	CGST         money.Amount   `json:"CgstAmt"`
+	SGST         money.Amount   `json:"SgstAmt"`
+	Cess         money.Amount   `json:"CesAmt"`
+	OtherCharges money.Amount   `json:"OthChrg"`
+	ItemValue    money.Amount   `json:"TotItemVal"`
+}
+
+// ValueDetails holds the invoice totals
+type ValueDetails struct {
+	TaxableValue money.Amount `json:"AssVal"`
+	CGST         money.Amount `json:"CgstVal"`
+	SGST         money.Amount `json:"SgstVal"`
+	IGST         money.Amount `json:"IgstVal"`
+	Cess         money.Amount `json:"CesVal"`
+	Discount     money.Amount `json:"Discount"` // Discount after tax; discounts here are all before tax
+	OtherCharges money.Amount `json:"OthChrg"`
+	RoundOff     money.Amount `json:"RndOffAmt"`
+	InvoiceValue money.Amount `json:"TotInvVal"`
+}
+
+// FromInvoice builds the e-invoice of an issued invoice from its seller
+// and buyer snapshots and its lines. Line items should have their catalog
+// item preloaded so that units and missing HSN codes can be filled in.
+func FromInvoice(invoice *models.Invoice) *Invoice {
+	seller := invoice.Seller
+	buyer := invoice.Buyer
+
+	e := &Invoice{
+		Version: SchemaVersion,
+		Transaction: TransactionDetails{
+			TaxScheme:     TaxSchemeGST,
+			SupplyType:    SupplyTypeB2B,
+			ReverseCharge: No,
+			IGSTOnIntra:   No,
+		},
+		Document: DocumentDetails{
+			Type:   DocTypeInvoice,
+			Number: invoice.InvoiceNumber,
+			Date:   invoice.InvoiceDate.Format(DateLayout),
+		},
+		Seller: partyDetails(&seller),
+		Values: ValueDetails{
+			TaxableValue: invoice.SubTotal,
+			CGST:         invoice.TotalCGST,
+			SGST:         invoice.TotalSGST,
+			IGST:         invoice.TotalIGST,
+			InvoiceValue: invoice.TotalAmount,
+		},
+	}
+
+	b := partyDetails(&buyer)
+	e.Buyer = Buyer{
+		GSTIN:         b.GSTIN,
+		LegalName:     b.LegalName,
+		TradeName:     b.TradeName,
+		PlaceOfSupply: invoice.PlaceOfSupply,
+		Address1:      b.Address1,
+		Address2:      b.Address2,
+		Location:      b.Location,
+		Pincode:       b.Pincode,
+		StateCode:     b.StateCode,
+		Phone:         b.Phone,
+		Email:         b.Email,
+	}
+
+	if invoice.ShipTo.Address != (models.Address{}) {
+		shipTo := invoice.ShipTo
+		if shipTo.Name == "" && shipTo.CompanyName == "" {
+			shipTo.Name, shipTo.CompanyName = buyer.Name, buyer.CompanyName
+		}
+		ship := partyDetails(&shipTo)
+		ship.Phone, ship.Email = "", "" // Not part of the shipping details
+		e.Ship = &ship
+	}
+
+	for i, line := range invoice.LineItems {
+		hsn := line.HSNCode
+		unit := ""
+		if line.Item != nil {
+			if hsn == "" {
+				hsn = line.Item.HSNCode
+			}
+			unit = line.Item.Unit
+		}
+		discount := line.DiscountAmount + line.InvoiceDiscountAmount
+		gross := line.GrossAmount
+		if gross.IsZero() {
+			// Lines saved before discounts were recorded separately
+			gross = line.Amount + discount
+		}
+
+		item := Item{
+			SerialNumber: strconv.Itoa(i + 1),
+			Description:  gst.Truncate(line.Description, 300),
+			IsService:    No,
+			HSNCode:      hsn,
+			Quantity:     line.Quantity,
+			UnitPrice:    line.Rate,
+			TotalAmount:  gross,
+			Discount:     discount,
+			TaxableValue: line.Amount,
+			GSTRate:      line.GSTRate,
+			IGST:         line.IGSTAmount,
+			CGST:         line.CGSTAmount,
+			SGST:         line.SGSTAmount,
+			ItemValue:    line.TotalAmount,
+		}
+		if gst.IsServiceCode(hsn) {
+			item.IsService = Yes
+		} else {
+			item.Unit = gst.UQC(unit, hsn)
+		}
+		e.Items = append(e.Items, item)
+	}
+	return e
+}
+
+// partyDetails maps a party snapshot onto the schema's party fields,
+// splitting long addresses over the two address lines
+func partyDetails(p *models.PartySnapshot) Party {
+	legalName := strings.TrimSpace(p.CompanyName)
+	if legalName == "" {
+		legalName = strings.TrimSpace(p.Name)
+	}
+	stateCode := p.StateCode
+	if stateCode == "" {
+		stateCode = gst.ResolveStateCode(p.GSTIN, p.State)
+	}
+	pincode, _ := strconv.Atoi(strings.ReplaceAll(p.Pincode, " ", ""))
+
+	address1, address2 := gst.SplitAddress(p.Address.Address, 100)
+	return Party{
+		GSTIN:     p.GSTIN,
+		LegalName: gst.Truncate(legalName, 100),
+		Address1:  address1,
+		Address2:  address2,
+		Location:  gst.Truncate(strings.TrimSpace(p.City), 50),
+		Pincode:   pincode,
+		StateCode: stateCode,
+		Phone:     digits(p.Phone),
+		Email:     strings.TrimSpace(p.Email),
+	}
+}
+
+// digits keeps only the digits of a phone number
+func digits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// Validate checks the e-invoice against the schema and the value checks
+// the IRP applies, so that problems are found before it is uploaded.
+// Failures are reported per field, named by their path in the JSON.
+func (e *Invoice) Validate() error {
+	var errs validation.Errors
+
+	if e.Version != SchemaVersion {
+		errs.Add("Version", "must be "+SchemaVersion)
+	}
+	if e.Transaction.TaxScheme != TaxSchemeGST {
+		errs.Add("TranDtls.TaxSch", "must be GST")
+	}
+	if e.Transaction.SupplyType != SupplyTypeB2B {
+		errs.Add("TranDtls.SupTyp", "only B2B supplies are supported")
+	}
+
+	switch e.Document.Type {
+	case DocTypeInvoice, DocTypeCreditNote, DocTypeDebitNote:
+	default:
+		errs.Add("DocDtls.Typ", "must be INV, CRN or DBN")
+	}
+	if !docNumberPattern.MatchString(e.Document.Number) {
+		errs.Add("DocDtls.No", "must be 1 to 16 letters, digits, / or -, not starting with 0, / or -")
+	}
+	if date, err := time.Parse(DateLayout, e.Document.Date); err != nil {
+		errs.Add("DocDtls.Dt", "must be a date as DD/MM/YYYY")
+	} else if date.After(time.Now()) {
+		errs.Add("DocDtls.Dt", "cannot be in the future")
+	}
+
+	checkParty(&errs, "SellerDtls", e.Seller, true)
+	buyer := e.Buyer
+	checkParty(&errs, "BuyerDtls", Party{
+		GSTIN:     buyer.GSTIN,
+		LegalName: buyer.LegalName,
+		Address1:  buyer.Address1,
+		Location:  buyer.Location,
+		Pincode:   buyer.Pincode,
+		StateCode: buyer.StateCode,
+		Phone:     buyer.Phone,
+		Email:     buyer.Email,
+	}, true)
+	if !gst.IsValidStateCode(buyer.PlaceOfSupply) {
+		errs.Add("BuyerDtls.Pos", "must be a valid GST state code")
+	}
+	if e.Seller.GSTIN != "" && e.Seller.GSTIN == buyer.GSTIN {
+		errs.Add("BuyerDtls.Gstin", "cannot be the seller's GSTIN")
+	}
+	if e.Ship != nil {
+		checkParty(&errs, "ShipDtls", *e.Ship, false)
+	}
+
+	e.checkItems(&errs)
+	return errs.Err()
+}
+
+// checkParty checks the name, registration and address of a party
+func checkParty(errs *validation.Errors, path string, p Party, gstinRequired bool) {
+	if p.GSTIN != "" || gstinRequired {
+		if err := validation.ValidateGSTIN(p.GSTIN); err != nil {
+			errs.Add(path+".Gstin", err.Error())
+		}
+	}
+	if n := utf8.RuneCountInString(p.LegalName); n < 3 || n > 100 {
+		errs.Add(path+".LglNm", "must be 3 to 100 characters")
+	}
+	if n := utf8.RuneCountInString(p.Address1); n < 1 || n > 100 {
+		errs.Add(path+".Addr1", "must be 1 to 100 characters")
+	}
+	if n := utf8.RuneCountInString(p.Location); n < 3 || n > 50 {
+		errs.Add(path+".Loc", "must be 3 to 50 characters")
+	}
+	if p.Pincode < 100000 || p.Pincode > 999999 {
+		errs.Add(path+".Pin", "must be a 6 digit PIN code")
+	}
+	if !gst.IsValidStateCode(p.StateCode) {
+		errs.Add(path+".Stcd", "must be a valid GST state code")
+	} else if p.GSTIN != "" && gst.StateCodeFromGSTIN(p.GSTIN) != "" && gst.StateCodeFromGSTIN(p.GSTIN) != p.StateCode {
+		errs.Add(path+".Stcd", "does not match the state of the GSTIN")
+	}
+	if n := len(p.Phone); n != 0 && (n < 6 || n > 12) {
+		errs.Add(path+".Ph", "must be 6 to 12 digits")
+	}
+	if n := len(p.Email); n != 0 && (n < 6 || n > 100 || !strings.Contains(p.Email, "@")) {
+		errs.Add(path+".Em", "must be a valid email address")
+	}
+}
+
+// checkItems checks each line's codes and arithmetic and that the invoice
+// totals agree with the lines
+func (e *Invoice) checkItems(errs *validation.Errors) {
+	if len(e.Items) == 0 || len(e.Items) > MaxItems {
+		errs.Add("ItemList", fmt.Sprintf("must have 1 to %d items", MaxItems))
+	}
+
+	interState := e.Seller.StateCode != e.Buyer.PlaceOfSupply
+	var total ValueDetails
+	for i, item := range e.Items {
+		path := fmt.Sprintf("ItemList[%d]", i)
+		if err := validation.ValidateHSN(item.HSNCode); err != nil {
+			errs.Add(path+".HsnCd", err.Error())
+		}
+		if item.IsService != Yes && item.IsService != No {
+			errs.Add(path+".IsServc", "must be Y or N")
+		}
+		if utf8.RuneCountInString(item.Description) > 300 {
+			errs.Add(path+".PrdDesc", "must be at most 300 characters")
+		}
+		if n := len(item.Unit); n != 0 && (n < 3 || n > 8) {
+			errs.Add(path+".Unit", "must be 3 to 8 characters")
+		}
+		if item.Quantity < 0 {
+			errs.Add(path+".Qty", "cannot be negative")
+		}
+		if !gst.IsValidRate(item.GSTRate) {
+			errs.Add(path+".GstRt", fmt.Sprintf("%d%% is not a GST rate", item.GSTRate))
+		}
+		if !withinTolerance(item.TaxableValue, item.TotalAmount-item.Discount) {
+			errs.Add(path+".AssAmt", "must equal TotAmt less Discount")
+		}
+
+		tax := item.TaxableValue.Percent(item.GSTRate)
+		if interState {
+			if !item.CGST.IsZero() || !item.SGST.IsZero() {
+				errs.Add(path+".CgstAmt", "inter-state supplies are taxed with IGST only")
+			}
+			if !withinTolerance(item.IGST, tax) {
+				errs.Add(path+".IgstAmt", fmt.Sprintf("must be %d%% of AssAmt", item.GSTRate))
+			}
+		} else {
+			if !item.IGST.IsZero() {
+				errs.Add(path+".IgstAmt", "intra-state supplies are taxed with CGST and SGST only")
+			}
+			if !withinTolerance(item.CGST+item.SGST, tax) {
+				errs.Add(path+".CgstAmt", fmt.Sprintf("CgstAmt and SgstAmt must add up to %d%% of AssAmt", item.GSTRate))
+			}
+		}
+		itemValue := item.TaxableValue + item.IGST + item.CGST + item.SGST + item.Cess + item.OtherCharges
+		if !withinTolerance(item.ItemValue, itemValue) {
+			errs.Add(path+".TotItemVal", "must equal AssAmt plus tax and other charges")
+		}
+
+		total.TaxableValue += item.TaxableValue
+		total.IGST += item.IGST
+		total.CGST += item.CGST
+		total.SGST += item.SGST
+		total.Cess += item.Cess
+		total.InvoiceValue += item.ItemValue
+	}
+
+	v := e.Values
+	checks := []struct {
+		field       string
+		value, want money.Amount
+	}{
+		{"ValDtls.AssVal", v.TaxableValue, total.TaxableValue},
+		{"ValDtls.IgstVal", v.IGST, total.IGST},
+		{"ValDtls.CgstVal", v.CGST, total.CGST},
+		{"ValDtls.SgstVal", v.SGST, total.SGST},
+		{"ValDtls.CesVal", v.Cess, total.Cess},
+		{"ValDtls.TotInvVal", v.InvoiceValue, total.InvoiceValue - v.Discount + v.OtherCharges + v.RoundOff},
+	}
+	for _, check := range checks {
+		if !withinTolerance(check.value, check.want) {
+			errs.Add(check.field, "does not agree with the items, expected "+check.want.String())
+		}
+	}
+}
+
+// withinTolerance reports whether two amounts differ by no more than the
+// rounding the IRP accepts
+func withinTolerance(a, b money.Amount) bool {
+	return (a - b).Abs() <= tolerance
+}
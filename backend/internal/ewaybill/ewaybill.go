@@ -0,0 +1,409 @@
+// Package ewaybill builds e-way bills for goods invoices in the JSON format
+// of the e-way bill portal's bulk generation tool, and checks the fields
+// the portal requires before a file is uploaded.
+package ewaybill
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"invoice-generator/internal/constants"
+	"invoice-generator/internal/gst"
+	"invoice-generator/internal/models"
+	"invoice-generator/internal/money"
+	"invoice-generator/internal/validation"
+)
+
+// Version is the bulk generation format version files declare
+const Version = "1.0.0621"
+
+// Threshold is the consignment value above which goods may only be moved
+// with an e-way bill
+var Threshold = money.FromRupees(50000)
+
+// DateLayout formats dates as the portal expects, e.g. "05/04/2025"
+const DateLayout = "02/01/2006"
+
+// Codes used in the bulk generation format
+const (
+	SupplyTypeOutward     = "O"
+	SubSupplyTypeSupply   = 1
+	DocTypeTaxInvoice     = "INV"
+	TransTypeRegular      = 1
+	TransTypeBillToShipTo = 2 // Goods delivered to a third party's address on the buyer's behalf
+	UnregisteredGSTIN     = "URP"
+	VehicleTypeRegular    = "R"
+	VehicleTypeODC        = "O"
+)
+
+// MaxItems is the largest number of lines an e-way bill may have
+const MaxItems = 250
+
+// TransportModes maps transport modes to their codes in the format
+var TransportModes = map[string]int{
+	constants.TransportModeRoad: 1,
+	constants.TransportModeRail: 2,
+	constants.TransportModeAir:  3,
+	constants.TransportModeShip: 4,
+}
+
+// tolerance is the rounding difference the portal accepts between the
+// invoice value and the sum of its parts
+var tolerance = money.FromRupees(2)
+
+// docNumberPattern is the format of document numbers the portal accepts:
+// up to 16 letters, digits, slashes and dashes, not starting with 0, / or -
+var docNumberPattern = regexp.MustCompile(`^[A-Za-z1-9][A-Za-z0-9/-]{0,15}$`)
+
+// BulkFile is the file uploaded to the bulk generation tool
+type BulkFile struct {
+	Version string `json:"version"`
+	Bills   []Bill `json:"billLists"`
+}
+
+// Bill is the e-way bill of one invoice
+type Bill struct {
+	UserGSTIN           string       `json:"userGstin"`
+	SupplyType          string       `json:"supplyType"`
+	SubSupplyType       int          `json:"subSupplyType"`
+	SubSupplyDesc       string       `json:"subSupplyDesc"`
+	DocType             string       `json:"docType"`
+	DocNo               string       `json:"docNo"`
+	DocDate             string       `json:"docDate"`
+	TransType           int          `json:"transType"`
+	FromGSTIN           string       `json:"fromGstin"`
+	FromTradeName       string       `json:"fromTrdName"`
+	FromAddress1        string       `json:"fromAddr1"`
+	FromAddress2        string       `json:"fromAddr2"`
+	FromPlace           string       `json:"fromPlace"`
+	FromPincode         int          `json:"fromPincode"`
+	FromStateCode       int          `json:"fromStateCode"`
+	ActualFromStateCode int          `json:"actualFromStateCode"`
+	ToGSTIN             string       `json:"toGstin"`
+	ToTradeName         string       `json:"toTrdName"`
+	ToAddress1          string       `json:"toAddr1"` // Delivery address: the ship-to address when goods go elsewhere
+	ToAddress2          string       `json:"toAddr2"`
+	ToPlace             string       `json:"toPlace"`
+	ToPincode           int          `json:"toPincode"`
+	ToStateCode         int          `json:"toStateCode"` // Place of supply
+	ActualToStateCode   int          `json:"actualToStateCode"`
+	TotalValue          money.Amount `json:"totalValue"` // Taxable value of the goods
+	CGSTValue           money.Amount `json:"cgstValue"`
+	SGSTValue           money.Amount `json:"sgstValue"`
+	IGSTValue           money.Amount `json:"igstValue"`
+	CessValue           money.Amount `json:"cessValue"`
+	CessNonAdvolValue   money.Amount `json:"TotNonAdvolVal"`
+	OtherValue          money.Amount `json:"OthValue"` // Services billed on the same invoice, with their tax
+	TotalInvoiceValue   money.Amount `json:"totInvValue"`
+	TransMode           int          `json:"transMode,omitempty"`
+	TransDistance       int          `json:"transDistance"`
+	TransporterName     string       `json:"transporterName"`
+	TransporterID       string       `json:"transporterId"`
+	TransDocNo          string       `json:"transDocNo"`
+	TransDocDate        string       `json:"transDocDate"`
+	VehicleNo           string       `json:"vehicleNo"`
+	VehicleType         string       `json:"vehicleType"`
+	MainHSNCode         int          `json:"mainHsnCode"`
+	Items               []Item       `json:"itemList"`
+}
+
+// Item is one goods line of an e-way bill
+type Item struct {
+	ItemNo             int            `json:"itemNo"`
+	ProductName        string         `json:"productName"`
+	ProductDescription string         `json:"productDesc"`
+	HSNCode            int            `json:"hsnCode"`
+	Quantity           money.Quantity `json:"quantity"`
+	QuantityUnit       string         `json:"qtyUnit"`
+	TaxableAmount      money.Amount   `json:"taxableAmount"`
+	CGSTRate           float64        `json:"cgstRate"`
+	SGSTRate           float64        `json:"sgstRate"`
+	IGSTRate           float64        `json:"igstRate"`
+	CessRate           float64        `json:"cessRate"`
+	CessNonAdvol       money.Amount   `json:"cessNonAdvol"`
+
+	hsn string // Code as entered, to validate before it is made a number
+}
+
+// ConsignmentValue returns the value of the goods on an invoice, with their
+// tax, which decides whether an e-way bill is required. Lines with a
+// services (SAC) code are left out.
+func ConsignmentValue(invoice *models.Invoice) money.Amount {
+	var value money.Amount
+	for i := range invoice.LineItems {
+		if !gst.IsServiceCode(lineHSNCode(&invoice.LineItems[i])) {
+			value += invoice.LineItems[i].TotalAmount
+		}
+	}
+	return value
+}
+
+// FromInvoice builds the e-way bill of an issued invoice from its seller
+// and buyer snapshots, ship-to address, transport details and goods lines.
+// Line items should have their catalog item preloaded so that product
+// names, units and missing HSN codes come from the catalog.
+func FromInvoice(invoice *models.Invoice) *Bill {
+	seller := invoice.Seller
+	buyer := invoice.Buyer
+	transport := invoice.Transport
+
+	b := &Bill{
+		UserGSTIN:     seller.GSTIN,
+		SupplyType:    SupplyTypeOutward,
+		SubSupplyType: SubSupplyTypeSupply,
+		DocType:       DocTypeTaxInvoice,
+		DocNo:         invoice.InvoiceNumber,
+		DocDate:       invoice.InvoiceDate.Format(DateLayout),
+		TransType:     TransTypeRegular,
+		FromGSTIN:     seller.GSTIN,
+		FromTradeName: partyName(&seller),
+		FromPlace:     strings.TrimSpace(seller.City),
+		FromPincode:   pincode(seller.Pincode),
+		FromStateCode: stateCode(&seller),
+		ToGSTIN:       buyer.GSTIN,
+		ToTradeName:   partyName(&buyer),
+		ToStateCode:   atoi(invoice.PlaceOfSupply),
+
+		TransMode:       TransportModes[transport.Mode],
+		TransDistance:   transport.DistanceKm,
+		TransporterName: transport.TransporterName,
+		TransporterID:   transport.TransporterID,
+		TransDocNo:      transport.DocumentNumber,
+		VehicleNo:       transport.VehicleNumber,
+	}
+	b.ActualFromStateCode = b.FromStateCode
+	b.FromAddress1, b.FromAddress2 = gst.SplitAddress(seller.Address.Address, 120)
+	if b.ToGSTIN == "" {
+		b.ToGSTIN = UnregisteredGSTIN
+	}
+	if transport.DocumentDate != nil {
+		b.TransDocDate = transport.DocumentDate.Format(DateLayout)
+	}
+	switch transport.VehicleType {
+	case constants.VehicleTypeRegular:
+		b.VehicleType = VehicleTypeRegular
+	case constants.VehicleTypeODC:
+		b.VehicleType = VehicleTypeODC
+	}
+
+	// Goods go to the ship-to address when one is given
+	delivery := buyer
+	if invoice.ShipTo.Address != (models.Address{}) {
+		b.TransType = TransTypeBillToShipTo
+		delivery = invoice.ShipTo
+	}
+	b.ToAddress1, b.ToAddress2 = gst.SplitAddress(delivery.Address.Address, 120)
+	b.ToPlace = strings.TrimSpace(delivery.City)
+	b.ToPincode = pincode(delivery.Pincode)
+	b.ActualToStateCode = stateCode(&delivery)
+
+	var largest money.Amount
+	for i := range invoice.LineItems {
+		line := &invoice.LineItems[i]
+		hsn := lineHSNCode(line)
+		if gst.IsServiceCode(hsn) {
+			b.OtherValue += line.TotalAmount
+			continue
+		}
+
+		item := Item{
+			ItemNo:             len(b.Items) + 1,
+			ProductName:        line.Description,
+			ProductDescription: line.Description,
+			HSNCode:            atoi(hsn),
+			Quantity:           line.Quantity,
+			QuantityUnit:       gst.UQC("", hsn),
+			TaxableAmount:      line.Amount,
+			hsn:                hsn,
+		}
+		if line.Item != nil {
+			item.ProductName = line.Item.Name
+			item.QuantityUnit = gst.UQC(line.Item.Unit, hsn)
+		}
+		if invoice.SupplyType == constants.SupplyTypeIntraState {
+			item.CGSTRate = float64(line.GSTRate) / 2
+			item.SGSTRate = float64(line.GSTRate) / 2
+		} else {
+			item.IGSTRate = float64(line.GSTRate)
+		}
+		b.Items = append(b.Items, item)
+
+		b.TotalValue += line.Amount
+		b.CGSTValue += line.CGSTAmount
+		b.SGSTValue += line.SGSTAmount
+		b.IGSTValue += line.IGSTAmount
+		if len(b.Items) == 1 || line.Amount > largest {
+			b.MainHSNCode = item.HSNCode
+			largest = line.Amount
+		}
+	}
+	b.TotalInvoiceValue = invoice.TotalAmount
+	return b
+}
+
+// Validate checks the fields the portal requires and that the values add
+// up. Failures are reported per field, named as in the JSON.
+func (b *Bill) Validate() error {
+	var errs validation.Errors
+
+	if err := validation.ValidateGSTIN(b.UserGSTIN); err != nil {
+		errs.Add("userGstin", err.Error())
+	}
+	if b.FromGSTIN != b.UserGSTIN {
+		errs.Add("fromGstin", "must be the GSTIN of the user generating the e-way bill")
+	}
+	if !docNumberPattern.MatchString(b.DocNo) {
+		errs.Add("docNo", "must be 1 to 16 letters, digits, / or -, not starting with 0, / or -")
+	}
+	if date, err := time.Parse(DateLayout, b.DocDate); err != nil {
+		errs.Add("docDate", "must be a date as DD/MM/YYYY")
+	} else if date.After(time.Now()) {
+		errs.Add("docDate", "cannot be in the future")
+	}
+
+	if b.ToGSTIN != UnregisteredGSTIN {
+		if err := validation.ValidateGSTIN(b.ToGSTIN); err != nil {
+			errs.Add("toGstin", err.Error())
+		} else if b.ToGSTIN == b.FromGSTIN {
+			errs.Add("toGstin", "cannot be the seller's GSTIN")
+		}
+	}
+	checkPlace(&errs, "from", b.FromPincode, b.FromStateCode, b.ActualFromStateCode, b.FromAddress1, b.FromPlace)
+	checkPlace(&errs, "to", b.ToPincode, b.ToStateCode, b.ActualToStateCode, b.ToAddress1, b.ToPlace)
+
+	b.checkTransport(&errs)
+	b.checkItems(&errs)
+	return errs.Err()
+}
+
+// checkPlace checks the address, PIN code and state codes of the dispatch
+// or delivery end of a bill
+func checkPlace(errs *validation.Errors, end string, pin, state, actualState int, address, place string) {
+	if pin < 100000 || pin > 999999 {
+		errs.Add(end+"Pincode", "must be a 6 digit PIN code")
+	}
+	if !gst.IsValidStateCode(fmt.Sprintf("%02d", state)) {
+		errs.Add(end+"StateCode", "must be a valid GST state code")
+	}
+	if !gst.IsValidStateCode(fmt.Sprintf("%02d", actualState)) {
+		errs.Add("actual"+strings.ToUpper(end[:1])+end[1:]+"StateCode", "must be a valid GST state code")
+	}
+	if utf8.RuneCountInString(address) > 120 {
+		errs.Add(end+"Addr1", "must be at most 120 characters")
+	}
+	if utf8.RuneCountInString(place) > 50 {
+		errs.Add(end+"Place", "must be at most 50 characters")
+	}
+}
+
+// checkTransport checks Part B: either the mode with its vehicle or
+// transport document, or a transporter who will enter them
+func (b *Bill) checkTransport(errs *validation.Errors) {
+	if b.TransporterID != "" {
+		if err := validation.ValidateGSTIN(b.TransporterID); err != nil {
+			errs.Add("transporterId", err.Error())
+		}
+	}
+	switch b.TransMode {
+	case 0:
+		if b.TransporterID == "" {
+			errs.Add("transMode", "a transport mode with vehicle or document details, or a transporter ID, is required")
+		}
+	case TransportModes[constants.TransportModeRoad]:
+		if err := validation.ValidateVehicleNumber(b.VehicleNo); err != nil {
+			errs.Add("vehicleNo", err.Error())
+		}
+		if b.VehicleType != VehicleTypeRegular && b.VehicleType != VehicleTypeODC {
+			errs.Add("vehicleType", "must be R or O")
+		}
+	default:
+		if b.TransDocNo == "" {
+			errs.Add("transDocNo", "is required for rail, air and ship transport")
+		}
+		if _, err := time.Parse(DateLayout, b.TransDocDate); err != nil {
+			errs.Add("transDocDate", "is required for rail, air and ship transport")
+		}
+	}
+	if b.TransDistance < 0 || b.TransDistance > constants.MaxTransportDistanceKm {
+		errs.Add("transDistance", fmt.Sprintf("must be between 0 and %d km", constants.MaxTransportDistanceKm))
+	}
+}
+
+// checkItems checks each goods line and that the values add up to the
+// invoice value
+func (b *Bill) checkItems(errs *validation.Errors) {
+	if len(b.Items) == 0 {
+		errs.Add("itemList", "must have at least one line of goods; services do not need an e-way bill")
+	} else if len(b.Items) > MaxItems {
+		errs.Add("itemList", fmt.Sprintf("must have at most %d lines", MaxItems))
+	}
+
+	intraState := b.FromStateCode == b.ToStateCode
+	var taxable money.Amount
+	for i, item := range b.Items {
+		path := fmt.Sprintf("itemList[%d]", i)
+		if item.hsn == "" {
+			errs.Add(path+".hsnCode", "is required; set it on the line or its item")
+		} else if err := validation.ValidateHSN(item.hsn); err != nil {
+			errs.Add(path+".hsnCode", err.Error())
+		}
+		if item.Quantity < 0 {
+			errs.Add(path+".quantity", "cannot be negative")
+		}
+		if !item.TaxableAmount.IsPositive() {
+			errs.Add(path+".taxableAmount", "must be positive")
+		}
+		if intraState && item.IGSTRate != 0 {
+			errs.Add(path+".igstRate", "must be 0 when goods are supplied within the state")
+		} else if !intraState && item.CGSTRate+item.SGSTRate != 0 {
+			errs.Add(path+".cgstRate", "must be 0 when goods are supplied to another state")
+		}
+		taxable += item.TaxableAmount
+	}
+
+	if b.TotalValue != taxable {
+		errs.Add("totalValue", "must equal the taxable amount of the items, "+taxable.String())
+	}
+	parts := b.TotalValue + b.CGSTValue + b.SGSTValue + b.IGSTValue + b.CessValue + b.CessNonAdvolValue + b.OtherValue
+	if (b.TotalInvoiceValue - parts).Abs() > tolerance {
+		errs.Add("totInvValue", "must equal the taxable value, taxes and other value, "+parts.String())
+	}
+}
+
+// lineHSNCode returns the HSN code of a line, falling back to its item's
+func lineHSNCode(line *models.InvoiceLineItem) string {
+	if line.HSNCode == "" && line.Item != nil {
+		return line.Item.HSNCode
+	}
+	return line.HSNCode
+}
+
+// partyName returns the name a party trades under
+func partyName(p *models.PartySnapshot) string {
+	if name := strings.TrimSpace(p.CompanyName); name != "" {
+		return name
+	}
+	return strings.TrimSpace(p.Name)
+}
+
+// stateCode returns the GST state code of a party as a number
+func stateCode(p *models.PartySnapshot) int {
+	if p.StateCode != "" {
+		return atoi(p.StateCode)
+	}
+	return atoi(gst.ResolveStateCode(p.GSTIN, p.State))
+}
+
+// pincode parses a PIN code, returning 0 if it is not a number
+func pincode(pin string) int {
+	return atoi(strings.ReplaceAll(pin, " ", ""))
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
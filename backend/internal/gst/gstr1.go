@@ -0,0 +1,231 @@
+package gst
+
+import (
+	"strings"
+
+	"invoice-generator/internal/money"
+)
+
+// GSTR1Version is the GST offline tool schema version that exported
+// GSTR-1 files declare
+const GSTR1Version = "GST3.1.6"
+
+// B2CLThreshold is the invoice value above which inter-state supplies to
+// unregistered buyers are reported invoice by invoice (B2CL) rather than
+// in the state-wise summary (B2CS)
+var B2CLThreshold = money.FromRupees(100000)
+
+// Supply types and other codes used in GSTR-1
+const (
+	SupplyIntra        = "INTRA"
+	SupplyInter        = "INTER"
+	ReverseChargeNo    = "N"
+	InvoiceTypeRegular = "R"
+	B2CSTypeOther      = "OE" // Not through an e-commerce operator
+	NoteTypeCredit     = "C"
+	NoteTypeDebit      = "D"
+	CDNURTypeB2CL      = "B2CL"
+)
+
+// Document summary (table 13) document types
+const (
+	DocOutwardInvoices = 1
+	DocDebitNotes      = 4
+	DocCreditNotes     = 5
+)
+
+// ValidRates lists the GST rates, in percent, that a line may carry
+var ValidRates = []int{0, 3, 5, 12, 18, 28, 40}
+
+// IsValidRate reports whether rate is a GST rate
+func IsValidRate(rate int) bool {
+	for _, r := range ValidRates {
+		if r == rate {
+			return true
+		}
+	}
+	return false
+}
+
+// GSTR1 is a GSTR-1 return in the JSON format imported by the GST offline
+// tool and the GST portal
+type GSTR1 struct {
+	GSTIN    string      `json:"gstin"`
+	Period   string      `json:"fp"`
+	Version  string      `json:"version"`
+	Hash     string      `json:"hash"`
+	B2B      []B2B       `json:"b2b,omitempty"`
+	B2CL     []B2CL      `json:"b2cl,omitempty"`
+	B2CS     []B2CS      `json:"b2cs,omitempty"`
+	CDNR     []CDNR      `json:"cdnr,omitempty"`
+	CDNUR    []CDNURNote `json:"cdnur,omitempty"`
+	HSN      *HSNSummary `json:"hsn,omitempty"`
+	DocIssue *DocIssue   `json:"doc_issue,omitempty"`
+}
+
+// B2B lists the invoices issued to one registered buyer
+type B2B struct {
+	CTIN     string       `json:"ctin"`
+	Invoices []B2BInvoice `json:"inv"`
+}
+
+// B2BInvoice is an invoice to a registered buyer
+type B2BInvoice struct {
+	Number        string       `json:"inum"`
+	Date          string       `json:"idt"`
+	Value         money.Amount `json:"val"`
+	PlaceOfSupply string       `json:"pos"`
+	ReverseCharge string       `json:"rchrg"`
+	InvoiceType   string       `json:"inv_typ"`
+	Items         []Item       `json:"itms"`
+}
+
+// B2CL lists large inter-state invoices to unregistered buyers in one
+// state
+type B2CL struct {
+	PlaceOfSupply string        `json:"pos"`
+	Invoices      []B2CLInvoice `json:"inv"`
+}
+
+// B2CLInvoice is a large inter-state invoice to an unregistered buyer
+type B2CLInvoice struct {
+	Number string       `json:"inum"`
+	Date   string       `json:"idt"`
+	Value  money.Amount `json:"val"`
+	Items  []Item       `json:"itms"`
+}
+
+// B2CS is the total of supplies to unregistered buyers at one rate in one
+// state, other than those reported in B2CL
+type B2CS struct {
+	SupplyType    string       `json:"sply_ty"`
+	PlaceOfSupply string       `json:"pos"`
+	Type          string       `json:"typ"`
+	TaxableValue  money.Amount `json:"txval"`
+	Rate          int          `json:"rt"`
+	IGST          money.Amount `json:"iamt"`
+	CGST          money.Amount `json:"camt"`
+	SGST          money.Amount `json:"samt"`
+	Cess          money.Amount `json:"csamt"`
+}
+
+// Item is the total of a document's lines at one rate
+type Item struct {
+	Number  int        `json:"num"`
+	Details ItemDetail `json:"itm_det"`
+}
+
+// ItemDetail holds the taxable value and tax of an Item
+type ItemDetail struct {
+	TaxableValue money.Amount `json:"txval"`
+	Rate         int          `json:"rt"`
+	IGST         money.Amount `json:"iamt"`
+	CGST         money.Amount `json:"camt"`
+	SGST         money.Amount `json:"samt"`
+	Cess         money.Amount `json:"csamt"`
+}
+
+// CDNR lists the credit and debit notes issued to one registered buyer
+type CDNR struct {
+	CTIN  string `json:"ctin"`
+	Notes []Note `json:"nt"`
+}
+
+// Note is a credit or debit note issued to a registered buyer
+type Note struct {
+	Type          string       `json:"ntty"`
+	Number        string       `json:"nt_num"`
+	Date          string       `json:"nt_dt"`
+	Value         money.Amount `json:"val"`
+	PlaceOfSupply string       `json:"pos"`
+	ReverseCharge string       `json:"rchrg"`
+	InvoiceType   string       `json:"inv_typ"`
+	Items         []Item       `json:"itms"`
+}
+
+// CDNURNote is a credit or debit note against a B2CL invoice
+type CDNURNote struct {
+	Type          string       `json:"typ"`
+	NoteType      string       `json:"ntty"`
+	Number        string       `json:"nt_num"`
+	Date          string       `json:"nt_dt"`
+	Value         money.Amount `json:"val"`
+	PlaceOfSupply string       `json:"pos"`
+	Items         []Item       `json:"itms"`
+}
+
+// HSNSummary is the HSN-wise summary of outward supplies, reported
+// separately for supplies to registered and unregistered buyers
+type HSNSummary struct {
+	B2B []HSNRow `json:"hsn_b2b"`
+	B2C []HSNRow `json:"hsn_b2c"`
+}
+
+// HSNRow is the total of supplies under one HSN or SAC code at one rate
+type HSNRow struct {
+	Number       int            `json:"num"`
+	HSN          string         `json:"hsn_sc"`
+	Description  string         `json:"desc"`
+	UQC          string         `json:"uqc"`
+	Quantity     money.Quantity `json:"qty"`
+	Rate         int            `json:"rt"`
+	TaxableValue money.Amount   `json:"txval"`
+	IGST         money.Amount   `json:"iamt"`
+	CGST         money.Amount   `json:"camt"`
+	SGST         money.Amount   `json:"samt"`
+	Cess         money.Amount   `json:"csamt"`
+}
+
+// DocIssue is the summary of documents issued in the period (table 13)
+type DocIssue struct {
+	Details []DocDetail `json:"doc_det"`
+}
+
+// DocDetail lists the number ranges issued for one document type
+type DocDetail struct {
+	DocumentType int        `json:"doc_num"`
+	Documents    []DocRange `json:"docs"`
+}
+
+// DocRange is a range of document numbers from one series
+type DocRange struct {
+	Number    int    `json:"num"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Total     int    `json:"totnum"`
+	Cancelled int    `json:"cancel"`
+	NetIssued int    `json:"net_issue"`
+}
+
+// ReturnDate formats a date as used in GST returns, e.g. "05-04-2025"
+const ReturnDate = "02-01-2006"
+
+// IsServiceCode reports whether an HSN code is a services accounting code
+// (SAC), which all start with 99
+func IsServiceCode(hsn string) bool {
+	return strings.HasPrefix(hsn, "99")
+}
+
+// uqcByUnit maps common unit names to GST unit quantity codes
+var uqcByUnit = map[string]string{
+	"pcs": "PCS", "pc": "PCS", "piece": "PCS", "pieces": "PCS",
+	"nos": "NOS", "no": "NOS", "number": "NOS", "numbers": "NOS", "unit": "UNT", "units": "UNT",
+	"kg": "KGS", "kgs": "KGS", "kilogram": "KGS", "g": "GMS", "gm": "GMS", "gms": "GMS", "gram": "GMS",
+	"l": "LTR", "ltr": "LTR", "litre": "LTR", "liter": "LTR", "ml": "MLT",
+	"m": "MTR", "mtr": "MTR", "metre": "MTR", "meter": "MTR", "cm": "CMS", "km": "KME",
+	"sqm": "SQM", "sqft": "SQF", "box": "BOX", "bag": "BAG", "bags": "BAG", "set": "SET", "sets": "SET",
+	"pair": "PRS", "pairs": "PRS", "dozen": "DOZ", "doz": "DOZ", "ton": "TON", "tonne": "TON",
+	"bottle": "BTL", "bottles": "BTL", "can": "CAN", "roll": "ROL", "rolls": "ROL", "pack": "PAC",
+}
+
+// UQC returns the GST unit quantity code for a unit name, "NA" for
+// services, or "OTH" for units without a code
+func UQC(unit, hsn string) string {
+	if IsServiceCode(hsn) {
+		return "NA"
+	}
+	if code, ok := uqcByUnit[strings.ToLower(strings.TrimSpace(unit))]; ok {
+		return code
+	}
+	return "OTH"
+}
@@ -0,0 +1,53 @@
+package gst
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"invoice-generator/internal/constants"
+)
+
+// ReturnPeriod is the tax period a return is filed for: a calendar month,
+// or a quarter of the financial year for taxpayers filing quarterly
+type ReturnPeriod struct {
+	From  time.Time // First day of the period
+	To    time.Time // First day after the period
+	Label string    // As entered, e.g. "2025-04" or "2025-26-Q1"
+}
+
+// ParseReturnPeriod parses a month as "YYYY-MM" (e.g. "2025-04") or a
+// quarter of a financial year as "YYYY-YY-Qn" (e.g. "2025-26-Q1" for April
+// to June 2025)
+func ParseReturnPeriod(period string) (ReturnPeriod, error) {
+	period = strings.ToUpper(strings.TrimSpace(period))
+
+	if month, err := time.Parse("2006-01", period); err == nil {
+		return ReturnPeriod{From: month, To: month.AddDate(0, 1, 0), Label: period}, nil
+	}
+
+	parts := strings.Split(period, "-")
+	if len(parts) == 3 && len(parts[0]) == 4 && len(parts[1]) == 2 && len(parts[2]) == 2 && parts[2][0] == 'Q' {
+		startYear, err1 := strconv.Atoi(parts[0])
+		endYear, err2 := strconv.Atoi(parts[1])
+		quarter := int(parts[2][1] - '0')
+		if err1 == nil && err2 == nil && endYear == (startYear+1)%100 && quarter >= 1 && quarter <= 4 {
+			from := time.Date(startYear, time.Month(constants.FinancialYearStartMonth+3*(quarter-1)), 1, 0, 0, 0, 0, time.UTC)
+			return ReturnPeriod{From: from, To: from.AddDate(0, 3, 0), Label: period}, nil
+		}
+	}
+
+	return ReturnPeriod{}, fmt.Errorf("invalid return period %q: use YYYY-MM for a month or YYYY-YY-Qn for a quarter, e.g. 2025-26-Q1", period)
+}
+
+// Contains reports whether a date falls within the period
+func (p ReturnPeriod) Contains(date time.Time) bool {
+	return !date.Before(p.From) && date.Before(p.To)
+}
+
+// FilingPeriod returns the period in the MMYYYY form used by the GST
+// portal. Quarterly returns are filed under the last month of the quarter.
+func (p ReturnPeriod) FilingPeriod() string {
+	return p.To.AddDate(0, -1, 0).Format("012006")
+}
@@ -0,0 +1,160 @@
+package gst
+
+import (
+	"sort"
+	"strings"
+)
+
+// State is an Indian state or union territory with its GST state code
+type State struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// States lists the state codes used in GSTINs and place of supply, keyed by
+// their two-digit code
+var States = map[string]string{
+	"01": "Jammu and Kashmir",
+	"02": "Himachal Pradesh",
+	"03": "Punjab",
+	"04": "Chandigarh",
+	"05": "Uttarakhand",
+	"06": "Haryana",
+	"07": "Delhi",
+	"08": "Rajasthan",
+	"09": "Uttar Pradesh",
+	"10": "Bihar",
+	"11": "Sikkim",
+	"12": "Arunachal Pradesh",
+	"13": "Nagaland",
+	"14": "Manipur",
+	"15": "Mizoram",
+	"16": "Tripura",
+	"17": "Meghalaya",
+	"18": "Assam",
+	"19": "West Bengal",
+	"20": "Jharkhand",
+	"21": "Odisha",
+	"22": "Chhattisgarh",
+	"23": "Madhya Pradesh",
+	"24": "Gujarat",
+	"25": "Daman and Diu",
+	"26": "Dadra and Nagar Haveli and Daman and Diu",
+	"27": "Maharashtra",
+	"28": "Andhra Pradesh (Old)",
+	"29": "Karnataka",
+	"30": "Goa",
+	"31": "Lakshadweep",
+	"32": "Kerala",
+	"33": "Tamil Nadu",
+	"34": "Puducherry",
+	"35": "Andaman and Nicobar Islands",
+	"36": "Telangana",
+	"37": "Andhra Pradesh",
+	"38": "Ladakh",
+	"96": "Foreign Country",
+	"97": "Other Territory",
+	"99": "Centre Jurisdiction",
+}
+
+// ForeignCountry is the place of supply code of exports
+const ForeignCountry = "96"
+
+// stateAliases maps alternative spellings to state codes
+var stateAliases = map[string]string{
+	"jk":                  "01",
+	"jandk":               "01",
+	"uttaranchal":         "05",
+	"newdelhi":            "07",
+	"nctofdelhi":          "07",
+	"up":                  "09",
+	"orissa":              "21",
+	"dadraandnagarhaveli": "26",
+	"dnhdd":               "26",
+	"pondicherry":         "34",
+	"andamanandnicobar":   "35",
+	"andamannicobar":      "35",
+}
+
+// StateName returns the name of the state with the given code, or an empty
+// string when the code is unknown
+func StateName(code string) string {
+	return States[code]
+}
+
+// IsValidStateCode reports whether code is a known GST state code
+func IsValidStateCode(code string) bool {
+	_, ok := States[code]
+	return ok
+}
+
+// StateList returns all states ordered by code
+func StateList() []State {
+	list := make([]State, 0, len(States))
+	for code, name := range States {
+		list = append(list, State{Code: code, Name: name})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Code < list[j].Code })
+	return list
+}
+
+// StateCodeByName looks up a state code from a state name or code as
+// typically entered in an address, e.g. "Karnataka", "tamil nadu" or "29"
+func StateCodeByName(name string) string {
+	name = strings.TrimSpace(name)
+	if len(name) == 1 && name[0] >= '1' && name[0] <= '9' {
+		name = "0" + name
+	}
+	if IsValidStateCode(name) {
+		return name
+	}
+
+	key := normaliseStateName(name)
+	if key == "" {
+		return ""
+	}
+	if code, ok := stateAliases[key]; ok {
+		return code
+	}
+	for code, stateName := range States {
+		if normaliseStateName(stateName) == key {
+			return code
+		}
+	}
+	return ""
+}
+
+// StateCodeFromGSTIN returns the state code embedded in the first two
+// characters of a GSTIN, or an empty string if it is not a known code
+func StateCodeFromGSTIN(gstin string) string {
+	gstin = strings.TrimSpace(gstin)
+	if len(gstin) < 2 {
+		return ""
+	}
+	if code := gstin[:2]; IsValidStateCode(code) {
+		return code
+	}
+	return ""
+}
+
+// ResolveStateCode determines a party's state code, preferring the code
+// embedded in its GSTIN over the free-text state of its address
+func ResolveStateCode(gstin, state string) string {
+	if code := StateCodeFromGSTIN(gstin); code != "" {
+		return code
+	}
+	return StateCodeByName(state)
+}
+
+// normaliseStateName lower-cases a state name and strips everything but
+// letters so that spacing and punctuation differences don't matter
+func normaliseStateName(name string) string {
+	name = strings.ReplaceAll(strings.ToLower(name), "&", "and")
+	var b strings.Builder
+	for _, r := range name {
+		if r >= 'a' && r <= 'z' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
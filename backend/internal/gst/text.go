@@ -0,0 +1,34 @@
+package gst
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Truncate shortens s to at most n characters. The portals count field
+// lengths in characters, and cutting at a character boundary keeps
+// addresses and names in Indian scripts valid UTF-8.
+func Truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:n])
+}
+
+// SplitAddress splits an address into two lines of at most width
+// characters each, as the IRP and the e-way bill portal take it, breaking
+// the first line at a comma or space where possible. Runs of whitespace
+// are collapsed first.
+func SplitAddress(address string, width int) (string, string) {
+	address = strings.Join(strings.Fields(address), " ")
+	if utf8.RuneCountInString(address) <= width {
+		return address, ""
+	}
+	first := Truncate(address, width)
+	cut := strings.LastIndexAny(first, ", ")
+	if cut <= 0 {
+		cut = len(first)
+	}
+	return strings.TrimSpace(address[:cut]), Truncate(strings.Trim(address[cut:], ", "), width)
+}
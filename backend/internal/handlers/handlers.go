@@ -1,14 +1,24 @@
 package handlers
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"invoice-generator/internal/bankstatement"
 	"invoice-generator/internal/constants"
+	"invoice-generator/internal/ewaybill"
+	"invoice-generator/internal/gst"
 	"invoice-generator/internal/models"
 	"invoice-generator/internal/services"
+	"invoice-generator/internal/validation"
 )
 
 // Handlers holds all the service dependencies
@@ -17,6 +27,22 @@ type Handlers struct {
 	invoiceService   *services.InvoiceService
 	dashboardService *services.DashboardService
 	catalogService   *services.CatalogService
+	pdfService       *services.PDFService
+	numberingService *services.NumberingService
+	noteService      *services.AdjustmentNoteService
+	revisionService  *services.InvoiceRevisionService
+	recurringService *services.RecurringInvoiceService
+	quotationService *services.QuotationService
+	partyService     *services.PartyService
+	gstr1Service     *services.GSTR1Service
+	reportService    *services.ReportService
+	einvoiceService  *services.EInvoiceService
+	ewayBillService  *services.EWayBillService
+	upiService       *services.UPIService
+	paymentService   *services.PaymentService
+	creditService    *services.CreditService
+	receiptService   *services.ReceiptService
+	bankService      *services.BankReconciliationService
 }
 
 // NewHandlers creates a new handlers instance
@@ -25,12 +51,44 @@ func NewHandlers(
 	invoiceService *services.InvoiceService,
 	dashboardService *services.DashboardService,
 	catalogService *services.CatalogService,
+	pdfService *services.PDFService,
+	numberingService *services.NumberingService,
+	noteService *services.AdjustmentNoteService,
+	revisionService *services.InvoiceRevisionService,
+	recurringService *services.RecurringInvoiceService,
+	quotationService *services.QuotationService,
+	partyService *services.PartyService,
+	gstr1Service *services.GSTR1Service,
+	reportService *services.ReportService,
+	einvoiceService *services.EInvoiceService,
+	ewayBillService *services.EWayBillService,
+	paymentService *services.PaymentService,
+	creditService *services.CreditService,
+	receiptService *services.ReceiptService,
+	bankService *services.BankReconciliationService,
+	upiService *services.UPIService,
 ) *Handlers {
 	return &Handlers{
 		userService:      userService,
 		invoiceService:   invoiceService,
 		dashboardService: dashboardService,
 		catalogService:   catalogService,
+		pdfService:       pdfService,
+		numberingService: numberingService,
+		noteService:      noteService,
+		revisionService:  revisionService,
+		recurringService: recurringService,
+		quotationService: quotationService,
+		partyService:     partyService,
+		gstr1Service:     gstr1Service,
+		reportService:    reportService,
+		einvoiceService:  einvoiceService,
+		ewayBillService:  ewayBillService,
+		paymentService:   paymentService,
+		creditService:    creditService,
+		receiptService:   receiptService,
+		bankService:      bankService,
+		upiService:       upiService,
 	}
 }
 
@@ -45,7 +103,7 @@ func (h *Handlers) Register(c *gin.Context) {
 	}
 
 	if err := h.userService.Register(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, errorBody(err))
 		return
 	}
 
@@ -109,7 +167,7 @@ func (h *Handlers) UpdateProfile(c *gin.Context) {
 	}
 
 	if err := h.userService.UpdateProfile(userID.(uint), &updateData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, errorBody(err))
 		return
 	}
 
@@ -181,13 +239,92 @@ func (h *Handlers) CreateInvoice(c *gin.Context) {
 	userID, _ := c.Get("user_id")
 
 	if err := h.invoiceService.CreateInvoice(&invoiceData, userID.(uint)); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, errorBody(err))
 		return
 	}
 
 	c.JSON(http.StatusCreated, gin.H{"invoice": invoiceData})
 }
 
+// UpdateInvoice replaces the contents of a draft invoice
+func (h *Handlers) UpdateInvoice(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invoice ID"})
+		return
+	}
+
+	invoice, _ := c.Get("invoice")
+	invoiceData := invoice.(models.Invoice)
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	updated, err := h.invoiceService.UpdateInvoice(uint(id), &invoiceData, userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorBody(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invoice": updated})
+}
+
+// IssueInvoice issues a draft invoice and allocates its number
+func (h *Handlers) IssueInvoice(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invoice ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	invoice, err := h.invoiceService.IssueInvoice(uint(id), userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorBody(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invoice": invoice})
+}
+
+// VoidInvoice discards a draft invoice while keeping its record
+func (h *Handlers) VoidInvoice(c *gin.Context) {
+	h.changeInvoiceStatus(c, h.invoiceService.VoidInvoice)
+}
+
+// CancelInvoice cancels an issued invoice while keeping its record
+func (h *Handlers) CancelInvoice(c *gin.Context) {
+	h.changeInvoiceStatus(c, h.invoiceService.CancelInvoice)
+}
+
+// changeInvoiceStatus binds the reason for a void or cancel action and
+// applies it
+func (h *Handlers) changeInvoiceStatus(c *gin.Context, change func(id uint, reason string, userID uint, isAdmin bool) (*models.Invoice, error)) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invoice ID"})
+		return
+	}
+
+	var request models.StatusChangeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	invoice, err := change(uint(id), request.Reason, userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invoice": invoice})
+}
+
 // GetInvoices returns invoices with pagination
 func (h *Handlers) GetInvoices(c *gin.Context) {
 	userID, _ := c.Get("user_id")
@@ -196,7 +333,7 @@ func (h *Handlers) GetInvoices(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(constants.DefaultPageLimit)))
 
-	invoices, total, err := h.invoiceService.GetInvoices(userID.(uint), isAdmin.(bool), page, limit)
+	invoices, total, err := h.invoiceService.GetInvoices(userID.(uint), isAdmin.(bool), c.Query("status"), page, limit)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invoices"})
 		return
@@ -230,6 +367,151 @@ func (h *Handlers) GetInvoice(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"invoice": invoice})
 }
 
+// GetInvoicePDF renders a stored invoice as a PDF download
+func (h *Handlers) GetInvoicePDF(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invoice ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	invoice, err := h.invoiceService.GetInvoice(uint(id), userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	document, err := h.pdfService.RenderInvoice(invoice)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render invoice PDF"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pdf"`, pdfFileName(invoice.InvoiceNumber)))
+	c.Data(http.StatusOK, "application/pdf", document)
+}
+
+// GetUPIPaymentLink returns the UPI payment request for an invoice's
+// amount due
+func (h *Handlers) GetUPIPaymentLink(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invoice ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	link, err := h.upiService.GetPaymentLink(uint(id), userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"upi": link})
+}
+
+// GetUPIQRCode returns the UPI payment request for an invoice's amount due
+// as a PNG (default) or SVG QR code
+func (h *Handlers) GetUPIQRCode(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invoice ID"})
+		return
+	}
+
+	format := strings.ToLower(c.DefaultQuery("format", "png"))
+	if format != "png" && format != "svg" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be png or svg"})
+		return
+	}
+	module, err := strconv.Atoi(c.DefaultQuery("module", strconv.Itoa(constants.DefaultQRModuleSize)))
+	if err != nil || module < 1 || module > constants.MaxQRModuleSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("module must be between 1 and %d", constants.MaxQRModuleSize)})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	code, err := h.upiService.GetQRCode(uint(id), userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if format == "svg" {
+		c.Data(http.StatusOK, "image/svg+xml", code.SVG(module))
+		return
+	}
+	data, err := code.PNG(module)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render QR code"})
+		return
+	}
+	c.Data(http.StatusOK, "image/png", data)
+}
+
+// GetInvoiceRevisions returns the revision history of an invoice
+func (h *Handlers) GetInvoiceRevisions(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invoice ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	revisions, err := h.revisionService.GetRevisions(uint(id), userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"revisions": revisions})
+}
+
+// DiffInvoiceRevisions returns the field-level differences between two
+// revisions of an invoice
+func (h *Handlers) DiffInvoiceRevisions(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invoice ID"})
+		return
+	}
+
+	from, err := strconv.Atoi(c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from revision"})
+		return
+	}
+	to, err := strconv.Atoi(c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to revision"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	changes, err := h.revisionService.DiffRevisions(uint(id), from, to, userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":    from,
+		"to":      to,
+		"changes": changes,
+	})
+}
+
 // AddPayment adds a payment to an invoice
 func (h *Handlers) AddPayment(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -271,31 +553,1484 @@ func (h *Handlers) DeleteInvoice(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Invoice deleted successfully"})
 }
 
-// Dashboard Handlers
+// Payment Handlers
+
+// AddRefund records a refund to the buyer of an invoice
+func (h *Handlers) AddRefund(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invoice ID"})
+		return
+	}
+
+	var refund models.Payment
+	if err := c.ShouldBindJSON(&refund); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-// GetDashboard returns dashboard statistics
-func (h *Handlers) GetDashboard(c *gin.Context) {
 	userID, _ := c.Get("user_id")
 	isAdmin, _ := c.Get("is_admin")
 
-	stats, err := h.dashboardService.GetDashboardStats(userID.(uint), isAdmin.(bool))
+	if err := h.paymentService.AddRefund(uint(id), &refund, userID.(uint), isAdmin.(bool)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"payment": refund})
+}
+
+// GetPaymentEvents returns the audit trail of an invoice's payments
+func (h *Handlers) GetPaymentEvents(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch dashboard stats"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invoice ID"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"dashboard": stats})
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	events, err := h.paymentService.GetPaymentEvents(uint(id), userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"events": events})
 }
 
-// GetAdminStats returns admin dashboard statistics
-func (h *Handlers) GetAdminStats(c *gin.Context) {
-	stats, err := h.dashboardService.GetAdminStats()
+// DepositCheque marks a received cheque as deposited
+func (h *Handlers) DepositCheque(c *gin.Context) {
+	h.changePaymentStatus(c, h.paymentService.DepositCheque)
+}
+
+// ClearCheque marks a deposited cheque as cleared
+func (h *Handlers) ClearCheque(c *gin.Context) {
+	h.changePaymentStatus(c, h.paymentService.ClearCheque)
+}
+
+// ReversePayment reverses a payment or refund recorded by mistake
+func (h *Handlers) ReversePayment(c *gin.Context) {
+	h.changePaymentStatus(c, h.paymentService.ReversePayment)
+}
+
+// changePaymentStatus binds the date and reason of a change to a payment
+// and applies it
+func (h *Handlers) changePaymentStatus(c *gin.Context, change func(id uint, request *models.PaymentStatusRequest, userID uint, isAdmin bool) (*models.Payment, error)) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch admin stats"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment ID"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"stats": stats})
+	var request models.PaymentStatusRequest
+	if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	payment, err := change(uint(id), &request, userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"payment": payment})
+}
+
+// BounceCheque records a bounced cheque, reopening its invoice and
+// optionally raising a debit note for the bank charges
+func (h *Handlers) BounceCheque(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment ID"})
+		return
+	}
+
+	var request models.ChequeBounceRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	payment, err := h.paymentService.BounceCheque(uint(id), &request, userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"payment": payment})
+}
+
+// Receipt Handlers
+
+// CreateReceipt records a lump-sum receipt and allocates it to invoices
+func (h *Handlers) CreateReceipt(c *gin.Context) {
+	var receipt models.Receipt
+	if err := c.ShouldBindJSON(&receipt); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	if err := h.receiptService.CreateReceipt(&receipt, userID.(uint)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"receipt": receipt})
+}
+
+// GetReceipts returns receipts with pagination
+func (h *Handlers) GetReceipts(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(constants.DefaultPageLimit)))
+
+	receipts, total, err := h.receiptService.GetReceipts(userID.(uint), isAdmin.(bool), page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch receipts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"receipts": receipts,
+		"total":    total,
+		"page":     page,
+		"limit":    limit,
+	})
+}
+
+// GetReceipt returns a single receipt with its allocations
+func (h *Handlers) GetReceipt(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid receipt ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	receipt, err := h.receiptService.GetReceipt(uint(id), userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"receipt": receipt})
+}
+
+// UpdateReceiptAllocations replaces the allocation of a receipt
+func (h *Handlers) UpdateReceiptAllocations(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid receipt ID"})
+		return
+	}
+
+	var request models.AllocationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	receipt, err := h.receiptService.UpdateAllocations(uint(id), &request, userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"receipt": receipt})
+}
+
+// Bank Reconciliation Handlers
+
+// ImportBankStatement imports a bank statement file sent as multipart form
+// data and proposes matches for its transactions
+func (h *Handlers) ImportBankStatement(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Statement file is required"})
+		return
+	}
+	if file.Size > bankstatement.MaxFileSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Statement file is too large"})
+		return
+	}
+
+	var mapping *bankstatement.CSVMapping
+	if value := c.PostForm("mapping"); value != "" {
+		mapping = &bankstatement.CSVMapping{}
+		if err := json.Unmarshal([]byte(value), mapping); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid column mapping"})
+			return
+		}
+	}
+
+	windowDays, ok := matchWindow(c)
+	if !ok {
+		return
+	}
+
+	reader, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read statement file"})
+		return
+	}
+	defer reader.Close()
+
+	userID, _ := c.Get("user_id")
+
+	statement := models.BankStatement{Format: c.PostForm("format"), FileName: file.Filename}
+	if err := h.bankService.ImportStatement(&statement, reader, mapping, windowDays, userID.(uint)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"bank_statement": statement})
+}
+
+// GetBankStatements returns imported bank statements with pagination
+func (h *Handlers) GetBankStatements(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(constants.DefaultPageLimit)))
+
+	statements, total, err := h.bankService.GetStatements(userID.(uint), isAdmin.(bool), page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bank statements"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"bank_statements": statements,
+		"total":           total,
+		"page":            page,
+		"limit":           limit,
+	})
+}
+
+// GetBankStatement returns a bank statement with its transactions
+func (h *Handlers) GetBankStatement(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bank statement ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	statement, err := h.bankService.GetStatement(uint(id), userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"bank_statement": statement})
+}
+
+// ReconcileBankStatement proposes matches again for a statement's
+// unreconciled transactions
+func (h *Handlers) ReconcileBankStatement(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bank statement ID"})
+		return
+	}
+
+	windowDays, ok := matchWindow(c)
+	if !ok {
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	statement, err := h.bankService.Reconcile(uint(id), windowDays, userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"bank_statement": statement})
+}
+
+// GetBankTransactions returns bank transactions with pagination,
+// optionally filtered by status
+func (h *Handlers) GetBankTransactions(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(constants.DefaultPageLimit)))
+
+	transactions, total, err := h.bankService.GetTransactions(userID.(uint), isAdmin.(bool), c.Query("status"), page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bank transactions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"bank_transactions": transactions,
+		"total":             total,
+		"page":              page,
+		"limit":             limit,
+	})
+}
+
+// ConfirmBankMatch reconciles a bank transaction with its proposed match
+// or the one given
+func (h *Handlers) ConfirmBankMatch(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bank transaction ID"})
+		return
+	}
+
+	var request models.BankMatchRequest
+	if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	transaction, err := h.bankService.ConfirmMatch(uint(id), &request, userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"bank_transaction": transaction})
+}
+
+// IgnoreBankTransaction sets aside a bank transaction that settles no
+// invoice
+func (h *Handlers) IgnoreBankTransaction(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bank transaction ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	transaction, err := h.bankService.IgnoreTransaction(uint(id), userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"bank_transaction": transaction})
+}
+
+// Customer Credit Handlers
+
+// AddAdvance records an advance received from a party before invoicing
+func (h *Handlers) AddAdvance(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid party ID"})
+		return
+	}
+
+	var entry models.CreditEntry
+	if err := c.ShouldBindJSON(&entry); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	if err := h.creditService.AddAdvance(uint(id), &entry, userID.(uint), isAdmin.(bool)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"credit_entry": entry})
+}
+
+// GetCreditStatement returns a party's credit balance and entries
+func (h *Handlers) GetCreditStatement(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid party ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	statement, err := h.creditService.GetStatement(uint(id), userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"credit": statement})
+}
+
+// GetCreditBalances lists the parties with a credit balance
+func (h *Handlers) GetCreditBalances(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	balances, err := h.creditService.GetBalances(userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch credit balances"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"credit_balances": balances})
+}
+
+// ReverseAdvance reverses an advance recorded by mistake
+func (h *Handlers) ReverseAdvance(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid credit entry ID"})
+		return
+	}
+
+	var request models.StatusChangeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	reversal, err := h.creditService.ReverseAdvance(uint(id), request.Reason, userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"credit_entry": reversal})
+}
+
+// ApplyCredit pays an invoice from its party's credit balance
+func (h *Handlers) ApplyCredit(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invoice ID"})
+		return
+	}
+
+	var request models.ApplyCreditRequest
+	if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	payment, err := h.creditService.ApplyCredit(uint(id), request.Amount, userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"payment": payment})
+}
+
+// E-invoice Handlers
+
+// GetEInvoice returns the e-invoice JSON of an issued invoice as it would
+// be uploaded to the IRP, with any schema violations that need fixing
+// first. With download=true the JSON alone is returned as an attachment.
+func (h *Handlers) GetEInvoice(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invoice ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	payload, problems, err := h.einvoiceService.GetPayload(uint(id), userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if c.Query("download") == "true" {
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="EINV_%s.json"`, pdfFileName(payload.Document.Number)))
+		c.JSON(http.StatusOK, payload)
+		return
+	}
+
+	if problems == nil {
+		problems = validation.Errors{}
+	}
+	c.JSON(http.StatusOK, gin.H{"einvoice": payload, "errors": problems})
+}
+
+// GenerateIRN registers an issued invoice with the IRP and returns the
+// invoice with its IRN, acknowledgement and signed QR code
+func (h *Handlers) GenerateIRN(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invoice ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	invoice, err := h.einvoiceService.GenerateIRN(c.Request.Context(), uint(id), userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorBody(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invoice": invoice})
+}
+
+// CancelIRN cancels the IRN of an invoice on the IRP so that the invoice
+// can be cancelled
+func (h *Handlers) CancelIRN(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invoice ID"})
+		return
+	}
+
+	var request models.IRNCancelRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	invoice, err := h.einvoiceService.CancelIRN(c.Request.Context(), uint(id), &request, userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invoice": invoice})
+}
+
+// E-way Bill Handlers
+
+// UpdateTransport sets how the goods on a draft or issued invoice are
+// moved, for its e-way bill
+func (h *Handlers) UpdateTransport(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invoice ID"})
+		return
+	}
+
+	var transport models.TransportDetails
+	if err := c.ShouldBindJSON(&transport); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	invoice, err := h.invoiceService.UpdateTransport(uint(id), &transport, userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorBody(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invoice": invoice})
+}
+
+// GetEWayBill returns the e-way bill of an issued invoice in the bulk
+// generation format, with any missing or invalid fields that need fixing
+// first. With download=true a bulk file holding just this bill is returned
+// as an attachment.
+func (h *Handlers) GetEWayBill(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invoice ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	bill, problems, err := h.ewayBillService.GetEWayBill(uint(id), userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if c.Query("download") == "true" {
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="EWB_%s.json"`, pdfFileName(bill.DocNo)))
+		c.JSON(http.StatusOK, ewaybill.BulkFile{Version: ewaybill.Version, Bills: []ewaybill.Bill{*bill}})
+		return
+	}
+
+	if problems == nil {
+		problems = validation.Errors{}
+	}
+	c.JSON(http.StatusOK, gin.H{"eway_bill": bill, "errors": problems})
+}
+
+// ExportEWayBills exports the current user's issued invoices dated from
+// and to (YYYY-MM-DD, inclusive, defaulting to the current month) that need
+// an e-way bill, as a bulk generation file. Admins may export another
+// seller's invoices with seller_id. With download=true only the file is
+// sent; otherwise invoices left out of it are listed under errors.
+func (h *Handlers) ExportEWayBills(c *gin.Context) {
+	from, to, ok := reportDates(c)
+	if !ok {
+		return
+	}
+	sellerID, ok := returnSellerID(c)
+	if !ok {
+		return
+	}
+
+	file, returnErrors, err := h.ewayBillService.ExportEWayBills(sellerID, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export e-way bills"})
+		return
+	}
+
+	if c.Query("download") == "true" {
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="EWB_%s_%s.json"`,
+			from.Format("20060102"), to.Format("20060102")))
+		c.JSON(http.StatusOK, file)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"eway_bills": file, "errors": returnErrors})
+}
+
+// Credit and Debit Note Handlers
+
+// CreateCreditNote issues a credit note against an invoice
+func (h *Handlers) CreateCreditNote(c *gin.Context) {
+	h.createAdjustmentNote(c, constants.NoteTypeCredit)
+}
+
+// CreateDebitNote issues a debit note against an invoice
+func (h *Handlers) CreateDebitNote(c *gin.Context) {
+	h.createAdjustmentNote(c, constants.NoteTypeDebit)
+}
+
+// createAdjustmentNote binds and creates a note of the given type
+func (h *Handlers) createAdjustmentNote(c *gin.Context, noteType string) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invoice ID"})
+		return
+	}
+
+	var note models.AdjustmentNote
+	if err := c.ShouldBindJSON(&note); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	note.NoteType = noteType
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	if err := h.noteService.CreateNote(uint(id), &note, userID.(uint), isAdmin.(bool)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"note": note})
+}
+
+// GetAdjustmentNotes returns credit and debit notes with pagination
+func (h *Handlers) GetAdjustmentNotes(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(constants.DefaultPageLimit)))
+
+	notes, total, err := h.noteService.GetNotes(userID.(uint), isAdmin.(bool), c.Query("type"), page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"notes": notes,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+// GetAdjustmentNote returns a single credit or debit note
+func (h *Handlers) GetAdjustmentNote(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid note ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	note, err := h.noteService.GetNote(uint(id), userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"note": note})
+}
+
+// Number Series Handlers
+
+// GetNumberSeries returns the current user's document number series
+func (h *Handlers) GetNumberSeries(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	series, err := h.numberingService.GetSeries(userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch number series"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"series": series})
+}
+
+// CreateNumberSeries creates a document number series
+func (h *Handlers) CreateNumberSeries(c *gin.Context) {
+	var series models.NumberSeries
+	if err := c.ShouldBindJSON(&series); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	if err := h.numberingService.CreateSeries(userID.(uint), &series); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"series": series})
+}
+
+// UpdateNumberSeries updates the pattern or default flag of a series
+func (h *Handlers) UpdateNumberSeries(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid series ID"})
+		return
+	}
+
+	var updateData models.NumberSeries
+	if err := c.ShouldBindJSON(&updateData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	series, err := h.numberingService.UpdateSeries(uint(id), userID.(uint), &updateData)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"series": series})
+}
+
+// Quotation Handlers
+
+// GetQuotations returns quotations with pagination
+func (h *Handlers) GetQuotations(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(constants.DefaultPageLimit)))
+
+	quotations, total, err := h.quotationService.GetQuotations(userID.(uint), isAdmin.(bool), c.Query("status"), page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch quotations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"quotations": quotations,
+		"total":      total,
+		"page":       page,
+		"limit":      limit,
+	})
+}
+
+// GetQuotation returns a single quotation
+func (h *Handlers) GetQuotation(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quotation ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	quotation, err := h.quotationService.GetQuotation(uint(id), userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"quotation": quotation})
+}
+
+// CreateQuotation creates a new quotation
+func (h *Handlers) CreateQuotation(c *gin.Context) {
+	var quotation models.Quotation
+	if err := c.ShouldBindJSON(&quotation); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	if err := h.quotationService.CreateQuotation(&quotation, userID.(uint)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"quotation": quotation})
+}
+
+// AcceptQuotation marks a quotation as accepted
+func (h *Handlers) AcceptQuotation(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quotation ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	quotation, err := h.quotationService.AcceptQuotation(uint(id), userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"quotation": quotation})
+}
+
+// RejectQuotation marks a quotation as rejected with a reason
+func (h *Handlers) RejectQuotation(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quotation ID"})
+		return
+	}
+
+	var req models.StatusChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	quotation, err := h.quotationService.RejectQuotation(uint(id), req.Reason, userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"quotation": quotation})
+}
+
+// ConvertQuotation creates an invoice from a quotation, in full or for the
+// requested quantities
+func (h *Handlers) ConvertQuotation(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quotation ID"})
+		return
+	}
+
+	var req models.QuotationConversionRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	invoice, err := h.quotationService.ConvertToInvoice(uint(id), &req, userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"invoice": invoice})
+}
+
+// Recurring Invoice Handlers
+
+// GetRecurringInvoices returns the current user's recurring invoice schedules
+func (h *Handlers) GetRecurringInvoices(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(constants.DefaultPageLimit)))
+
+	schedules, total, err := h.recurringService.GetSchedules(userID.(uint), isAdmin.(bool), page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch recurring invoices"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recurring_invoices": schedules,
+		"total":              total,
+		"page":               page,
+		"limit":              limit,
+	})
+}
+
+// GetRecurringInvoice returns a single recurring invoice schedule
+func (h *Handlers) GetRecurringInvoice(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	schedule, err := h.recurringService.GetSchedule(uint(id), userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recurring_invoice": schedule})
+}
+
+// CreateRecurringInvoice creates a recurring invoice schedule
+func (h *Handlers) CreateRecurringInvoice(c *gin.Context) {
+	var schedule models.RecurringInvoice
+	if err := c.ShouldBindJSON(&schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	if err := h.recurringService.CreateSchedule(&schedule, userID.(uint)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"recurring_invoice": schedule})
+}
+
+// UpdateRecurringInvoice replaces the template and rule of a schedule
+func (h *Handlers) UpdateRecurringInvoice(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule ID"})
+		return
+	}
+
+	var updateData models.RecurringInvoice
+	if err := c.ShouldBindJSON(&updateData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	schedule, err := h.recurringService.UpdateSchedule(uint(id), &updateData, userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recurring_invoice": schedule})
+}
+
+// PauseRecurringInvoice pauses a schedule
+func (h *Handlers) PauseRecurringInvoice(c *gin.Context) {
+	h.changeScheduleStatus(c, h.recurringService.PauseSchedule)
+}
+
+// ResumeRecurringInvoice resumes a paused schedule
+func (h *Handlers) ResumeRecurringInvoice(c *gin.Context) {
+	h.changeScheduleStatus(c, h.recurringService.ResumeSchedule)
+}
+
+// changeScheduleStatus handles the shared request flow of pausing and
+// resuming a schedule
+func (h *Handlers) changeScheduleStatus(c *gin.Context, change func(id uint, userID uint, isAdmin bool) (*models.RecurringInvoice, error)) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	schedule, err := change(uint(id), userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recurring_invoice": schedule})
+}
+
+// DeleteRecurringInvoice deletes a schedule; generated invoices are kept
+func (h *Handlers) DeleteRecurringInvoice(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	if err := h.recurringService.DeleteSchedule(uint(id), userID.(uint), isAdmin.(bool)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Recurring invoice deleted successfully"})
+}
+
+// GetRecurringInvoiceRuns returns the generation history of a schedule
+func (h *Handlers) GetRecurringInvoiceRuns(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	runs, err := h.recurringService.GetRuns(uint(id), userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"runs": runs})
+}
+
+// PreviewRecurringInvoice lists the next dates a stored schedule will
+// generate invoices on (dry run)
+func (h *Handlers) PreviewRecurringInvoice(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule ID"})
+		return
+	}
+
+	count, ok := previewCount(c)
+	if !ok {
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	dates, err := h.recurringService.PreviewSchedule(uint(id), count, userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"dates": dates})
+}
+
+// PreviewRecurringRule lists the first dates an unsaved schedule would
+// generate invoices on (dry run)
+func (h *Handlers) PreviewRecurringRule(c *gin.Context) {
+	var schedule models.RecurringInvoice
+	if err := c.ShouldBindJSON(&schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	count, ok := previewCount(c)
+	if !ok {
+		return
+	}
+
+	dates, err := h.recurringService.PreviewRule(&schedule, count)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"dates": dates})
+}
+
+// RunRecurringInvoices generates all due recurring invoices now (admin only)
+func (h *Handlers) RunRecurringInvoices(c *gin.Context) {
+	generated, err := h.recurringService.RunDueSchedules(time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to run recurring invoices"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"generated": generated})
+}
+
+// previewCount reads the number of dates to preview from the count query
+// parameter
+func previewCount(c *gin.Context) (int, bool) {
+	count, err := strconv.Atoi(c.DefaultQuery("count", strconv.Itoa(constants.DefaultPreviewCount)))
+	if err != nil || count < 1 || count > constants.MaxPreviewCount {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("count must be between 1 and %d", constants.MaxPreviewCount)})
+		return 0, false
+	}
+	return count, true
+}
+
+// Party Handlers
+
+// GetParties returns the current user's customers and vendors
+func (h *Handlers) GetParties(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(constants.DefaultPageLimit)))
+	partyType := c.Query("type")
+	search := c.Query("search")
+
+	parties, total, err := h.partyService.GetParties(userID.(uint), isAdmin.(bool), partyType, search, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch parties"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"parties": parties,
+		"total":   total,
+		"page":    page,
+		"limit":   limit,
+	})
+}
+
+// GetParty returns a single party
+func (h *Handlers) GetParty(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid party ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	party, err := h.partyService.GetParty(uint(id), userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"party": party})
+}
+
+// CreateParty creates a customer or vendor
+func (h *Handlers) CreateParty(c *gin.Context) {
+	var party models.Party
+	if err := c.ShouldBindJSON(&party); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	if err := h.partyService.CreateParty(&party, userID.(uint)); err != nil {
+		c.JSON(http.StatusBadRequest, errorBody(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"party": party})
+}
+
+// UpdateParty replaces the details and contacts of a party
+func (h *Handlers) UpdateParty(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid party ID"})
+		return
+	}
+
+	var updateData models.Party
+	if err := c.ShouldBindJSON(&updateData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	party, err := h.partyService.UpdateParty(uint(id), &updateData, userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorBody(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"party": party})
+}
+
+// DeleteParty deletes a party that has no documents
+func (h *Handlers) DeleteParty(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid party ID"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	if err := h.partyService.DeleteParty(uint(id), userID.(uint), isAdmin.(bool)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Party deleted successfully"})
+}
+
+// Dashboard Handlers
+
+// GST Return Handlers
+
+// GetGSTR1 exports the current user's GSTR-1 return for a month or quarter.
+// Admins may export another seller's return with seller_id. With
+// download=true only the return is sent, as a file for the GST offline
+// tool; otherwise documents left out of it are listed under errors.
+func (h *Handlers) GetGSTR1(c *gin.Context) {
+	period, err := gst.ParseReturnPeriod(c.Query("period"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	sellerID, ok := returnSellerID(c)
+	if !ok {
+		return
+	}
+
+	gstr1, returnErrors, err := h.gstr1Service.GenerateGSTR1(sellerID, period)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if c.Query("download") == "true" {
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="GSTR1_%s_%s.json"`, gstr1.GSTIN, gstr1.Period))
+		c.JSON(http.StatusOK, gstr1)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"gstr1": gstr1, "errors": returnErrors})
+}
+
+// GetGSTR3B returns the current user's GSTR-3B summary for a period, as
+// JSON or with format=csv as a CSV download. Admins may view another
+// seller's summary with seller_id.
+func (h *Handlers) GetGSTR3B(c *gin.Context) {
+	period, err := gst.ParseReturnPeriod(c.Query("period"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	sellerID, ok := returnSellerID(c)
+	if !ok {
+		return
+	}
+
+	summary, err := h.reportService.GetGSTR3BSummary(sellerID, period)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if c.Query("format") == "csv" {
+		var buf bytes.Buffer
+		if err := services.WriteGSTR3BCSV(&buf, summary); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export GSTR-3B summary"})
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="GSTR3B_%s.csv"`, summary.Period))
+		c.Data(http.StatusOK, "text/csv", buf.Bytes())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"gstr3b": summary})
+}
+
+// GetHSNSummary returns the current user's HSN/SAC-wise summary for the
+// dates from and to (YYYY-MM-DD, inclusive), defaulting to the current
+// month. Admins may view another seller's summary with seller_id.
+func (h *Handlers) GetHSNSummary(c *gin.Context) {
+	from, to, ok := reportDates(c)
+	if !ok {
+		return
+	}
+	sellerID, ok := returnSellerID(c)
+	if !ok {
+		return
+	}
+
+	summary, err := h.reportService.GetHSNSummary(sellerID, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build HSN summary"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"hsn_summary": summary})
+}
+
+// reportDates parses the from and to dates (YYYY-MM-DD, inclusive) of a
+// report, defaulting to the current month up to today
+func reportDates(c *gin.Context) (time.Time, time.Time, bool) {
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	var err error
+	if value := c.Query("from"); value != "" {
+		if from, err = time.Parse("2006-01-02", value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date, use YYYY-MM-DD"})
+			return from, to, false
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		if to, err = time.Parse("2006-01-02", value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date, use YYYY-MM-DD"})
+			return from, to, false
+		}
+	}
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to date cannot be before from date"})
+		return from, to, false
+	}
+	return from, to, true
+}
+
+// matchWindow reads the number of days either side of a bank transaction
+// in which to look for matching payments and receipts
+func matchWindow(c *gin.Context) (int, bool) {
+	windowDays, err := strconv.Atoi(c.DefaultQuery("window_days", strconv.Itoa(constants.DefaultMatchWindowDays)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid window_days"})
+		return 0, false
+	}
+	return windowDays, true
+}
+
+// returnSellerID returns the seller whose return is requested: the current
+// user, or for admins the seller given by seller_id
+func returnSellerID(c *gin.Context) (uint, bool) {
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	sellerParam := c.Query("seller_id")
+	if sellerParam == "" {
+		return userID.(uint), true
+	}
+	if !isAdmin.(bool) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can view other sellers' returns"})
+		return 0, false
+	}
+	sellerID, err := strconv.ParseUint(sellerParam, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid seller ID"})
+		return 0, false
+	}
+	return uint(sellerID), true
+}
+
+// GetDashboard returns dashboard statistics
+func (h *Handlers) GetDashboard(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	isAdmin, _ := c.Get("is_admin")
+
+	stats, err := h.dashboardService.GetDashboardStats(userID.(uint), isAdmin.(bool))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch dashboard stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"dashboard": stats})
+}
+
+// GetAdminStats returns admin dashboard statistics
+func (h *Handlers) GetAdminStats(c *gin.Context) {
+	stats, err := h.dashboardService.GetAdminStats()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch admin stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"stats": stats})
+}
+
+// errorBody builds the JSON body of an error response. Validation errors
+// also list each failing field so that clients can show the message next
+// to it.
+func errorBody(err error) gin.H {
+	body := gin.H{"error": err.Error()}
+	var fieldErrs validation.Errors
+	if errors.As(err, &fieldErrs) {
+		body["fields"] = fieldErrs
+	}
+	return body
+}
+
+// pdfFileName turns a document number into a safe download file name
+func pdfFileName(number string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == '"' || r < ' ' {
+			return '-'
+		}
+		return r
+	}, number)
 }
 
 // HealthCheck returns health status
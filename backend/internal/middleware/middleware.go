@@ -1,12 +1,19 @@
 package middleware
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"invoice-generator/internal/auth"
 	"invoice-generator/internal/constants"
 	"invoice-generator/internal/models"
+	"invoice-generator/internal/services"
 )
 
 // AuthMiddleware validates JWT tokens
@@ -86,3 +93,99 @@ func ValidateInvoiceData() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// Idempotency makes a route safe to retry. A request carrying an
+// Idempotency-Key header is processed once per user and key; replays of
+// the same request return the stored response, while reusing the key for a
+// different request, or while the first is still running, is a conflict.
+// Server errors are not stored, so such requests can be retried. It must
+// run after AuthMiddleware and before anything that reads the body.
+func Idempotency(idempotencyService *services.IdempotencyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(constants.IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > constants.MaxIdempotencyKeyLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := sha256.New()
+		hash.Write([]byte(c.Request.Method + " " + c.Request.URL.Path + "\n"))
+		hash.Write(body)
+		fingerprint := hex.EncodeToString(hash.Sum(nil))
+
+		userID, _ := c.Get("user_id")
+		record, err := idempotencyService.Begin(userID.(uint), key, fingerprint)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, services.ErrIdempotencyKeyReused) || errors.Is(err, services.ErrIdempotencyKeyInProgress) {
+				status = http.StatusConflict
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+
+		if record.Status == constants.IdempotencyStatusCompleted {
+			c.Header(constants.IdempotentReplayHeader, "true")
+			c.Data(record.ResponseCode, record.ContentType, record.ResponseBody)
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+
+		completed := false
+		defer func() {
+			if !completed {
+				// The handler panicked; let the client retry
+				if err := idempotencyService.Release(record); err != nil {
+					log.Printf("Failed to release idempotency key %d: %v", record.ID, err)
+				}
+			}
+		}()
+
+		c.Next()
+		completed = true
+
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			err = idempotencyService.Release(record)
+		} else {
+			err = idempotencyService.Complete(record, status, recorder.Header().Get("Content-Type"), recorder.body.Bytes())
+		}
+		if err != nil {
+			log.Printf("Failed to store idempotency key %d: %v", record.ID, err)
+		}
+	}
+}
+
+// responseRecorder copies the response body as it is written so that it
+// can be stored for replay
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
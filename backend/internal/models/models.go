@@ -2,6 +2,8 @@ package models
 
 import (
 	"time"
+
+	"invoice-generator/internal/money"
 )
 
 // User represents a user in the system
@@ -12,11 +14,13 @@ type User struct {
 	Name        string    `json:"name" gorm:"not null"`
 	CompanyName string    `json:"company_name"`
 	GSTIN       string    `json:"gstin"`
+	PAN         string    `json:"pan"`
 	Address     string    `json:"address"`
 	City        string    `json:"city"`
 	State       string    `json:"state"`
 	Pincode     string    `json:"pincode"`
 	Phone       string    `json:"phone"`
+	UPIVPA      string    `json:"upi_vpa" gorm:"column:upi_vpa"` // UPI ID invoices are paid to, e.g. acme@okhdfcbank
 	IsAdmin     bool      `json:"is_admin" gorm:"default:false"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
@@ -42,56 +46,696 @@ type Item struct {
 	CreatedAt   time.Time `json:"created_at"`
 }
 
+// Address is a postal address
+type Address struct {
+	Address string `json:"address"`
+	City    string `json:"city"`
+	State   string `json:"state"`
+	Pincode string `json:"pincode"`
+}
+
+// PartySnapshot records a party's name, registration and address as they
+// stood when a document was issued, so that the document keeps showing
+// them after the party's own details change
+type PartySnapshot struct {
+	Name        string `json:"name"`
+	CompanyName string `json:"company_name"`
+	GSTIN       string `json:"gstin"`
+	Email       string `json:"email"`
+	Phone       string `json:"phone"`
+	Address     `gorm:"embedded"`
+	StateCode   string `json:"state_code" gorm:"size:2"`
+}
+
+// Party is a customer or vendor in a seller's own books. Unlike users,
+// parties never log in; they belong to the seller who created them and can
+// be billed without registering an account.
+type Party struct {
+	ID               uint           `json:"id" gorm:"primaryKey"`
+	OwnerID          uint           `json:"owner_id" gorm:"not null;index"`
+	PartyType        string         `json:"party_type" gorm:"not null;default:'CUSTOMER';check:party_type IN ('CUSTOMER','VENDOR','BOTH')"`
+	Name             string         `json:"name" gorm:"not null"`
+	CompanyName      string         `json:"company_name"`
+	GSTIN            string         `json:"gstin"`
+	PAN              string         `json:"pan"`
+	Email            string         `json:"email"`
+	Phone            string         `json:"phone"`
+	BillingAddress   Address        `json:"billing_address" gorm:"embedded;embeddedPrefix:billing_"`
+	ShippingAddress  Address        `json:"shipping_address" gorm:"embedded;embeddedPrefix:shipping_"` // Empty when goods ship to the billing address
+	PaymentTermsDays int            `json:"payment_terms_days"`                                        // Days from invoice date to due date; 0 uses DefaultDueDays
+	CreditLimit      money.Amount   `json:"credit_limit" gorm:"default:0;type:decimal(15,2)"`          // Maximum outstanding on issued invoices; 0 for no limit
+	Notes            string         `json:"notes"`
+	Contacts         []PartyContact `json:"contacts" gorm:"foreignKey:PartyID"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+}
+
+// PartyContact is a contact person at a party
+type PartyContact struct {
+	ID          uint   `json:"id" gorm:"primaryKey"`
+	PartyID     uint   `json:"party_id" gorm:"not null;index"`
+	Name        string `json:"name" gorm:"not null"`
+	Designation string `json:"designation"`
+	Email       string `json:"email"`
+	Phone       string `json:"phone"`
+	IsPrimary   bool   `json:"is_primary"`
+}
+
+// Discount is a percentage or flat discount. Discounts reduce the taxable
+// value, so they are always applied before GST is calculated.
+type Discount struct {
+	DiscountType  string       `json:"discount_type" gorm:"size:10"`                       // PERCENT or FLAT; empty for no discount
+	DiscountValue money.Amount `json:"discount_value" gorm:"default:0;type:decimal(15,2)"` // Percentage (e.g. 12.5) or flat amount in rupees
+}
+
+// TransportDetails describes how the goods on an invoice are moved, as
+// required on an e-way bill. Either a mode with its vehicle or transport
+// document, or a transporter who will enter them, must be known.
+type TransportDetails struct {
+	TransporterID   string     `json:"transporter_id" gorm:"size:15"` // Transporter's GSTIN or TRANSIN
+	TransporterName string     `json:"transporter_name"`
+	Mode            string     `json:"mode" gorm:"size:10"`            // ROAD, RAIL, AIR or SHIP; empty when left to the transporter
+	VehicleNumber   string     `json:"vehicle_number" gorm:"size:20"`  // Required for road transport
+	VehicleType     string     `json:"vehicle_type" gorm:"size:10"`    // REGULAR or ODC (over-dimensional cargo); defaults to REGULAR
+	DocumentNumber  string     `json:"document_number" gorm:"size:20"` // Railway receipt, airway bill or bill of lading; lorry receipt by road
+	DocumentDate    *time.Time `json:"document_date"`
+	DistanceKm      int        `json:"distance_km"` // Approximate distance from dispatch to delivery; 0 lets the e-way bill portal calculate it
+}
+
 // Invoice represents an invoice
 type Invoice struct {
-	ID             uint              `json:"id" gorm:"primaryKey"`
-	InvoiceNumber  string            `json:"invoice_number" gorm:"unique;not null"`
-	GeneratedByID  uint              `json:"generated_by_id"`
-	GeneratedBy    User              `json:"generated_by" gorm:"foreignKey:GeneratedByID"`
-	GeneratedForID uint              `json:"generated_for_id"`
-	GeneratedFor   User              `json:"generated_for" gorm:"foreignKey:GeneratedForID"`
-	InvoiceType    string            `json:"invoice_type" gorm:"not null;check:invoice_type IN ('CASH','CREDIT','DEBIT')"`
-	PaymentStatus  string            `json:"payment_status" gorm:"default:'PENDING';check:payment_status IN ('PENDING','PARTIAL','PAID')"`
-	InvoiceDate    time.Time         `json:"invoice_date"`
-	DueDate        time.Time         `json:"due_date"`
-	SubTotal       float64           `json:"sub_total" gorm:"type:decimal(15,2)"`
-	TotalGST       float64           `json:"total_gst" gorm:"type:decimal(15,2)"`
-	TotalAmount    float64           `json:"total_amount" gorm:"type:decimal(15,2)"`
-	AmountPaid     float64           `json:"amount_paid" gorm:"default:0;type:decimal(15,2)"`
-	AmountDue      float64           `json:"amount_due" gorm:"type:decimal(15,2)"`
-	Notes          string            `json:"notes"`
-	Terms          string            `json:"terms"`
-	LineItems      []InvoiceLineItem `json:"line_items" gorm:"foreignKey:InvoiceID"`
-	Payments       []Payment         `json:"payments" gorm:"foreignKey:InvoiceID"`
-	CreatedAt      time.Time         `json:"created_at"`
-	UpdatedAt      time.Time         `json:"updated_at"`
+	ID                 uint              `json:"id" gorm:"primaryKey"`
+	InvoiceNumber      string            `json:"invoice_number" gorm:"not null;uniqueIndex:idx_invoices_seller_number,where:invoice_number <> ''"` // Empty until issued
+	SeriesID           *uint             `json:"series_id"`
+	Series             string            `json:"series" gorm:"size:10"` // Series to number the invoice from when issued; defaults to the seller's default series
+	SequenceNumber     int64             `json:"sequence_number"`
+	FinancialYear      string            `json:"financial_year" gorm:"size:7"`
+	GeneratedByID      uint              `json:"generated_by_id" gorm:"uniqueIndex:idx_invoices_seller_number,where:invoice_number <> ''"`
+	GeneratedBy        User              `json:"generated_by" gorm:"foreignKey:GeneratedByID"`
+	GeneratedForID     *uint             `json:"generated_for_id"` // Registered user billed; set this or PartyID
+	GeneratedFor       *User             `json:"generated_for,omitempty" gorm:"foreignKey:GeneratedForID"`
+	PartyID            *uint             `json:"party_id" gorm:"index"` // Seller's customer billed; set this or GeneratedForID
+	Party              *Party            `json:"party,omitempty" gorm:"foreignKey:PartyID"`
+	QuotationID        *uint             `json:"quotation_id" gorm:"index"` // Quotation the invoice was converted from
+	InvoiceType        string            `json:"invoice_type" gorm:"not null;check:invoice_type IN ('CASH','CREDIT','DEBIT')"`
+	Status             string            `json:"status" gorm:"not null;default:'ISSUED';index;check:status IN ('DRAFT','ISSUED','VOID','CANCELLED')"`
+	Issue              bool              `json:"issue,omitempty" gorm:"-"` // Issue immediately when creating or updating a draft
+	IssuedAt           *time.Time        `json:"issued_at"`
+	CancelledAt        *time.Time        `json:"cancelled_at"`
+	CancelledByID      *uint             `json:"cancelled_by_id"`
+	CancellationReason string            `json:"cancellation_reason"`
+	PaymentStatus      string            `json:"payment_status" gorm:"default:'PENDING';check:payment_status IN ('PENDING','PARTIAL','PAID')"`
+	InvoiceDate        time.Time         `json:"invoice_date"`
+	DueDate            time.Time         `json:"due_date"`
+	PlaceOfSupply      string            `json:"place_of_supply" gorm:"size:2"` // GST state code; derived from the buyer unless set explicitly
+	SupplyType         string            `json:"supply_type" gorm:"check:supply_type IN ('INTRA_STATE','INTER_STATE')"`
+	ShipTo             PartySnapshot     `json:"ship_to" gorm:"embedded;embeddedPrefix:ship_to_"`     // Delivery address when different from the buyer's; defaults to a party's shipping address
+	Transport          TransportDetails  `json:"transport" gorm:"embedded;embeddedPrefix:transport_"` // How the goods are moved, for the e-way bill
+	Seller             PartySnapshot     `json:"seller" gorm:"embedded;embeddedPrefix:seller_"`       // Seller's details as issued; empty on drafts
+	Buyer              PartySnapshot     `json:"buyer" gorm:"embedded;embeddedPrefix:buyer_"`         // Buyer's details as issued; empty on drafts
+	IRN                string            `json:"irn" gorm:"size:64;index"`                            // Invoice Reference Number from the IRP; empty until the e-invoice is registered
+	AckNo              string            `json:"ack_no" gorm:"size:20"`
+	AckDate            *time.Time        `json:"ack_date"`
+	SignedQRCode       string            `json:"signed_qr_code,omitempty" gorm:"type:text"` // QR code content signed by the IRP
+	IRNCancelledAt     *time.Time        `json:"irn_cancelled_at"`                          // When the IRP cancelled the IRN; the invoice can then be cancelled
+	IRNCancelRemarks   string            `json:"irn_cancel_remarks,omitempty"`
+	Discount           `gorm:"embedded"` // Invoice-level discount, shared across lines in proportion to their value
+	GrossAmount        money.Amount      `json:"gross_amount" gorm:"default:0;type:decimal(15,2)"`    // Sum of quantity x rate before any discount
+	DiscountAmount     money.Amount      `json:"discount_amount" gorm:"default:0;type:decimal(15,2)"` // Invoice-level discount
+	TotalDiscount      money.Amount      `json:"total_discount" gorm:"default:0;type:decimal(15,2)"`  // Line and invoice-level discounts
+	SubTotal           money.Amount      `json:"sub_total" gorm:"type:decimal(15,2)"`                 // Taxable value after discounts
+	TotalCGST          money.Amount      `json:"total_cgst" gorm:"default:0;type:decimal(15,2)"`
+	TotalSGST          money.Amount      `json:"total_sgst" gorm:"default:0;type:decimal(15,2)"`
+	TotalIGST          money.Amount      `json:"total_igst" gorm:"default:0;type:decimal(15,2)"`
+	TotalGST           money.Amount      `json:"total_gst" gorm:"type:decimal(15,2)"`
+	TotalAmount        money.Amount      `json:"total_amount" gorm:"type:decimal(15,2)"`
+	TotalCredited      money.Amount      `json:"total_credited" gorm:"default:0;type:decimal(15,2)"` // Sum of credit notes issued against the invoice
+	TotalDebited       money.Amount      `json:"total_debited" gorm:"default:0;type:decimal(15,2)"`  // Sum of debit notes issued against the invoice
+	AmountPaid         money.Amount      `json:"amount_paid" gorm:"default:0;type:decimal(15,2)"`
+	AmountDue          money.Amount      `json:"amount_due" gorm:"type:decimal(15,2)"`
+	Notes              string            `json:"notes"`
+	Terms              string            `json:"terms"`
+	LineItems          []InvoiceLineItem `json:"line_items" gorm:"foreignKey:InvoiceID"`
+	Payments           []Payment         `json:"payments" gorm:"foreignKey:InvoiceID"`
+	AdjustmentNotes    []AdjustmentNote  `json:"adjustment_notes" gorm:"foreignKey:InvoiceID"`
+	CreatedAt          time.Time         `json:"created_at"`
+	UpdatedAt          time.Time         `json:"updated_at"`
 }
 
 // InvoiceLineItem represents a line item in an invoice
 type InvoiceLineItem struct {
-	ID          uint    `json:"id" gorm:"primaryKey"`
-	InvoiceID   uint    `json:"invoice_id"`
-	ItemID      *uint   `json:"item_id"` // Optional, can be null for custom items
-	Item        *Item   `json:"item,omitempty" gorm:"foreignKey:ItemID"`
-	Description string  `json:"description" gorm:"not null"`
-	Quantity    float64 `json:"quantity" gorm:"type:decimal(10,3)"`
-	Rate        float64 `json:"rate" gorm:"type:decimal(15,2)"`
-	Amount      float64 `json:"amount" gorm:"type:decimal(15,2)"`
-	GSTRate     int     `json:"gst_rate"`
-	GSTAmount   float64 `json:"gst_amount" gorm:"type:decimal(15,2)"`
-	TotalAmount float64 `json:"total_amount" gorm:"type:decimal(15,2)"`
-}
-
-// Payment represents a payment made against an invoice
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	InvoiceID   uint           `json:"invoice_id"`
+	ItemID      *uint          `json:"item_id"` // Optional, can be null for custom items
+	Item        *Item          `json:"item,omitempty" gorm:"foreignKey:ItemID"`
+	Description string         `json:"description" gorm:"not null"`
+	HSNCode     string         `json:"hsn_code" gorm:"size:8"` // HSN or SAC code; defaults to the item's
+	Quantity    money.Quantity `json:"quantity" gorm:"type:decimal(10,3)"`
+	Rate        money.Amount   `json:"rate" gorm:"type:decimal(15,2)"`
+	Amount      money.Amount   `json:"amount" gorm:"type:decimal(15,2)"` // Taxable value after discounts
+	GSTRate     int            `json:"gst_rate"`
+	CGSTAmount  money.Amount   `json:"cgst_amount" gorm:"default:0;type:decimal(15,2)"`
+	SGSTAmount  money.Amount   `json:"sgst_amount" gorm:"default:0;type:decimal(15,2)"`
+	IGSTAmount  money.Amount   `json:"igst_amount" gorm:"default:0;type:decimal(15,2)"`
+	GSTAmount   money.Amount   `json:"gst_amount" gorm:"type:decimal(15,2)"`
+	TotalAmount money.Amount   `json:"total_amount" gorm:"type:decimal(15,2)"`
+
+	Discount              `gorm:"embedded"`
+	GrossAmount           money.Amount `json:"gross_amount" gorm:"default:0;type:decimal(15,2)"`            // Quantity x rate
+	DiscountAmount        money.Amount `json:"discount_amount" gorm:"default:0;type:decimal(15,2)"`         // Line discount
+	InvoiceDiscountAmount money.Amount `json:"invoice_discount_amount" gorm:"default:0;type:decimal(15,2)"` // Share of the invoice-level discount
+
+	QuotationLineItemID *uint `json:"quotation_line_item_id" gorm:"index"` // Quotation line this line was converted from
+}
+
+// InvoiceRevision is an immutable record of an invoice's contents after a
+// change, with who made it and which fields changed compared with the
+// previous revision
+type InvoiceRevision struct {
+	ID             uint            `json:"id" gorm:"primaryKey"`
+	InvoiceID      uint            `json:"invoice_id" gorm:"not null;uniqueIndex:idx_invoice_revisions_number"`
+	RevisionNumber int             `json:"revision_number" gorm:"not null;uniqueIndex:idx_invoice_revisions_number"`
+	Action         string          `json:"action" gorm:"not null"`
+	EditedByID     uint            `json:"edited_by_id"`
+	EditedBy       *User           `json:"edited_by,omitempty" gorm:"foreignKey:EditedByID"`
+	Snapshot       InvoiceSnapshot `json:"snapshot" gorm:"type:jsonb;serializer:json"`
+	Changes        []FieldChange   `json:"changes" gorm:"type:jsonb;serializer:json"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// InvoiceSnapshot captures the editable contents and computed totals of an
+// invoice at one revision
+type InvoiceSnapshot struct {
+	InvoiceNumber  string           `json:"invoice_number"`
+	Status         string           `json:"status"`
+	GeneratedForID *uint            `json:"generated_for_id"`
+	PartyID        *uint            `json:"party_id"`
+	InvoiceType    string           `json:"invoice_type"`
+	InvoiceDate    time.Time        `json:"invoice_date"`
+	DueDate        time.Time        `json:"due_date"`
+	PlaceOfSupply  string           `json:"place_of_supply"`
+	SupplyType     string           `json:"supply_type"`
+	ShipTo         PartySnapshot    `json:"ship_to"`
+	Transport      TransportDetails `json:"transport"`
+	Notes          string           `json:"notes"`
+	Terms          string           `json:"terms"`
+	Discount
+	TotalDiscount money.Amount          `json:"total_discount"`
+	SubTotal      money.Amount          `json:"sub_total"`
+	TotalCGST     money.Amount          `json:"total_cgst"`
+	TotalSGST     money.Amount          `json:"total_sgst"`
+	TotalIGST     money.Amount          `json:"total_igst"`
+	TotalGST      money.Amount          `json:"total_gst"`
+	TotalAmount   money.Amount          `json:"total_amount"`
+	LineItems     []InvoiceSnapshotLine `json:"line_items"`
+}
+
+// InvoiceSnapshotLine is a line item as captured in a revision
+type InvoiceSnapshotLine struct {
+	ItemID      *uint          `json:"item_id"`
+	Description string         `json:"description"`
+	HSNCode     string         `json:"hsn_code"`
+	Quantity    money.Quantity `json:"quantity"`
+	Rate        money.Amount   `json:"rate"`
+	Discount
+	Amount      money.Amount `json:"amount"`
+	GSTRate     int          `json:"gst_rate"`
+	GSTAmount   money.Amount `json:"gst_amount"`
+	TotalAmount money.Amount `json:"total_amount"`
+}
+
+// FieldChange describes one changed field between two revisions. Nested
+// fields use dotted paths, e.g. "line_items[1].rate".
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// AdjustmentNote is a credit note or debit note issued against an invoice.
+// Credit notes (returns, post-sale discounts) reduce the amount due on the
+// original invoice; debit notes (additional charges) increase it. Notes
+// inherit the place of supply of the original invoice so GST is reversed
+// or added under the same heads.
+type AdjustmentNote struct {
+	ID             uint                     `json:"id" gorm:"primaryKey"`
+	NoteNumber     string                   `json:"note_number" gorm:"not null;uniqueIndex:idx_adjustment_notes_seller_number"`
+	NoteType       string                   `json:"note_type" gorm:"not null;check:note_type IN ('CREDIT_NOTE','DEBIT_NOTE')"`
+	SeriesID       *uint                    `json:"series_id"`
+	Series         string                   `json:"series" gorm:"-"`
+	SequenceNumber int64                    `json:"sequence_number"`
+	FinancialYear  string                   `json:"financial_year" gorm:"size:7"`
+	InvoiceID      uint                     `json:"invoice_id" gorm:"not null;index"`
+	Invoice        *Invoice                 `json:"invoice,omitempty" gorm:"foreignKey:InvoiceID"`
+	GeneratedByID  uint                     `json:"generated_by_id" gorm:"uniqueIndex:idx_adjustment_notes_seller_number"`
+	GeneratedForID *uint                    `json:"generated_for_id"`
+	PartyID        *uint                    `json:"party_id" gorm:"index"`
+	NoteDate       time.Time                `json:"note_date"`
+	Reason         string                   `json:"reason" gorm:"not null"`
+	PlaceOfSupply  string                   `json:"place_of_supply" gorm:"size:2"`
+	SupplyType     string                   `json:"supply_type"`
+	SubTotal       money.Amount             `json:"sub_total" gorm:"type:decimal(15,2)"`
+	TotalCGST      money.Amount             `json:"total_cgst" gorm:"default:0;type:decimal(15,2)"`
+	TotalSGST      money.Amount             `json:"total_sgst" gorm:"default:0;type:decimal(15,2)"`
+	TotalIGST      money.Amount             `json:"total_igst" gorm:"default:0;type:decimal(15,2)"`
+	TotalGST       money.Amount             `json:"total_gst" gorm:"type:decimal(15,2)"`
+	TotalAmount    money.Amount             `json:"total_amount" gorm:"type:decimal(15,2)"`
+	Notes          string                   `json:"notes"`
+	LineItems      []AdjustmentNoteLineItem `json:"line_items" gorm:"foreignKey:NoteID"`
+	CreatedAt      time.Time                `json:"created_at"`
+}
+
+// AdjustmentNoteLineItem is a line of a credit or debit note. Lines may
+// refer to a line of the original invoice, in which case a description,
+// rate or GST rate left out defaults to that of the original line.
+type AdjustmentNoteLineItem struct {
+	ID                uint           `json:"id" gorm:"primaryKey"`
+	NoteID            uint           `json:"note_id"`
+	InvoiceLineItemID *uint          `json:"invoice_line_item_id"`
+	Description       string         `json:"description" gorm:"not null"`
+	Quantity          money.Quantity `json:"quantity" gorm:"type:decimal(10,3)"`
+	Rate              money.Amount   `json:"rate" gorm:"type:decimal(15,2)"`
+	Amount            money.Amount   `json:"amount" gorm:"type:decimal(15,2)"`
+	GSTRate           *int           `json:"gst_rate"`
+	CGSTAmount        money.Amount   `json:"cgst_amount" gorm:"default:0;type:decimal(15,2)"`
+	SGSTAmount        money.Amount   `json:"sgst_amount" gorm:"default:0;type:decimal(15,2)"`
+	IGSTAmount        money.Amount   `json:"igst_amount" gorm:"default:0;type:decimal(15,2)"`
+	GSTAmount         money.Amount   `json:"gst_amount" gorm:"type:decimal(15,2)"`
+	TotalAmount       money.Amount   `json:"total_amount" gorm:"type:decimal(15,2)"`
+}
+
+// Quotation is an estimate sent to a buyer before invoicing. Quotations
+// are numbered from their own series and use the same line-item and GST
+// calculation as invoices. Lines can be converted into invoices in full or
+// in part; InvoicedQuantity on each line tracks how much has been invoiced.
+type Quotation struct {
+	ID              uint       `json:"id" gorm:"primaryKey"`
+	QuotationNumber string     `json:"quotation_number" gorm:"not null;uniqueIndex:idx_quotations_seller_number"`
+	SeriesID        *uint      `json:"series_id"`
+	Series          string     `json:"series" gorm:"-"`
+	SequenceNumber  int64      `json:"sequence_number"`
+	FinancialYear   string     `json:"financial_year" gorm:"size:7"`
+	GeneratedByID   uint       `json:"generated_by_id" gorm:"uniqueIndex:idx_quotations_seller_number"`
+	GeneratedBy     User       `json:"generated_by" gorm:"foreignKey:GeneratedByID"`
+	GeneratedForID  *uint      `json:"generated_for_id"` // Registered user quoted; set this or PartyID
+	GeneratedFor    *User      `json:"generated_for,omitempty" gorm:"foreignKey:GeneratedForID"`
+	PartyID         *uint      `json:"party_id" gorm:"index"` // Seller's customer quoted; set this or GeneratedForID
+	Party           *Party     `json:"party,omitempty" gorm:"foreignKey:PartyID"`
+	QuotationDate   time.Time  `json:"quotation_date"`
+	ValidUntil      time.Time  `json:"valid_until"`
+	Status          string     `json:"status" gorm:"not null;default:'OPEN';index;check:status IN ('OPEN','ACCEPTED','REJECTED','EXPIRED','CONVERTED')"`
+	AcceptedAt      *time.Time `json:"accepted_at"`
+	RejectedAt      *time.Time `json:"rejected_at"`
+	RejectionReason string     `json:"rejection_reason"`
+	PlaceOfSupply   string     `json:"place_of_supply" gorm:"size:2"`
+	SupplyType      string     `json:"supply_type" gorm:"check:supply_type IN ('INTRA_STATE','INTER_STATE')"`
+	Discount        `gorm:"embedded"`
+	GrossAmount     money.Amount        `json:"gross_amount" gorm:"default:0;type:decimal(15,2)"`
+	DiscountAmount  money.Amount        `json:"discount_amount" gorm:"default:0;type:decimal(15,2)"`
+	TotalDiscount   money.Amount        `json:"total_discount" gorm:"default:0;type:decimal(15,2)"`
+	SubTotal        money.Amount        `json:"sub_total" gorm:"type:decimal(15,2)"`
+	TotalCGST       money.Amount        `json:"total_cgst" gorm:"default:0;type:decimal(15,2)"`
+	TotalSGST       money.Amount        `json:"total_sgst" gorm:"default:0;type:decimal(15,2)"`
+	TotalIGST       money.Amount        `json:"total_igst" gorm:"default:0;type:decimal(15,2)"`
+	TotalGST        money.Amount        `json:"total_gst" gorm:"type:decimal(15,2)"`
+	TotalAmount     money.Amount        `json:"total_amount" gorm:"type:decimal(15,2)"`
+	Notes           string              `json:"notes"`
+	Terms           string              `json:"terms"`
+	LineItems       []QuotationLineItem `json:"line_items" gorm:"foreignKey:QuotationID"`
+	Invoices        []Invoice           `json:"invoices,omitempty" gorm:"foreignKey:QuotationID"`
+	CreatedAt       time.Time           `json:"created_at"`
+	UpdatedAt       time.Time           `json:"updated_at"`
+}
+
+// QuotationLineItem is a line of a quotation
+type QuotationLineItem struct {
+	ID               uint           `json:"id" gorm:"primaryKey"`
+	QuotationID      uint           `json:"quotation_id"`
+	ItemID           *uint          `json:"item_id"`
+	Item             *Item          `json:"item,omitempty" gorm:"foreignKey:ItemID"`
+	Description      string         `json:"description" gorm:"not null"`
+	HSNCode          string         `json:"hsn_code" gorm:"size:8"`
+	Quantity         money.Quantity `json:"quantity" gorm:"type:decimal(10,3)"`
+	Rate             money.Amount   `json:"rate" gorm:"type:decimal(15,2)"`
+	Amount           money.Amount   `json:"amount" gorm:"type:decimal(15,2)"`
+	GSTRate          int            `json:"gst_rate"`
+	CGSTAmount       money.Amount   `json:"cgst_amount" gorm:"default:0;type:decimal(15,2)"`
+	SGSTAmount       money.Amount   `json:"sgst_amount" gorm:"default:0;type:decimal(15,2)"`
+	IGSTAmount       money.Amount   `json:"igst_amount" gorm:"default:0;type:decimal(15,2)"`
+	GSTAmount        money.Amount   `json:"gst_amount" gorm:"type:decimal(15,2)"`
+	TotalAmount      money.Amount   `json:"total_amount" gorm:"type:decimal(15,2)"`
+	InvoicedQuantity money.Quantity `json:"invoiced_quantity" gorm:"default:0;type:decimal(10,3)"` // Quantity on live (not void or cancelled) invoices
+
+	Discount              `gorm:"embedded"`
+	GrossAmount           money.Amount `json:"gross_amount" gorm:"default:0;type:decimal(15,2)"`
+	DiscountAmount        money.Amount `json:"discount_amount" gorm:"default:0;type:decimal(15,2)"`
+	InvoiceDiscountAmount money.Amount `json:"invoice_discount_amount" gorm:"default:0;type:decimal(15,2)"`
+}
+
+// QuotationConversionRequest selects what to invoice from a quotation.
+// Without lines, every line is invoiced for its remaining quantity.
+type QuotationConversionRequest struct {
+	Lines       []QuotationConversionLine `json:"lines"`
+	InvoiceType string                    `json:"invoice_type"`
+	InvoiceDate time.Time                 `json:"invoice_date"`
+	DueDate     time.Time                 `json:"due_date"`
+	Series      string                    `json:"series"`
+	Issue       bool                      `json:"issue"`
+}
+
+// QuotationConversionLine is the quantity of one quotation line to invoice
+type QuotationConversionLine struct {
+	QuotationLineItemID uint           `json:"quotation_line_item_id" binding:"required"`
+	Quantity            money.Quantity `json:"quantity"`
+}
+
+// Payment represents a payment made against an invoice, or a refund of
+// one. Refunds are recorded with a negative amount. Payments are never
+// deleted: mistaken entries are reversed and bounced cheques are marked as
+// such, and both then stop counting towards the amount paid.
 type Payment struct {
+	ID            uint         `json:"id" gorm:"primaryKey"`
+	InvoiceID     uint         `json:"invoice_id"`
+	ReceiptID     *uint        `json:"receipt_id,omitempty" gorm:"index"` // Receipt the payment is an allocation of
+	PaymentType   string       `json:"payment_type" gorm:"not null;default:'RECEIPT';check:payment_type IN ('RECEIPT','REFUND')"`
+	Amount        money.Amount `json:"amount" gorm:"type:decimal(15,2)"`
+	PaymentMethod string       `json:"payment_method" gorm:"check:payment_method IN ('CASH','BANK_TRANSFER','CHEQUE','UPI','CARD','CREDIT')"`
+	PaymentDate   time.Time    `json:"payment_date"`
+	Reference     string       `json:"reference"` // Cheque number, UTR or transaction ID
+	Notes         string       `json:"notes"`
+	Status        string       `json:"status" gorm:"not null;default:'CLEARED';index"` // RECEIVED, DEPOSITED, CLEARED, BOUNCED or REVERSED
+	DepositedAt   *time.Time   `json:"deposited_at,omitempty"`
+	ClearedAt     *time.Time   `json:"cleared_at,omitempty"`
+	BouncedAt     *time.Time   `json:"bounced_at,omitempty"`
+	ReversedAt    *time.Time   `json:"reversed_at,omitempty"`
+	Reason        string       `json:"reason,omitempty"`                                  // Why a refund was made, a cheque bounced or a payment was reversed
+	BankCharges   money.Amount `json:"bank_charges" gorm:"type:decimal(15,2);default:0"`  // Charged to the buyer for a bounced cheque
+	DebitNoteID   *uint        `json:"debit_note_id,omitempty"`                           // Debit note recovering the bank charges
+	CreditAmount  money.Amount `json:"credit_amount" gorm:"type:decimal(15,2);default:0"` // Received beyond the amount due and added to the party's credit balance
+	CreatedAt     time.Time    `json:"created_at"`
+}
+
+// PaymentEvent is an entry in the audit trail of a payment: its recording
+// and every later change of status, with who made it and why. Events are
+// kept even if the payment's invoice is deleted.
+type PaymentEvent struct {
+	ID            uint         `json:"id" gorm:"primaryKey"`
+	PaymentID     uint         `json:"payment_id" gorm:"not null;index"`
+	InvoiceID     uint         `json:"invoice_id" gorm:"not null;index"`
+	Action        string       `json:"action" gorm:"not null"` // RECORDED, DEPOSITED, CLEARED, BOUNCED or REVERSED
+	FromStatus    string       `json:"from_status,omitempty"`
+	ToStatus      string       `json:"to_status"`
+	Amount        money.Amount `json:"amount" gorm:"type:decimal(15,2)"`
+	Reason        string       `json:"reason,omitempty"`
+	PerformedByID uint         `json:"performed_by_id" gorm:"not null"`
+	PerformedBy   *User        `json:"performed_by,omitempty" gorm:"foreignKey:PerformedByID"`
+	CreatedAt     time.Time    `json:"created_at"`
+}
+
+// Receipt is a single amount received from a buyer, such as one bank
+// transfer covering several invoices, allocated across any number of the
+// seller's invoices to that buyer. Each allocation is recorded as a
+// payment on its invoice; reallocating a receipt reverses those payments
+// and records new ones.
+type Receipt struct {
+	ID                uint                `json:"id" gorm:"primaryKey"`
+	SellerID          uint                `json:"seller_id" gorm:"not null;index"`
+	GeneratedForID    *uint               `json:"generated_for_id"` // Registered user who paid; set this or PartyID
+	PartyID           *uint               `json:"party_id" gorm:"index"`
+	Party             *Party              `json:"party,omitempty" gorm:"foreignKey:PartyID"`
+	Amount            money.Amount        `json:"amount" gorm:"type:decimal(15,2)"`
+	PaymentMethod     string              `json:"payment_method" gorm:"check:payment_method IN ('CASH','BANK_TRANSFER','UPI','CARD')"`
+	ReceiptDate       time.Time           `json:"receipt_date"`
+	Reference         string              `json:"reference" gorm:"index"` // Bank reference (UTR) or transaction ID
+	Notes             string              `json:"notes"`
+	AllocatedAmount   money.Amount        `json:"allocated_amount" gorm:"default:0;type:decimal(15,2)"`
+	UnallocatedAmount money.Amount        `json:"unallocated_amount" gorm:"default:0;type:decimal(15,2)"`
+	Allocations       []ReceiptAllocation `json:"allocations,omitempty" gorm:"-"`   // Invoices to allocate to when creating the receipt
+	AutoAllocate      bool                `json:"auto_allocate,omitempty" gorm:"-"` // Allocate to the buyer's open invoices, oldest due first
+	Payments          []Payment           `json:"payments" gorm:"foreignKey:ReceiptID"`
+	CreatedByID       uint                `json:"created_by_id"`
+	CreatedAt         time.Time           `json:"created_at"`
+	UpdatedAt         time.Time           `json:"updated_at"`
+}
+
+// ReceiptAllocation is the part of a receipt to apply to one invoice
+type ReceiptAllocation struct {
+	InvoiceID uint         `json:"invoice_id" binding:"required"`
+	Amount    money.Amount `json:"amount"`
+}
+
+// AllocationRequest replaces the allocation of a receipt, either with the
+// given allocations or automatically
+type AllocationRequest struct {
+	Allocations  []ReceiptAllocation `json:"allocations"`
+	AutoAllocate bool                `json:"auto_allocate"`
+}
+
+// CreditEntry is a movement on a party's credit balance with a seller: an
+// advance received before invoicing or left unallocated on a receipt, the
+// excess of an overpayment, credit applied to an invoice, or the reversal
+// of one of these. Positive amounts add to the balance and negative
+// amounts use it; the balance is the sum of a party's entries.
+type CreditEntry struct {
+	ID              uint         `json:"id" gorm:"primaryKey"`
+	SellerID        uint         `json:"seller_id" gorm:"not null;index"`
+	PartyID         uint         `json:"party_id" gorm:"not null;index"`
+	Party           *Party       `json:"party,omitempty" gorm:"foreignKey:PartyID"`
+	EntryType       string       `json:"entry_type" gorm:"not null;check:entry_type IN ('ADVANCE','OVERPAYMENT','APPLIED','REVERSAL')"`
+	Amount          money.Amount `json:"amount" gorm:"type:decimal(15,2)"`
+	EntryDate       time.Time    `json:"entry_date"`
+	PaymentMethod   string       `json:"payment_method,omitempty"` // How an advance was received
+	Reference       string       `json:"reference"`
+	Notes           string       `json:"notes"`                             // Reason for a reversal
+	InvoiceID       *uint        `json:"invoice_id,omitempty" gorm:"index"` // Invoice the credit was applied to or overpaid
+	PaymentID       *uint        `json:"payment_id,omitempty"`              // Payment made from the credit, or the overpaying payment
+	ReceiptID       *uint        `json:"receipt_id,omitempty" gorm:"index"` // Receipt whose unallocated amount the entry holds
+	ReversedEntryID *uint        `json:"reversed_entry_id,omitempty"`       // Advance undone by a reversal
+	CreatedByID     uint         `json:"created_by_id"`
+	CreatedAt       time.Time    `json:"created_at"`
+}
+
+// CreditStatement is a party's credit balance with its entries, oldest
+// first
+type CreditStatement struct {
+	PartyID uint          `json:"party_id"`
+	Balance money.Amount  `json:"balance"`
+	Entries []CreditEntry `json:"entries"`
+}
+
+// CreditBalance is the credit balance of one party
+type CreditBalance struct {
+	SellerID    uint         `json:"seller_id"`
+	PartyID     uint         `json:"party_id"`
+	PartyName   string       `json:"party_name"`
+	CompanyName string       `json:"company_name"`
+	Balance     money.Amount `json:"balance"`
+}
+
+// ApplyCreditRequest is the amount of a party's credit to apply to an
+// invoice; zero applies as much as is available and due
+type ApplyCreditRequest struct {
+	Amount money.Amount `json:"amount"`
+}
+
+// PaymentStatusRequest carries the date and reason of a change to a
+// payment's status
+type PaymentStatusRequest struct {
+	Date   *time.Time `json:"date"` // When the cheque was deposited, cleared or bounced; defaults to now
+	Reason string     `json:"reason"`
+}
+
+// ChequeBounceRequest records a bounced cheque. Bank charges, if given,
+// are recovered from the buyer with a debit note.
+type ChequeBounceRequest struct {
+	Date        *time.Time   `json:"date"`
+	Reason      string       `json:"reason" binding:"required"`
+	BankCharges money.Amount `json:"bank_charges"`
+}
+
+// BankStatement is a statement file imported from the seller's bank.
+// Transactions already imported from an earlier file are skipped, so
+// overlapping statements can be imported safely.
+type BankStatement struct {
+	ID               uint              `json:"id" gorm:"primaryKey"`
+	SellerID         uint              `json:"seller_id" gorm:"not null;index"`
+	Format           string            `json:"format" gorm:"not null;check:format IN ('CSV','OFX','MT940')"`
+	FileName         string            `json:"file_name"`
+	AccountNumber    string            `json:"account_number"`
+	FromDate         *time.Time        `json:"from_date"`
+	ToDate           *time.Time        `json:"to_date"`
+	TransactionCount int               `json:"transaction_count"` // Transactions imported
+	DuplicateCount   int               `json:"duplicate_count"`   // Transactions skipped as already imported
+	Transactions     []BankTransaction `json:"transactions,omitempty" gorm:"foreignKey:StatementID"`
+	CreatedByID      uint              `json:"created_by_id"`
+	CreatedAt        time.Time         `json:"created_at"`
+}
+
+// BankTransaction is one line of a bank statement and its reconciliation.
+// A credit is matched to a payment or receipt already recorded, or to an
+// open invoice that is paid when the match is confirmed; a debit may be
+// matched to a refund. Proposed matches wait for confirmation.
+type BankTransaction struct {
+	ID              uint         `json:"id" gorm:"primaryKey"`
+	StatementID     uint         `json:"statement_id" gorm:"not null;index"`
+	SellerID        uint         `json:"seller_id" gorm:"not null;uniqueIndex:idx_bank_transactions_seller_fingerprint"`
+	TransactionDate time.Time    `json:"transaction_date" gorm:"index"`
+	Amount          money.Amount `json:"amount" gorm:"type:decimal(15,2)"` // Positive for credits, negative for debits
+	Reference       string       `json:"reference"`                        // Cheque number or UTR
+	BankReference   string       `json:"bank_reference"`
+	Description     string       `json:"description"`
+	Fingerprint     string       `json:"-" gorm:"size:64;not null;uniqueIndex:idx_bank_transactions_seller_fingerprint"` // Identifies the transaction across imports
+	Status          string       `json:"status" gorm:"not null;default:'UNMATCHED';index;check:status IN ('UNMATCHED','PROPOSED','RECONCILED','IGNORED')"`
+	MatchType       string       `json:"match_type,omitempty"` // INVOICE, PAYMENT or RECEIPT
+	InvoiceID       *uint        `json:"invoice_id,omitempty"`
+	PaymentID       *uint        `json:"payment_id,omitempty" gorm:"index"` // For invoice matches, the payment recorded on confirmation
+	ReceiptID       *uint        `json:"receipt_id,omitempty" gorm:"index"`
+	MatchReason     string       `json:"match_reason,omitempty"` // Why the match was proposed
+	ReconciledAt    *time.Time   `json:"reconciled_at,omitempty"`
+	ReconciledByID  *uint        `json:"reconciled_by_id,omitempty"`
+	CreatedAt       time.Time    `json:"created_at"`
+	UpdatedAt       time.Time    `json:"updated_at"`
+}
+
+// BankMatchRequest confirms a bank transaction's match. Giving one of the
+// IDs matches the transaction to that invoice, payment or receipt instead
+// of the proposed one.
+type BankMatchRequest struct {
+	InvoiceID *uint  `json:"invoice_id"`
+	PaymentID *uint  `json:"payment_id"`
+	ReceiptID *uint  `json:"receipt_id"`
+	Method    string `json:"payment_method"` // For invoice matches; defaults to BANK_TRANSFER
+}
+
+// UPIPaymentLink is a UPI payment request for the amount due on an
+// invoice, as an intent URI that opens the payer's UPI app
+type UPIPaymentLink struct {
+	URI       string       `json:"uri"`
+	VPA       string       `json:"vpa"`
+	PayeeName string       `json:"payee_name"`
+	Amount    money.Amount `json:"amount"`
+	Note      string       `json:"note"`
+}
+
+// NumberSeries configures how document numbers are generated for a seller.
+// The pattern may contain the placeholders {SERIES}, {FY} (e.g. 2025-26),
+// {FY_SHORT} (e.g. 2526), {YYYY}, {MM} and {SEQ} or {SEQ:n} for the
+// sequence zero-padded to n digits.
+type NumberSeries struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	SellerID     uint      `json:"seller_id" gorm:"not null;uniqueIndex:idx_number_series_seller_name"`
+	Name         string    `json:"name" gorm:"not null;uniqueIndex:idx_number_series_seller_name"`
+	DocumentType string    `json:"document_type" gorm:"not null;default:'INVOICE'"`
+	Pattern      string    `json:"pattern" gorm:"not null"`
+	IsDefault    bool      `json:"is_default" gorm:"default:false"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// NumberSequence holds the last number allocated in a series for one
+// financial year. Rows are locked while a number is allocated so that
+// numbering stays gapless and unique under concurrent requests.
+type NumberSequence struct {
 	ID            uint      `json:"id" gorm:"primaryKey"`
-	InvoiceID     uint      `json:"invoice_id"`
-	Amount        float64   `json:"amount" gorm:"type:decimal(15,2)"`
-	PaymentMethod string    `json:"payment_method" gorm:"check:payment_method IN ('CASH','BANK_TRANSFER','CHEQUE','UPI','CARD')"`
-	PaymentDate   time.Time `json:"payment_date"`
-	Reference     string    `json:"reference"`
-	Notes         string    `json:"notes"`
-	CreatedAt     time.Time `json:"created_at"`
+	SeriesID      uint      `json:"series_id" gorm:"not null;uniqueIndex:idx_number_sequence_series_fy"`
+	FinancialYear string    `json:"financial_year" gorm:"not null;size:7;uniqueIndex:idx_number_sequence_series_fy"`
+	LastNumber    int64     `json:"last_number" gorm:"not null;default:0"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// RecurringInvoice is a template from which invoices are generated on a
+// schedule. Fixed frequencies repeat on the day of month of StartDate
+// (clamped to shorter months); CUSTOM schedules follow CronExpression.
+// The schedule ends after EndDate or MaxOccurrences invoices, whichever
+// comes first (zero values mean no limit).
+type RecurringInvoice struct {
+	ID              uint   `json:"id" gorm:"primaryKey"`
+	Name            string `json:"name" gorm:"not null"`
+	GeneratedByID   uint   `json:"generated_by_id" gorm:"not null;index"`
+	GeneratedForID  *uint  `json:"generated_for_id"` // Registered user billed; set this or PartyID
+	GeneratedFor    *User  `json:"generated_for,omitempty" gorm:"foreignKey:GeneratedForID"`
+	PartyID         *uint  `json:"party_id" gorm:"index"` // Seller's customer billed; set this or GeneratedForID
+	Party           *Party `json:"party,omitempty" gorm:"foreignKey:PartyID"`
+	InvoiceType     string `json:"invoice_type" gorm:"not null;check:invoice_type IN ('CASH','CREDIT','DEBIT')"`
+	PlaceOfSupply   string `json:"place_of_supply" gorm:"size:2"`
+	Series          string `json:"series" gorm:"size:10"`
+	Notes           string `json:"notes"`
+	Terms           string `json:"terms"`
+	DueDays         int    `json:"due_days"`   // Days from invoice date to due date; defaults to the buyer's payment terms
+	AutoIssue       bool   `json:"auto_issue"` // Issue generated invoices instead of leaving them as drafts
+	Discount        `gorm:"embedded"`
+	Frequency       string                     `json:"frequency" gorm:"not null;check:frequency IN ('MONTHLY','QUARTERLY','YEARLY','CUSTOM')"`
+	CronExpression  string                     `json:"cron_expression"`
+	StartDate       time.Time                  `json:"start_date" gorm:"not null"`
+	EndDate         *time.Time                 `json:"end_date"`
+	MaxOccurrences  int                        `json:"max_occurrences"`
+	OccurrenceCount int                        `json:"occurrence_count" gorm:"not null;default:0"`
+	LastRunDate     *time.Time                 `json:"last_run_date"`
+	NextRunDate     *time.Time                 `json:"next_run_date" gorm:"index"` // Nil once the schedule has no further dates
+	Status          string                     `json:"status" gorm:"not null;default:'ACTIVE';check:status IN ('ACTIVE','PAUSED','COMPLETED')"`
+	LastError       string                     `json:"last_error"`
+	LineItems       []RecurringInvoiceLineItem `json:"line_items" gorm:"foreignKey:RecurringInvoiceID"`
+	CreatedAt       time.Time                  `json:"created_at"`
+	UpdatedAt       time.Time                  `json:"updated_at"`
+}
+
+// RecurringInvoiceLineItem is a line copied onto every generated invoice
+type RecurringInvoiceLineItem struct {
+	ID                 uint           `json:"id" gorm:"primaryKey"`
+	RecurringInvoiceID uint           `json:"recurring_invoice_id"`
+	ItemID             *uint          `json:"item_id"`
+	Item               *Item          `json:"item,omitempty" gorm:"foreignKey:ItemID"`
+	Description        string         `json:"description" gorm:"not null"`
+	HSNCode            string         `json:"hsn_code" gorm:"size:8"`
+	Quantity           money.Quantity `json:"quantity" gorm:"type:decimal(10,3)"`
+	Rate               money.Amount   `json:"rate" gorm:"type:decimal(15,2)"`
+	GSTRate            int            `json:"gst_rate"`
+	Discount           `gorm:"embedded"`
+}
+
+// RecurringInvoiceRun records one attempt to generate the invoice for a
+// period of a schedule. At most one successful run can exist per period,
+// which keeps generation exactly-once across restarts and concurrent
+// schedulers.
+type RecurringInvoiceRun struct {
+	ID                 uint      `json:"id" gorm:"primaryKey"`
+	RecurringInvoiceID uint      `json:"recurring_invoice_id" gorm:"not null;index;uniqueIndex:idx_recurring_runs_period,where:status = 'SUCCESS'"`
+	PeriodDate         time.Time `json:"period_date" gorm:"not null;uniqueIndex:idx_recurring_runs_period,where:status = 'SUCCESS'"`
+	Status             string    `json:"status" gorm:"not null;check:status IN ('SUCCESS','FAILED')"`
+	InvoiceID          *uint     `json:"invoice_id"`
+	Attempts           int       `json:"attempts" gorm:"not null;default:1"`
+	Error              string    `json:"error"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// IdempotencyKey records a request made with an Idempotency-Key header so
+// that retries of it return the original response instead of repeating it.
+// Fingerprint is a hash of the method, path and body of the first request.
+type IdempotencyKey struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	UserID       uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_idempotency_user_key"`
+	Key          string    `json:"key" gorm:"size:255;not null;uniqueIndex:idx_idempotency_user_key"`
+	Fingerprint  string    `json:"fingerprint" gorm:"size:64;not null"`
+	Status       string    `json:"status" gorm:"not null;check:status IN ('IN_PROGRESS','COMPLETED')"`
+	ResponseCode int       `json:"response_code"`
+	ContentType  string    `json:"content_type"`
+	ResponseBody []byte    `json:"-"`
+	ExpiresAt    time.Time `json:"expires_at" gorm:"index"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// StatusChangeRequest carries the reason for voiding or cancelling a document
+type StatusChangeRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// IRNCancelRequest carries the IRP reason code and remarks for cancelling
+// an invoice's IRN
+type IRNCancelRequest struct {
+	ReasonCode string `json:"reason_code" binding:"required"`
+	Remarks    string `json:"remarks" binding:"required"`
 }
 
 // LoginRequest represents login request data
@@ -102,23 +746,116 @@ type LoginRequest struct {
 
 // DashboardStats represents dashboard statistics
 type DashboardStats struct {
-	TodaySales       float64 `json:"today_sales"`
-	TodayCredit      float64 `json:"today_credit"`
-	TodayDebit       float64 `json:"today_debit"`
-	TotalReceivables float64 `json:"total_receivables"`
-	TotalPayables    float64 `json:"total_payables"`
-	PendingInvoices  int64   `json:"pending_invoices"`
-	TotalInvoices    int64   `json:"total_invoices"`
-	ThisMonthSales   float64 `json:"this_month_sales"`
-	LastMonthSales   float64 `json:"last_month_sales"`
+	TodaySales         money.Amount `json:"today_sales"`
+	TodayCredit        money.Amount `json:"today_credit"`
+	TodayDebit         money.Amount `json:"today_debit"`
+	TotalReceivables   money.Amount `json:"total_receivables"`
+	TotalPayables      money.Amount `json:"total_payables"`
+	PendingInvoices    int64        `json:"pending_invoices"`
+	TotalInvoices      int64        `json:"total_invoices"`
+	ThisMonthSales     money.Amount `json:"this_month_sales"`
+	LastMonthSales     money.Amount `json:"last_month_sales"`
+	ThisMonthCGST      money.Amount `json:"this_month_cgst"`
+	ThisMonthSGST      money.Amount `json:"this_month_sgst"`
+	ThisMonthIGST      money.Amount `json:"this_month_igst"`
+	ThisMonthCredits   money.Amount `json:"this_month_credit_notes"`
+	ThisMonthDebits    money.Amount `json:"this_month_debit_notes"`
+	ThisMonthDiscounts money.Amount `json:"this_month_discounts"`
+
+	TotalQuotations         int64        `json:"total_quotations"`
+	ConvertedQuotations     int64        `json:"converted_quotations"`      // Quotations with at least one issued invoice
+	QuotationConversionRate float64      `json:"quotation_conversion_rate"` // Percentage of quotations converted
+	OpenQuotationValue      money.Amount `json:"open_quotation_value"`
 }
 
 // AdminStats represents admin dashboard statistics
 type AdminStats struct {
-	TotalUsers    int64   `json:"total_users"`
-	TotalInvoices int64   `json:"total_invoices"`
-	TotalAmount   float64 `json:"total_amount"`
-	PendingAmount float64 `json:"pending_amount"`
-	TodayInvoices int64   `json:"today_invoices"`
-	TodayAmount   float64 `json:"today_amount"`
+	TotalUsers    int64        `json:"total_users"`
+	TotalInvoices int64        `json:"total_invoices"`
+	TotalAmount   money.Amount `json:"total_amount"`
+	PendingAmount money.Amount `json:"pending_amount"`
+	TodayInvoices int64        `json:"today_invoices"`
+	TodayAmount   money.Amount `json:"today_amount"`
+	TotalCGST     money.Amount `json:"total_cgst"`
+	TotalSGST     money.Amount `json:"total_sgst"`
+	TotalIGST     money.Amount `json:"total_igst"`
+	TotalDiscount money.Amount `json:"total_discount"`
+}
+
+// ReturnError is a document left out of a GST return because a field the
+// return requires is missing or invalid
+type ReturnError struct {
+	DocumentType   string `json:"document_type"` // INVOICE, CREDIT_NOTE or DEBIT_NOTE
+	DocumentID     uint   `json:"document_id"`
+	DocumentNumber string `json:"document_number"`
+	Field          string `json:"field"`
+	Message        string `json:"message"`
+}
+
+// TaxSummary is a taxable value and the GST on it
+type TaxSummary struct {
+	TaxableValue money.Amount `json:"taxable_value"`
+	IGST         money.Amount `json:"igst"`
+	CGST         money.Amount `json:"cgst"`
+	SGST         money.Amount `json:"sgst"`
+	Cess         money.Amount `json:"cess"`
+}
+
+// StateSupplySummary is the total of inter-state supplies to unregistered
+// buyers in one state
+type StateSupplySummary struct {
+	PlaceOfSupply string       `json:"place_of_supply"`
+	StateName     string       `json:"state_name"`
+	TaxableValue  money.Amount `json:"taxable_value"`
+	IGST          money.Amount `json:"igst"`
+}
+
+// GSTR3BSummary is a GSTR-3B style summary of a seller's outward supplies,
+// input tax credit and tax payable for a period. Credit and debit notes
+// dated in the period are netted into the figures they adjust.
+type GSTR3BSummary struct {
+	GSTIN  string    `json:"gstin"`
+	Period string    `json:"period"` // MMYYYY
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"` // Last day of the period
+
+	OutwardTaxable         TaxSummary           `json:"outward_taxable"`          // 3.1(a): taxable supplies other than zero rated, nil rated and exempt
+	OutwardZeroRated       TaxSummary           `json:"outward_zero_rated"`       // 3.1(b): exports
+	OutwardNilExempt       TaxSummary           `json:"outward_nil_exempt"`       // 3.1(c): supplies at a 0% rate
+	InterStateUnregistered []StateSupplySummary `json:"inter_state_unregistered"` // 3.2: included in 3.1(a)
+
+	ITCAvailable TaxSummary `json:"itc_available"` // 4(A)(5): GST on invoices received from other sellers
+	ITCReversed  TaxSummary `json:"itc_reversed"`  // 4(B)
+	NetITC       TaxSummary `json:"net_itc"`       // 4(C)
+
+	OutputTax       TaxSummary `json:"output_tax"`        // Tax on all outward supplies
+	PaidThroughITC  TaxSummary `json:"paid_through_itc"`  // Output tax set off against input tax credit, by head of the tax paid
+	PayableInCash   TaxSummary `json:"payable_in_cash"`   // Output tax left after set-off
+	ITCCarryForward TaxSummary `json:"itc_carry_forward"` // Input tax credit left after set-off
+}
+
+// HSNSummary is the HSN/SAC-wise total of a seller's outward supplies for
+// a date range, net of credit and debit notes
+type HSNSummary struct {
+	From            time.Time       `json:"from"`
+	To              time.Time       `json:"to"`
+	Rows            []HSNSummaryRow `json:"rows"`
+	Total           TaxSummary      `json:"total"`
+	LinesWithoutHSN int             `json:"lines_without_hsn"` // Reported under an empty HSN code
+}
+
+// HSNSummaryRow is the total of supplies under one HSN or SAC code at one
+// GST rate
+type HSNSummaryRow struct {
+	HSNCode      string         `json:"hsn_code"`
+	Description  string         `json:"description"`
+	UQC          string         `json:"uqc"` // GST unit quantity code, e.g. NOS or KGS; NA for services
+	Quantity     money.Quantity `json:"quantity"`
+	GSTRate      int            `json:"gst_rate"`
+	TaxableValue money.Amount   `json:"taxable_value"`
+	IGST         money.Amount   `json:"igst"`
+	CGST         money.Amount   `json:"cgst"`
+	SGST         money.Amount   `json:"sgst"`
+	Cess         money.Amount   `json:"cess"`
+	TotalValue   money.Amount   `json:"total_value"`
 }
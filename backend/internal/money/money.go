@@ -0,0 +1,382 @@
+// Package money provides exact fixed-point types for currency amounts and
+// quantities.
+//
+// Amounts are held as whole paise and quantities as thousandths of a unit,
+// so sums never drift the way float64 arithmetic does. Values are stored in
+// NUMERIC columns and serialised to JSON as plain numbers (e.g. 1234.50).
+//
+// Rounding rules used throughout the invoice pipeline:
+//
+//   - Every multiplication or percentage is rounded to the nearest paisa,
+//     with halves rounded away from zero.
+//   - Line values are rounded per line: the line amount (quantity x rate)
+//     is rounded first, and each tax component (CGST, SGST, IGST) is then
+//     computed on that rounded amount and rounded on its own.
+//   - Invoice totals are the exact sums of the rounded line values; totals
+//     are never recomputed from unrounded figures.
+package money
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Amount is a monetary value in paise
+type Amount int64
+
+// Quantity is a quantity in thousandths of a unit
+type Quantity int64
+
+// Zero is the zero amount
+const Zero Amount = 0
+
+const (
+	paisePerRupee     = 100
+	thousandthsPerOne = 1000
+)
+
+// FromPaise creates an amount from a number of paise
+func FromPaise(paise int64) Amount {
+	return Amount(paise)
+}
+
+// FromRupees creates an amount from whole rupees
+func FromRupees(rupees int64) Amount {
+	return Amount(rupees * paisePerRupee)
+}
+
+// ParseAmount parses a decimal string such as "1234.5" into an amount,
+// rounding to the nearest paisa
+func ParseAmount(s string) (Amount, error) {
+	v, err := parseFixed(s, 2)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	return Amount(v), nil
+}
+
+// Paise returns the amount in paise
+func (a Amount) Paise() int64 {
+	return int64(a)
+}
+
+// Float64 returns the amount in rupees as a float, for display and
+// interoperability only
+func (a Amount) Float64() float64 {
+	return float64(a) / paisePerRupee
+}
+
+// String formats the amount with two decimals, e.g. "-12.05"
+func (a Amount) String() string {
+	return formatFixed(int64(a), 2)
+}
+
+// IsZero reports whether the amount is zero
+func (a Amount) IsZero() bool {
+	return a == 0
+}
+
+// IsPositive reports whether the amount is greater than zero
+func (a Amount) IsPositive() bool {
+	return a > 0
+}
+
+// IsNegative reports whether the amount is less than zero
+func (a Amount) IsNegative() bool {
+	return a < 0
+}
+
+// Abs returns the absolute value of the amount
+func (a Amount) Abs() Amount {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+// Percent returns rate percent of the amount, rounded to the nearest paisa
+func (a Amount) Percent(rate int) Amount {
+	return Amount(mulDivRound(int64(a), int64(rate), 100))
+}
+
+// PercentOf returns numerator/denominator percent of the amount, rounded
+// to the nearest paisa. It is used for fractional rates such as the half
+// rate applied for each of CGST and SGST.
+func (a Amount) PercentOf(numerator, denominator int64) Amount {
+	return Amount(mulDivRound(int64(a), numerator, 100*denominator))
+}
+
+// Allocate returns the share of the amount proportional to part/whole,
+// rounded to the nearest paisa
+func (a Amount) Allocate(part, whole Amount) Amount {
+	if whole == 0 {
+		return 0
+	}
+	return Amount(mulDivRound(int64(a), int64(part), int64(whole)))
+}
+
+// Prorate returns the share of the amount proportional to the quantities
+// part/whole, rounded to the nearest paisa
+func (a Amount) Prorate(part, whole Quantity) Amount {
+	if whole == 0 {
+		return 0
+	}
+	return Amount(mulDivRound(int64(a), int64(part), int64(whole)))
+}
+
+// Min returns the smaller of two amounts
+func Min(a, b Amount) Amount {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Max returns the larger of two amounts
+func Max(a, b Amount) Amount {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// MarshalJSON encodes the amount as a JSON number with two decimals
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or numeric string
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	v, err := unmarshalFixed(data, 2)
+	if err != nil {
+		return fmt.Errorf("invalid amount %s", data)
+	}
+	*a = Amount(v)
+	return nil
+}
+
+// Scan implements sql.Scanner for NUMERIC columns
+func (a *Amount) Scan(src interface{}) error {
+	v, err := scanFixed(src, 2)
+	if err != nil {
+		return err
+	}
+	*a = Amount(v)
+	return nil
+}
+
+// Value implements driver.Valuer
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+// GormDataType declares the column type used when no type tag is given
+func (Amount) GormDataType() string {
+	return "decimal(15,2)"
+}
+
+// QuantityFromInt creates a quantity of whole units
+func QuantityFromInt(units int64) Quantity {
+	return Quantity(units * thousandthsPerOne)
+}
+
+// ParseQuantity parses a decimal string such as "2.5" into a quantity
+func ParseQuantity(s string) (Quantity, error) {
+	v, err := parseFixed(s, 3)
+	if err != nil {
+		return 0, fmt.Errorf("invalid quantity %q", s)
+	}
+	return Quantity(v), nil
+}
+
+// Thousandths returns the quantity in thousandths of a unit
+func (q Quantity) Thousandths() int64 {
+	return int64(q)
+}
+
+// Float64 returns the quantity as a float, for display only
+func (q Quantity) Float64() float64 {
+	return float64(q) / thousandthsPerOne
+}
+
+// String formats the quantity without trailing zeros, e.g. "2.5"
+func (q Quantity) String() string {
+	s := formatFixed(int64(q), 3)
+	return strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+}
+
+// MulAmount returns quantity x rate rounded to the nearest paisa
+func (q Quantity) MulAmount(rate Amount) Amount {
+	return Amount(mulDivRound(int64(q), int64(rate), thousandthsPerOne))
+}
+
+// MarshalJSON encodes the quantity as a JSON number
+func (q Quantity) MarshalJSON() ([]byte, error) {
+	return []byte(q.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or numeric string
+func (q *Quantity) UnmarshalJSON(data []byte) error {
+	v, err := unmarshalFixed(data, 3)
+	if err != nil {
+		return fmt.Errorf("invalid quantity %s", data)
+	}
+	*q = Quantity(v)
+	return nil
+}
+
+// Scan implements sql.Scanner for NUMERIC columns
+func (q *Quantity) Scan(src interface{}) error {
+	v, err := scanFixed(src, 3)
+	if err != nil {
+		return err
+	}
+	*q = Quantity(v)
+	return nil
+}
+
+// Value implements driver.Valuer
+func (q Quantity) Value() (driver.Value, error) {
+	return formatFixed(int64(q), 3), nil
+}
+
+// GormDataType declares the column type used when no type tag is given
+func (Quantity) GormDataType() string {
+	return "decimal(10,3)"
+}
+
+// mulDivRound computes a*b/d rounded half away from zero without overflow
+func mulDivRound(a, b, d int64) int64 {
+	n := new(big.Int).Mul(big.NewInt(a), big.NewInt(b))
+	den := big.NewInt(d)
+	q, r := new(big.Int).QuoRem(n, den, new(big.Int))
+
+	// Round half away from zero: compare 2|r| with |d|
+	r2 := new(big.Int).Abs(r)
+	r2.Lsh(r2, 1)
+	if r2.Cmp(new(big.Int).Abs(den)) >= 0 {
+		if (n.Sign() < 0) != (den.Sign() < 0) {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q.Int64()
+}
+
+// parseFixed parses a plain decimal string into an integer scaled by
+// 10^scale, rounding extra digits half away from zero
+func parseFixed(s string, scale int) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, errors.New("empty value")
+	}
+
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	whole, fraction, _ := strings.Cut(s, ".")
+	if whole == "" && fraction == "" {
+		return 0, errors.New("no digits")
+	}
+	for _, part := range []string{whole, fraction} {
+		for _, c := range part {
+			if c < '0' || c > '9' {
+				return 0, errors.New("invalid character")
+			}
+		}
+	}
+
+	roundUp := false
+	if len(fraction) > scale {
+		roundUp = fraction[scale] >= '5'
+		fraction = fraction[:scale]
+	}
+	fraction += strings.Repeat("0", scale-len(fraction))
+
+	digits := strings.TrimLeft(whole+fraction, "0")
+	if digits == "" {
+		digits = "0"
+	}
+	v, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if roundUp {
+		v++
+	}
+	if negative {
+		v = -v
+	}
+	return v, nil
+}
+
+// formatFixed formats an integer scaled by 10^scale as a decimal string
+func formatFixed(v int64, scale int) string {
+	sign := ""
+	u := uint64(v)
+	if v < 0 {
+		sign = "-"
+		u = uint64(-v)
+	}
+	s := strconv.FormatUint(u, 10)
+	if len(s) <= scale {
+		s = strings.Repeat("0", scale-len(s)+1) + s
+	}
+	return sign + s[:len(s)-scale] + "." + s[len(s)-scale:]
+}
+
+// unmarshalFixed decodes a JSON number, numeric string or null
+func unmarshalFixed(data []byte, scale int) (int64, error) {
+	text := strings.TrimSpace(string(data))
+	if text == "null" {
+		return 0, nil
+	}
+	if strings.HasPrefix(text, `"`) {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return 0, err
+		}
+		text = s
+	}
+	if strings.ContainsAny(text, "eE") {
+		// Exponent notation: go through big.Float, which is exact enough
+		// for any value that fits the column
+		f, _, err := big.ParseFloat(text, 10, 128, big.ToNearestAway)
+		if err != nil {
+			return 0, err
+		}
+		text = f.Text('f', scale+1)
+	}
+	return parseFixed(text, scale)
+}
+
+// scanFixed converts a database value into an integer scaled by 10^scale
+func scanFixed(src interface{}, scale int) (int64, error) {
+	switch v := src.(type) {
+	case nil:
+		return 0, nil
+	case string:
+		return parseFixed(v, scale)
+	case []byte:
+		return parseFixed(string(v), scale)
+	case int64:
+		return v * int64(math.Pow10(scale)), nil
+	case float64:
+		return int64(math.Round(v * math.Pow10(scale))), nil
+	default:
+		return 0, fmt.Errorf("cannot scan %T into a fixed-point value", src)
+	}
+}
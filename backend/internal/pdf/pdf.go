@@ -0,0 +1,224 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Page sizes in PDF points (1/72 inch)
+const (
+	A4Width  = 595.28
+	A4Height = 841.89
+)
+
+// Font identifies one of the standard Type 1 fonts embedded by every PDF reader
+type Font int
+
+// Supported fonts
+const (
+	Helvetica Font = iota
+	HelveticaBold
+)
+
+var fontNames = []string{"Helvetica", "Helvetica-Bold"}
+
+// Document is a minimal PDF writer that supports text in the standard
+// Helvetica fonts, lines and rectangles. It has no external dependencies.
+type Document struct {
+	pages []*Page
+}
+
+// Page is a single page of a document. Coordinates passed to drawing
+// methods are in points measured from the top-left corner of the page.
+type Page struct {
+	Width   float64
+	Height  float64
+	content bytes.Buffer
+}
+
+// New creates an empty document
+func New() *Document {
+	return &Document{}
+}
+
+// AddPage appends a new A4 portrait page to the document
+func (d *Document) AddPage() *Page {
+	p := &Page{Width: A4Width, Height: A4Height}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+// PageCount returns the number of pages in the document
+func (d *Document) PageCount() int {
+	return len(d.pages)
+}
+
+// Text draws s with its baseline starting at (x, y)
+func (p *Page) Text(x, y float64, font Font, size float64, s string) {
+	fmt.Fprintf(&p.content, "BT /F%d %s Tf %s %s Td (%s) Tj ET\n",
+		font+1, num(size), num(x), num(p.Height-y), escape(s))
+}
+
+// TextRight draws s so that it ends at x
+func (p *Page) TextRight(x, y float64, font Font, size float64, s string) {
+	p.Text(x-TextWidth(font, size, s), y, font, size, s)
+}
+
+// TextCenter draws s centred on x
+func (p *Page) TextCenter(x, y float64, font Font, size float64, s string) {
+	p.Text(x-TextWidth(font, size, s)/2, y, font, size, s)
+}
+
+// Line draws a straight line between two points
+func (p *Page) Line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(&p.content, "%s w %s %s m %s %s l S\n",
+		num(width), num(x1), num(p.Height-y1), num(x2), num(p.Height-y2))
+}
+
+// Rect draws a rectangle whose top-left corner is at (x, y). When fill is
+// true the rectangle is filled with the current fill colour, otherwise only
+// its outline is stroked.
+func (p *Page) Rect(x, y, w, h, lineWidth float64, fill bool) {
+	op := "S"
+	if fill {
+		op = "f"
+	}
+	fmt.Fprintf(&p.content, "%s w %s %s %s %s re %s\n",
+		num(lineWidth), num(x), num(p.Height-y-h), num(w), num(h), op)
+}
+
+// SetFillGray sets the fill colour used by text and filled rectangles,
+// where 0 is black and 1 is white
+func (p *Page) SetFillGray(g float64) {
+	fmt.Fprintf(&p.content, "%s g\n", num(g))
+}
+
+// SetStrokeGray sets the colour used by lines and outlines
+func (p *Page) SetStrokeGray(g float64) {
+	fmt.Fprintf(&p.content, "%s G\n", num(g))
+}
+
+// Bytes renders the document to a byte slice
+func (d *Document) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := d.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteTo writes the complete PDF file to w
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+
+	// Object layout: 1 catalog, 2 page tree, one object per font, then a
+	// page object followed by its content stream for every page.
+	fontStart := 3
+	pageStart := fontStart + len(fontNames)
+	objCount := pageStart + 2*len(d.pages) - 1
+
+	var buf bytes.Buffer
+	offsets := make([]int, objCount+1)
+	begin := func(id int) {
+		offsets[id] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n", id)
+	}
+	end := func() {
+		buf.WriteString("endobj\n")
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	begin(1)
+	buf.WriteString("<< /Type /Catalog /Pages 2 0 R >>\n")
+	end()
+
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", pageStart+2*i)
+	}
+	begin(2)
+	fmt.Fprintf(&buf, "<< /Type /Pages /Kids [%s] /Count %d >>\n", strings.Join(kids, " "), len(d.pages))
+	end()
+
+	var fontRefs strings.Builder
+	for i, name := range fontNames {
+		begin(fontStart + i)
+		fmt.Fprintf(&buf, "<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>\n", name)
+		end()
+		fmt.Fprintf(&fontRefs, "/F%d %d 0 R ", i+1, fontStart+i)
+	}
+
+	for i, p := range d.pages {
+		pageID := pageStart + 2*i
+		begin(pageID)
+		fmt.Fprintf(&buf, "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << %s>> >> /Contents %d 0 R >>\n",
+			num(p.Width), num(p.Height), fontRefs.String(), pageID+1)
+		end()
+
+		begin(pageID + 1)
+		fmt.Fprintf(&buf, "<< /Length %d >>\nstream\n", p.content.Len())
+		buf.Write(p.content.Bytes())
+		buf.WriteString("endstream\n")
+		end()
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", objCount+1)
+	for id := 1; id <= objCount; id++ {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offsets[id])
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", objCount+1, xref)
+
+	n, err := w.Write(buf.Bytes())
+	return int64(n), err
+}
+
+// num formats a coordinate with at most two decimals
+func num(f float64) string {
+	s := fmt.Sprintf("%.2f", f)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "" || s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// escape converts s to a WinAnsi encoded PDF string literal body
+func escape(s string) string {
+	var b strings.Builder
+	for _, c := range encode(s) {
+		switch c {
+		case '(', ')', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// encode maps s onto the WinAnsi character set. Characters outside Latin-1
+// are replaced, with the rupee sign spelled out since the standard fonts
+// have no glyph for it.
+func encode(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r == '₹':
+			out = append(out, "Rs."...)
+		case r == '\n' || r == '\t':
+			out = append(out, ' ')
+		case r >= 0x20 && r < 0x7f, r >= 0xa0 && r <= 0xff:
+			out = append(out, byte(r))
+		default:
+			out = append(out, '?')
+		}
+	}
+	return out
+}
@@ -0,0 +1,66 @@
+package qr
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+)
+
+// QuietZone is the width in modules of the light margin scanners need
+// around a code
+const QuietZone = 4
+
+// PNG renders the code as a black and white PNG image with the given
+// number of pixels per module, including the quiet zone
+func (c *Code) PNG(module int) ([]byte, error) {
+	side := (c.Size + 2*QuietZone) * module
+	img := image.NewPaletted(image.Rect(0, 0, side, side), color.Palette{color.White, color.Black})
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.Dark(x, y) {
+				continue
+			}
+			top, left := (y+QuietZone)*module, (x+QuietZone)*module
+			for py := top; py < top+module; py++ {
+				for px := left; px < left+module; px++ {
+					img.SetColorIndex(px, py, 1)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := encoder.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SVG renders the code as an SVG image with the given size in pixels per
+// module, including the quiet zone. Each row's runs of dark modules are
+// drawn as one rectangle of a single path.
+func (c *Code) SVG(module int) []byte {
+	side := c.Size + 2*QuietZone
+	var path strings.Builder
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; {
+			if !c.Dark(x, y) {
+				x++
+				continue
+			}
+			start := x
+			for x < c.Size && c.Dark(x, y) {
+				x++
+			}
+			fmt.Fprintf(&path, "M%d %dh%dv1h-%dz", start+QuietZone, y+QuietZone, x-start, x-start)
+		}
+	}
+
+	return []byte(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+
+		`<rect width="%d" height="%d" fill="#fff"/><path d="%s" fill="#000"/></svg>`,
+		side*module, side*module, side, side, side, side, path.String()))
+}
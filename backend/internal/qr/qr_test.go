@@ -0,0 +1,474 @@
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReedSolomonRemainder(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		ecc  []byte
+	}{
+		{
+			// ISO/IEC 18004 Annex I: "01234567" as a 1-M code
+			name: "ISO 01234567 1-M",
+			data: []byte{0x10, 0x20, 0x0C, 0x56, 0x61, 0x80, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11},
+			ecc:  []byte{0xA5, 0x24, 0xD4, 0xC1, 0xED, 0x36, 0xC7, 0x87, 0x2C, 0x55},
+		},
+		{
+			// "HELLO WORLD" as a 1-M code
+			name: "HELLO WORLD 1-M",
+			data: []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17},
+			ecc:  []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := reedSolomonRemainder(tt.data, reedSolomonDivisor(len(tt.ecc)))
+			if !bytes.Equal(got, tt.ecc) {
+				t.Errorf("ecc = % X, want % X", got, tt.ecc)
+			}
+		})
+	}
+}
+
+func TestFormatBits(t *testing.T) {
+	// ISO/IEC 18004 Table C.1, by level and mask
+	want := map[Level][8]string{
+		Low:      {"111011111000100", "111001011110011", "111110110101010", "111100010011101", "110011000101111", "110001100011000", "110110001000001", "110100101110110"},
+		Medium:   {"101010000010010", "101000100100101", "101111001111100", "101101101001011", "100010111111001", "100000011001110", "100111110010111", "100101010100000"},
+		Quartile: {"011010101011111", "011000001101000", "011111100110001", "011101000000110", "010010010110100", "010000110000011", "010111011011010", "010101111101101"},
+		High:     {"001011010001001", "001001110111110", "001110011100111", "001100111010000", "000011101100010", "000001001010101", "000110100001100", "000100000111011"},
+	}
+
+	for level, masks := range want {
+		for mask, bits := range masks {
+			m := newMatrix(1)
+			m.drawFormatBits(level, mask)
+			if got := readFormatBits(m.modules); got != bits {
+				t.Errorf("level %d mask %d: format bits = %s, want %s", level, mask, got, bits)
+			}
+		}
+	}
+}
+
+func TestVersionBits(t *testing.T) {
+	// ISO/IEC 18004 Table D.1, versions 7 to 40
+	want := []int{
+		0x07C94, 0x085BC, 0x09A99, 0x0A4D3, 0x0BBF6, 0x0C762, 0x0D847, 0x0E60D,
+		0x0F928, 0x10B78, 0x1145D, 0x12A17, 0x13532, 0x149A6, 0x15683, 0x168C9,
+		0x177EC, 0x18EC4, 0x191E1, 0x1AFAB, 0x1B08E, 0x1CC1A, 0x1D33F, 0x1ED75,
+		0x1F250, 0x209D5, 0x216F0, 0x228BA, 0x2379F, 0x24B0B, 0x2542E, 0x26A64,
+		0x27541, 0x28C69,
+	}
+
+	for i, bits := range want {
+		version := i + 7
+		m := newMatrix(version)
+		m.drawVersion()
+		var got int
+		for j := 0; j < 18; j++ {
+			if m.modules[j/3][m.size-11+j%3] {
+				got |= 1 << j
+			}
+		}
+		if got != bits {
+			t.Errorf("version %d: version bits = %05X, want %05X", version, got, bits)
+		}
+	}
+}
+
+func TestAlignmentPositions(t *testing.T) {
+	// ISO/IEC 18004 Annex E
+	tests := map[int][]int{
+		1:  nil,
+		2:  {6, 18},
+		7:  {6, 22, 38},
+		14: {6, 26, 46, 66},
+		16: {6, 26, 50, 74},
+		32: {6, 34, 60, 86, 112, 138},
+		36: {6, 24, 50, 76, 102, 128, 154},
+		40: {6, 30, 58, 86, 114, 142, 170},
+	}
+
+	for version, want := range tests {
+		got := newMatrix(version).alignmentPositions()
+		if !equalInts(got, want) {
+			t.Errorf("version %d: alignment positions = %v, want %v", version, got, want)
+		}
+	}
+}
+
+func TestCodewordCapacity(t *testing.T) {
+	// Total codewords of each version, ISO/IEC 18004 Table 1
+	total := []int{0,
+		26, 44, 70, 100, 134, 172, 196, 242, 292, 346,
+		404, 466, 532, 581, 655, 733, 815, 901, 991, 1085,
+		1156, 1258, 1364, 1474, 1588, 1706, 1828, 1921, 2051, 2185,
+		2323, 2465, 2611, 2761, 2876, 3034, 3196, 3362, 3532, 3706,
+	}
+	for version := 1; version <= 40; version++ {
+		if got := numRawDataModules(version) / 8; got != total[version] {
+			t.Errorf("version %d: total codewords = %d, want %d", version, got, total[version])
+		}
+	}
+
+	// Data codewords of each version by level, ISO/IEC 18004 Table 7. With
+	// the totals above these pin down the error correction of every block
+	// table entry.
+	data := [][4]int{{},
+		{19, 16, 13, 9},
+		{34, 28, 22, 16},
+		{55, 44, 34, 26},
+		{80, 64, 48, 36},
+		{108, 86, 62, 46},
+		{136, 108, 76, 60},
+		{156, 124, 88, 66},
+		{194, 154, 110, 86},
+		{232, 182, 132, 100},
+		{274, 216, 154, 122},
+		{324, 254, 180, 140},
+		{370, 290, 206, 158},
+		{428, 334, 244, 180},
+		{461, 365, 261, 197},
+		{523, 415, 295, 223},
+		{589, 453, 325, 253},
+		{647, 507, 367, 283},
+		{721, 563, 397, 313},
+		{795, 627, 445, 341},
+		{861, 669, 485, 385},
+		{932, 714, 512, 406},
+		{1006, 782, 568, 442},
+		{1094, 860, 614, 464},
+		{1174, 914, 664, 514},
+		{1276, 1000, 718, 538},
+		{1370, 1062, 754, 596},
+		{1468, 1128, 808, 628},
+		{1531, 1193, 871, 661},
+		{1631, 1267, 911, 701},
+		{1735, 1373, 985, 745},
+		{1843, 1455, 1033, 793},
+		{1955, 1541, 1115, 845},
+		{2071, 1631, 1171, 901},
+		{2191, 1725, 1231, 961},
+		{2306, 1812, 1286, 986},
+		{2434, 1914, 1354, 1054},
+		{2566, 1992, 1426, 1096},
+		{2702, 2102, 1502, 1142},
+		{2812, 2216, 1582, 1222},
+		{2956, 2334, 1666, 1276},
+	}
+	for version := 1; version <= 40; version++ {
+		for level := Low; level <= High; level++ {
+			if got := numDataCodewords(version, level); got != data[version][level] {
+				t.Errorf("version %d level %d: data codewords = %d, want %d", version, level, got, data[version][level])
+			}
+		}
+	}
+}
+
+func TestBlockSplit(t *testing.T) {
+	// Spot checks of ISO/IEC 18004 Table 9: blocks in each group and data
+	// codewords per block
+	tests := []struct {
+		version        int
+		level          Level
+		short, long    int
+		shortData, ecc int
+	}{
+		{1, Medium, 1, 0, 16, 10},
+		{5, Quartile, 2, 2, 15, 18},
+		{10, Medium, 4, 1, 43, 26},
+		{21, Low, 4, 4, 116, 28},
+		{40, High, 20, 61, 15, 30},
+	}
+	for _, tt := range tests {
+		short, long, shortData := blockGroups(tt.version, tt.level)
+		if short != tt.short || long != tt.long || shortData != tt.shortData || eccCodewordsPerBlock[tt.level][tt.version] != tt.ecc {
+			t.Errorf("version %d level %d: %d blocks of %d and %d of %d with %d ecc, want %d of %d and %d of %d with %d ecc",
+				tt.version, tt.level, short, shortData, long, shortData+1, eccCodewordsPerBlock[tt.level][tt.version],
+				tt.short, tt.shortData, tt.long, tt.shortData+1, tt.ecc)
+		}
+	}
+
+	// Every version and level: deinterleave the output and check each
+	// block holds its share of the data followed by its error correction
+	for version := 1; version <= 40; version++ {
+		for level := Low; level <= High; level++ {
+			data := make([]byte, numDataCodewords(version, level))
+			for i := range data {
+				data[i] = byte(i*7 + version)
+			}
+			out := addECCAndInterleave(data, version, level)
+			if len(out) != numRawDataModules(version)/8 {
+				t.Fatalf("version %d level %d: %d codewords, want %d", version, level, len(out), numRawDataModules(version)/8)
+			}
+
+			short, long, shortData := blockGroups(version, level)
+			eccLen := eccCodewordsPerBlock[level][version]
+			blocks := deinterleave(out, short, long, shortData, eccLen)
+			var k int
+			for i, block := range blocks {
+				n := len(block) - eccLen
+				if !bytes.Equal(block[:n], data[k:k+n]) {
+					t.Fatalf("version %d level %d block %d: data out of place", version, level, i)
+				}
+				if ecc := reedSolomonRemainder(data[k:k+n], reedSolomonDivisor(eccLen)); !bytes.Equal(block[n:], ecc) {
+					t.Fatalf("version %d level %d block %d: wrong error correction", version, level, i)
+				}
+				k += n
+			}
+			if k != len(data) {
+				t.Fatalf("version %d level %d: blocks hold %d data codewords, want %d", version, level, k, len(data))
+			}
+		}
+	}
+}
+
+func TestEncodeVersion1Medium(t *testing.T) {
+	code, err := Encode("hello", Medium)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code.Version != 1 || code.Size != 21 {
+		t.Fatalf("version %d size %d, want version 1 size 21", code.Version, code.Size)
+	}
+
+	// Byte mode, count 5, "hello", terminator and padding, then the error
+	// correction of those data codewords
+	wantData := []byte{0x40, 0x56, 0x86, 0x56, 0xC6, 0xC6, 0xF0, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC}
+	want := append(wantData, reedSolomonRemainder(wantData, reedSolomonDivisor(10))...)
+	if got := readCodewords(t, code, Medium); !bytes.Equal(got, want) {
+		t.Errorf("codewords = % X, want % X", got, want)
+	}
+
+	// Finder patterns in three corners and timing patterns between them
+	for _, corner := range [][2]int{{0, 0}, {14, 0}, {0, 14}} {
+		for dy := 0; dy < 7; dy++ {
+			for dx := 0; dx < 7; dx++ {
+				ring := max(abs(dx-3), abs(dy-3))
+				if want := ring != 2; code.Dark(corner[0]+dx, corner[1]+dy) != want {
+					t.Fatalf("finder at %v: module (%d, %d) dark = %t", corner, dx, dy, !want)
+				}
+			}
+		}
+	}
+	for i := 8; i < 13; i++ {
+		if code.Dark(i, 6) != (i%2 == 0) || code.Dark(6, i) != (i%2 == 0) {
+			t.Fatalf("timing pattern wrong at %d", i)
+		}
+	}
+	if !code.Dark(8, code.Size-8) {
+		t.Error("dark module is light")
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	text := strings.Repeat("upi://pay?pa=acme@okhdfcbank&am=1180.50&cu=INR ", 60)
+	for _, n := range []int{1, 17, 100, 500, 1200, 2300} {
+		for level := Low; level <= High; level++ {
+			data := text[:n]
+			code, err := Encode(data, level)
+			if errors.Is(err, ErrTooLong) {
+				continue
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			codewords := readCodewords(t, code, level)
+			short, long, shortData := blockGroups(code.Version, level)
+			eccLen := eccCodewordsPerBlock[level][code.Version]
+			var joined []byte
+			for _, block := range deinterleave(codewords, short, long, shortData, eccLen) {
+				n := len(block) - eccLen
+				if ecc := reedSolomonRemainder(block[:n], reedSolomonDivisor(eccLen)); !bytes.Equal(block[n:], ecc) {
+					t.Fatalf("%d bytes level %d: wrong error correction", len(data), level)
+				}
+				joined = append(joined, block[:n]...)
+			}
+			if got := decodeBytes(joined, code.Version); got != data {
+				t.Fatalf("%d bytes level %d: decoded %q", len(data), level, got)
+			}
+		}
+	}
+}
+
+func TestEncodeCapacity(t *testing.T) {
+	// Byte mode capacities, ISO/IEC 18004 Table 7
+	tests := []struct {
+		version int
+		level   Level
+		bytes   int
+	}{
+		{1, Low, 17}, {1, Medium, 14}, {1, Quartile, 11}, {1, High, 7},
+		{2, Medium, 26}, {10, Medium, 213}, {40, Low, 2953}, {40, High, 1273},
+	}
+	for _, tt := range tests {
+		code, err := Encode(strings.Repeat("a", tt.bytes), tt.level)
+		if err != nil || code.Version != tt.version {
+			t.Errorf("%d bytes level %d: version %v, err %v; want version %d", tt.bytes, tt.level, code, err, tt.version)
+			continue
+		}
+		code, err = Encode(strings.Repeat("a", tt.bytes+1), tt.level)
+		if tt.version == 40 {
+			if !errors.Is(err, ErrTooLong) {
+				t.Errorf("%d bytes level %d: err = %v, want ErrTooLong", tt.bytes+1, tt.level, err)
+			}
+		} else if err != nil || code.Version != tt.version+1 {
+			t.Errorf("%d bytes level %d: want version %d", tt.bytes+1, tt.level, tt.version+1)
+		}
+	}
+}
+
+// readFormatBits reads the copy of the format information around the top
+// left finder, most significant bit first
+func readFormatBits(modules [][]bool) string {
+	var bits [15]bool
+	for i := 0; i <= 5; i++ {
+		bits[i] = modules[i][8]
+	}
+	bits[6] = modules[7][8]
+	bits[7] = modules[8][8]
+	bits[8] = modules[8][7]
+	for i := 9; i < 15; i++ {
+		bits[i] = modules[8][14-i]
+	}
+	var s strings.Builder
+	for i := 14; i >= 0; i-- {
+		if bits[i] {
+			s.WriteByte('1')
+		} else {
+			s.WriteByte('0')
+		}
+	}
+	return s.String()
+}
+
+// readCodewords reads the codewords of a code back: the mask is taken from
+// the format information, undone, and the data modules are read in zigzag
+// order
+func readCodewords(t *testing.T, code *Code, level Level) []byte {
+	t.Helper()
+	format := readFormatBits(code.modules)
+	mask := -1
+	for candidate := 0; candidate < 8; candidate++ {
+		m := newMatrix(code.Version)
+		m.drawFormatBits(level, candidate)
+		if readFormatBits(m.modules) == format {
+			mask = candidate
+		}
+	}
+	if mask < 0 {
+		t.Fatalf("format bits %s do not match level %d", format, level)
+	}
+
+	m := newMatrix(code.Version)
+	m.drawFunctionPatterns(level)
+	for y := range m.modules {
+		copy(m.modules[y], code.modules[y])
+	}
+	m.applyMask(mask)
+
+	var bits bitBuffer
+	for right := m.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := ((m.size-1-right)/2)%2 == 0
+		if right < 6 {
+			upward = ((m.size-2-right)/2)%2 == 0
+		}
+		for vert := 0; vert < m.size; vert++ {
+			y := vert
+			if upward {
+				y = m.size - 1 - vert
+			}
+			for _, x := range []int{right, right - 1} {
+				if !m.isFunction[y][x] {
+					bits = append(bits, m.modules[y][x])
+				}
+			}
+		}
+	}
+
+	codewords := make([]byte, numRawDataModules(code.Version)/8)
+	for i := range codewords {
+		for j := 0; j < 8; j++ {
+			if bits[i*8+j] {
+				codewords[i] |= 1 << (7 - j)
+			}
+		}
+	}
+	return codewords
+}
+
+// blockGroups returns the number of short and long blocks of a version and
+// level and the data codewords in a short block
+func blockGroups(version int, level Level) (short, long, shortData int) {
+	blocks := numBlocks[level][version]
+	data := numDataCodewords(version, level)
+	long = data % blocks
+	return blocks - long, long, data / blocks
+}
+
+// deinterleave splits interleaved codewords back into blocks of data
+// followed by error correction
+func deinterleave(codewords []byte, short, long, shortData, eccLen int) [][]byte {
+	blocks := make([][]byte, short+long)
+	k := 0
+	for i := 0; i < shortData+1; i++ {
+		for j := range blocks {
+			if i == shortData && j < short {
+				continue
+			}
+			blocks[j] = append(blocks[j], codewords[k])
+			k++
+		}
+	}
+	for i := 0; i < eccLen; i++ {
+		for j := range blocks {
+			blocks[j] = append(blocks[j], codewords[k])
+			k++
+		}
+	}
+	return blocks
+}
+
+// decodeBytes reads a byte mode segment from data codewords
+func decodeBytes(data []byte, version int) string {
+	bit := func(i int) int { return int(data[i/8]>>(7-i%8)) & 1 }
+	read := func(pos, n int) int {
+		v := 0
+		for i := 0; i < n; i++ {
+			v = v<<1 | bit(pos+i)
+		}
+		return v
+	}
+	if read(0, 4) != 0x4 {
+		return ""
+	}
+	count := read(4, countBits(version))
+	pos := 4 + countBits(version)
+	out := make([]byte, count)
+	for i := range out {
+		out[i] = byte(read(pos+8*i, 8))
+	}
+	return string(out)
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,181 @@
+// Package recurrence computes the dates on which recurring documents fall
+// due.
+//
+// Schedules work at day granularity. Besides fixed monthly intervals, a
+// custom rule can be given as a cron-like expression with three fields:
+//
+//	day-of-month  month  day-of-week
+//
+// Each field accepts "*", single values, ranges ("1-5"), lists ("1,15")
+// and steps ("*/2", "1-12/3"). Days of the week run from 0 (Sunday) to 6
+// (Saturday); 7 is accepted as Sunday. The day of month may also be "L"
+// for the last day of the month. As in cron, when both the day of month
+// and the day of week are restricted, a date matches if either matches.
+//
+// Examples: "1 * *" (1st of every month), "L 3,6,9,12 *" (last day of each
+// quarter), "* * 1" (every Monday), "15 */2 *" (15th of every other month).
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// searchLimitDays bounds the search for the next matching date. Nine years
+// covers every leap day, including the gap around 2100.
+const searchLimitDays = 9 * 366
+
+// Cron is a parsed day-level cron expression
+type Cron struct {
+	days       [32]bool
+	lastDay    bool
+	months     [13]bool
+	weekdays   [7]bool
+	dayAny     bool
+	weekdayAny bool
+	expression string
+}
+
+// ParseCron parses a three-field expression of the form
+// "day-of-month month day-of-week"
+func ParseCron(expression string) (*Cron, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 3 {
+		return nil, errors.New("cron expression must have 3 fields: day-of-month month day-of-week")
+	}
+
+	c := &Cron{expression: strings.Join(fields, " ")}
+
+	c.dayAny = fields[0] == "*"
+	for _, part := range strings.Split(fields[0], ",") {
+		if part == "L" {
+			c.lastDay = true
+			continue
+		}
+		if err := parseField(part, 1, 31, c.days[:]); err != nil {
+			return nil, fmt.Errorf("day-of-month: %w", err)
+		}
+	}
+
+	for _, part := range strings.Split(fields[1], ",") {
+		if err := parseField(part, 1, 12, c.months[:]); err != nil {
+			return nil, fmt.Errorf("month: %w", err)
+		}
+	}
+
+	c.weekdayAny = fields[2] == "*"
+	var weekdays [8]bool
+	for _, part := range strings.Split(fields[2], ",") {
+		if err := parseField(part, 0, 7, weekdays[:]); err != nil {
+			return nil, fmt.Errorf("day-of-week: %w", err)
+		}
+	}
+	copy(c.weekdays[:], weekdays[:7])
+	c.weekdays[0] = c.weekdays[0] || weekdays[7]
+
+	return c, nil
+}
+
+// String returns the normalised expression
+func (c *Cron) String() string {
+	return c.expression
+}
+
+// Matches reports whether the date satisfies the expression
+func (c *Cron) Matches(date time.Time) bool {
+	if !c.months[date.Month()] {
+		return false
+	}
+
+	dayMatch := c.days[date.Day()] || (c.lastDay && date.AddDate(0, 0, 1).Day() == 1)
+	weekdayMatch := c.weekdays[date.Weekday()]
+
+	switch {
+	case c.dayAny && c.weekdayAny:
+		return true
+	case c.dayAny:
+		return weekdayMatch
+	case c.weekdayAny:
+		return dayMatch
+	default:
+		return dayMatch || weekdayMatch
+	}
+}
+
+// Next returns the first matching date strictly after the given date. It
+// returns false if no date matches within the search window, e.g. for
+// "31 2 *".
+func (c *Cron) Next(after time.Time) (time.Time, bool) {
+	date := Date(after)
+	for i := 0; i < searchLimitDays; i++ {
+		date = date.AddDate(0, 0, 1)
+		if c.Matches(date) {
+			return date, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseField parses one comma-separated part of a field and marks the
+// values it selects
+func parseField(part string, min, max int, selected []bool) error {
+	if part == "" {
+		return errors.New("empty value")
+	}
+
+	rangePart, stepPart, hasStep := strings.Cut(part, "/")
+	step := 1
+	if hasStep {
+		var err error
+		step, err = strconv.Atoi(stepPart)
+		if err != nil || step < 1 {
+			return fmt.Errorf("invalid step %q", stepPart)
+		}
+	}
+
+	low, high := min, max
+	switch {
+	case rangePart == "*":
+	case strings.Contains(rangePart, "-"):
+		from, to, _ := strings.Cut(rangePart, "-")
+		var err error
+		if low, err = parseValue(from, min, max); err != nil {
+			return err
+		}
+		if high, err = parseValue(to, min, max); err != nil {
+			return err
+		}
+		if low > high {
+			return fmt.Errorf("invalid range %q", rangePart)
+		}
+	default:
+		value, err := parseValue(rangePart, min, max)
+		if err != nil {
+			return err
+		}
+		low = value
+		if !hasStep {
+			high = value
+		}
+	}
+
+	for v := low; v <= high; v += step {
+		selected[v] = true
+	}
+	return nil
+}
+
+// parseValue parses a single number within [min, max]
+func parseValue(s string, min, max int) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if v < min || v > max {
+		return 0, fmt.Errorf("value %d out of range %d-%d", v, min, max)
+	}
+	return v, nil
+}
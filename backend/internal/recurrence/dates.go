@@ -0,0 +1,24 @@
+package recurrence
+
+import "time"
+
+// Date truncates a time to its calendar date, expressed as midnight UTC so
+// that dates compare and store consistently
+func Date(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// AddMonths adds a number of months to a date, clamping to the last day of
+// the target month instead of overflowing into the next one. For example
+// 31 January plus one month is 28 (or 29) February.
+func AddMonths(date time.Time, months int) time.Time {
+	date = Date(date)
+	first := time.Date(date.Year(), date.Month()+time.Month(months), 1, 0, 0, 0, 0, time.UTC)
+	lastDay := first.AddDate(0, 1, -1).Day()
+
+	day := date.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return first.AddDate(0, 0, day-1)
+}
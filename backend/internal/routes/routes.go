@@ -6,12 +6,14 @@ import (
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"invoice-generator/internal/config"
+	"invoice-generator/internal/constants"
 	"invoice-generator/internal/handlers"
 	"invoice-generator/internal/middleware"
+	"invoice-generator/internal/services"
 )
 
 // SetupRoutes configures all routes for the application
-func SetupRoutes(cfg *config.Config, h *handlers.Handlers, jwtSecret []byte) *gin.Engine {
+func SetupRoutes(cfg *config.Config, h *handlers.Handlers, jwtSecret []byte, idempotencyService *services.IdempotencyService) *gin.Engine {
 	// Set Gin mode
 	gin.SetMode(cfg.ServerMode)
 
@@ -22,7 +24,7 @@ func SetupRoutes(cfg *config.Config, h *handlers.Handlers, jwtSecret []byte) *gi
 		AllowOrigins:     cfg.CORSOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"*"},
-		ExposeHeaders:    []string{"Content-Length"},
+		ExposeHeaders:    []string{"Content-Length", constants.IdempotentReplayHeader},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
@@ -45,6 +47,7 @@ func SetupRoutes(cfg *config.Config, h *handlers.Handlers, jwtSecret []byte) *gi
 	// Protected routes
 	api := r.Group("/api")
 	api.Use(middleware.AuthMiddleware(jwtSecret))
+	idempotent := middleware.Idempotency(idempotencyService)
 	{
 		// User routes
 		api.GET("/profile", h.GetProfile)
@@ -58,8 +61,96 @@ func SetupRoutes(cfg *config.Config, h *handlers.Handlers, jwtSecret []byte) *gi
 		// Invoice routes
 		api.GET("/invoices", h.GetInvoices)
 		api.GET("/invoices/:id", h.GetInvoice)
-		api.POST("/invoices", middleware.ValidateInvoiceData(), h.CreateInvoice)
-		api.POST("/invoices/:id/payments", h.AddPayment)
+		api.GET("/invoices/:id/pdf", h.GetInvoicePDF)
+		api.GET("/invoices/:id/upi", h.GetUPIPaymentLink)
+		api.GET("/invoices/:id/upi-qr", h.GetUPIQRCode)
+		api.GET("/invoices/:id/revisions", h.GetInvoiceRevisions)
+		api.GET("/invoices/:id/revisions/diff", h.DiffInvoiceRevisions)
+		api.POST("/invoices", idempotent, middleware.ValidateInvoiceData(), h.CreateInvoice)
+		api.PUT("/invoices/:id", middleware.ValidateInvoiceData(), h.UpdateInvoice)
+		api.POST("/invoices/:id/issue", h.IssueInvoice)
+		api.POST("/invoices/:id/void", h.VoidInvoice)
+		api.POST("/invoices/:id/cancel", h.CancelInvoice)
+		api.POST("/invoices/:id/payments", idempotent, h.AddPayment)
+		api.POST("/invoices/:id/refunds", idempotent, h.AddRefund)
+		api.GET("/invoices/:id/payment-events", h.GetPaymentEvents)
+		api.POST("/invoices/:id/apply-credit", h.ApplyCredit)
+		api.POST("/invoices/:id/credit-notes", h.CreateCreditNote)
+		api.POST("/invoices/:id/debit-notes", h.CreateDebitNote)
+		api.GET("/invoices/:id/einvoice", h.GetEInvoice)
+		api.POST("/invoices/:id/irn", h.GenerateIRN)
+		api.POST("/invoices/:id/irn/cancel", h.CancelIRN)
+		api.PUT("/invoices/:id/transport", h.UpdateTransport)
+		api.GET("/invoices/:id/eway-bill", h.GetEWayBill)
+
+		// Payment routes
+		api.POST("/payments/:id/deposit", h.DepositCheque)
+		api.POST("/payments/:id/clear", h.ClearCheque)
+		api.POST("/payments/:id/bounce", h.BounceCheque)
+		api.POST("/payments/:id/reverse", h.ReversePayment)
+
+		// Credit and debit note routes
+		api.GET("/adjustment-notes", h.GetAdjustmentNotes)
+		api.GET("/adjustment-notes/:id", h.GetAdjustmentNote)
+
+		// Number series routes
+		api.GET("/number-series", h.GetNumberSeries)
+		api.POST("/number-series", h.CreateNumberSeries)
+		api.PUT("/number-series/:id", h.UpdateNumberSeries)
+
+		// Quotation routes
+		api.GET("/quotations", h.GetQuotations)
+		api.POST("/quotations", h.CreateQuotation)
+		api.GET("/quotations/:id", h.GetQuotation)
+		api.POST("/quotations/:id/accept", h.AcceptQuotation)
+		api.POST("/quotations/:id/reject", h.RejectQuotation)
+		api.POST("/quotations/:id/convert", h.ConvertQuotation)
+
+		// Recurring invoice routes
+		api.GET("/recurring-invoices", h.GetRecurringInvoices)
+		api.POST("/recurring-invoices", h.CreateRecurringInvoice)
+		api.POST("/recurring-invoices/preview", h.PreviewRecurringRule)
+		api.GET("/recurring-invoices/:id", h.GetRecurringInvoice)
+		api.PUT("/recurring-invoices/:id", h.UpdateRecurringInvoice)
+		api.DELETE("/recurring-invoices/:id", h.DeleteRecurringInvoice)
+		api.POST("/recurring-invoices/:id/pause", h.PauseRecurringInvoice)
+		api.POST("/recurring-invoices/:id/resume", h.ResumeRecurringInvoice)
+		api.GET("/recurring-invoices/:id/preview", h.PreviewRecurringInvoice)
+		api.GET("/recurring-invoices/:id/runs", h.GetRecurringInvoiceRuns)
+
+		// Party routes
+		api.GET("/parties", h.GetParties)
+		api.POST("/parties", h.CreateParty)
+		api.GET("/parties/:id", h.GetParty)
+		api.PUT("/parties/:id", h.UpdateParty)
+		api.DELETE("/parties/:id", h.DeleteParty)
+
+		// Receipt routes
+		api.GET("/receipts", h.GetReceipts)
+		api.POST("/receipts", idempotent, h.CreateReceipt)
+		api.GET("/receipts/:id", h.GetReceipt)
+		api.PUT("/receipts/:id/allocations", h.UpdateReceiptAllocations)
+
+		// Bank reconciliation routes
+		api.GET("/bank-statements", h.GetBankStatements)
+		api.POST("/bank-statements", h.ImportBankStatement)
+		api.GET("/bank-statements/:id", h.GetBankStatement)
+		api.POST("/bank-statements/:id/reconcile", h.ReconcileBankStatement)
+		api.GET("/bank-transactions", h.GetBankTransactions)
+		api.POST("/bank-transactions/:id/confirm", h.ConfirmBankMatch)
+		api.POST("/bank-transactions/:id/ignore", h.IgnoreBankTransaction)
+
+		// Customer credit routes
+		api.POST("/parties/:id/advances", idempotent, h.AddAdvance)
+		api.GET("/parties/:id/credit", h.GetCreditStatement)
+		api.GET("/credit-balances", h.GetCreditBalances)
+		api.POST("/credit-entries/:id/reverse", h.ReverseAdvance)
+
+		// GST return and report routes
+		api.GET("/returns/gstr1", h.GetGSTR1)
+		api.GET("/returns/gstr3b", h.GetGSTR3B)
+		api.GET("/reports/hsn-summary", h.GetHSNSummary)
+		api.GET("/eway-bills", h.ExportEWayBills)
 
 		// Dashboard
 		api.GET("/dashboard", h.GetDashboard)
@@ -72,6 +163,7 @@ func SetupRoutes(cfg *config.Config, h *handlers.Handlers, jwtSecret []byte) *gi
 			admin.POST("/categories", h.CreateCategory)
 			admin.POST("/items", h.CreateItem)
 			admin.DELETE("/invoices/:id", h.DeleteInvoice)
+			admin.POST("/recurring-invoices/run", h.RunRecurringInvoices)
 		}
 	}
 
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"invoice-generator/internal/constants"
+	"invoice-generator/internal/database"
+	"invoice-generator/internal/models"
+	"invoice-generator/internal/money"
+)
+
+// AdjustmentNoteService handles credit note and debit note business logic
+type AdjustmentNoteService struct{}
+
+// NewAdjustmentNoteService creates a new adjustment note service
+func NewAdjustmentNoteService() *AdjustmentNoteService {
+	return &AdjustmentNoteService{}
+}
+
+// CreateNote issues a credit or debit note against an invoice. Only the
+// seller of the invoice (or an admin) may issue notes. The note is numbered
+// from its own series, and the original invoice's amount due and payment
+// status are updated in the same transaction.
+func (s *AdjustmentNoteService) CreateNote(invoiceID uint, note *models.AdjustmentNote, userID uint, isAdmin bool) error {
+	if note.NoteType != constants.NoteTypeCredit && note.NoteType != constants.NoteTypeDebit {
+		return errors.New("invalid note type")
+	}
+	note.Reason = strings.TrimSpace(note.Reason)
+	if note.Reason == "" {
+		return errors.New("reason is required")
+	}
+	if len(note.LineItems) == 0 {
+		return errors.New("note must have at least one line item")
+	}
+	if note.NoteDate.IsZero() {
+		note.NoteDate = time.Now()
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		return s.createNote(tx, invoiceID, note, userID, isAdmin)
+	})
+	if err != nil {
+		return err
+	}
+
+	database.GetDB().Preload("LineItems").First(note, note.ID)
+	return nil
+}
+
+// createNote issues a note in the caller's transaction, after the note's
+// type, reason, lines and date have been checked
+func (s *AdjustmentNoteService) createNote(tx *gorm.DB, invoiceID uint, note *models.AdjustmentNote, userID uint, isAdmin bool) error {
+	// Lock the original invoice so concurrent notes and payments see a
+	// consistent balance
+	var invoice models.Invoice
+	query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", invoiceID)
+	if !isAdmin {
+		query = query.Where("generated_by_id = ?", userID)
+	}
+	if err := query.First(&invoice).Error; err != nil {
+		return errors.New("invoice not found")
+	}
+	if invoice.Status != constants.InvoiceStatusIssued {
+		return errors.New("notes can only be issued against issued invoices")
+	}
+	if note.NoteDate.Before(invoice.InvoiceDate.Truncate(24 * time.Hour)) {
+		return errors.New("note date cannot be before the invoice date")
+	}
+
+	var invoiceLines []models.InvoiceLineItem
+	if err := tx.Where("invoice_id = ?", invoiceID).Find(&invoiceLines).Error; err != nil {
+		return err
+	}
+
+	note.ID = 0
+	note.InvoiceID = invoice.ID
+	note.GeneratedByID = invoice.GeneratedByID
+	note.GeneratedForID = invoice.GeneratedForID
+	note.PartyID = invoice.PartyID
+	note.PlaceOfSupply = invoice.PlaceOfSupply
+	note.SupplyType = invoice.SupplyType
+
+	if err := s.prepareLines(tx, note, invoiceLines); err != nil {
+		return err
+	}
+	calculateNoteTotals(note)
+
+	if note.TotalAmount <= 0 {
+		return errors.New("note total must be positive")
+	}
+	// A credit note may leave a paid invoice overpaid, to be refunded or
+	// credited to the buyer, but cannot take its value below zero
+	if note.NoteType == constants.NoteTypeCredit && note.TotalAmount > invoice.TotalAmount+invoice.TotalDebited-invoice.TotalCredited {
+		return errors.New("credit note cannot exceed the net value of the invoice")
+	}
+
+	number, err := allocateDocumentNumber(tx, note.GeneratedByID, note.NoteType, note.Series, note.NoteDate)
+	if err != nil {
+		return err
+	}
+	note.NoteNumber = number.Number
+	note.SeriesID = &number.SeriesID
+	note.SequenceNumber = number.Sequence
+	note.FinancialYear = number.FinancialYear
+
+	if err := tx.Omit("Invoice").Create(note).Error; err != nil {
+		return fmt.Errorf("failed to create note: %w", err)
+	}
+
+	column, total := "total_debited", invoice.TotalDebited+note.TotalAmount
+	if note.NoteType == constants.NoteTypeCredit {
+		column, total = "total_credited", invoice.TotalCredited+note.TotalAmount
+	}
+	if err := tx.Model(&invoice).Update(column, total).Error; err != nil {
+		return err
+	}
+
+	return updateInvoicePaymentStatus(tx, invoice.ID)
+}
+
+// prepareLines fills in lines that refer to the original invoice and, for
+// credit notes, checks that no invoice line is credited beyond its value
+func (s *AdjustmentNoteService) prepareLines(tx *gorm.DB, note *models.AdjustmentNote, invoiceLines []models.InvoiceLineItem) error {
+	originals := make(map[uint]models.InvoiceLineItem, len(invoiceLines))
+	for _, line := range invoiceLines {
+		originals[line.ID] = line
+	}
+
+	credited := make(map[uint]money.Amount)
+	for i := range note.LineItems {
+		line := &note.LineItems[i]
+		line.ID = 0
+
+		if line.InvoiceLineItemID == nil {
+			if strings.TrimSpace(line.Description) == "" {
+				return fmt.Errorf("line %d: description is required", i+1)
+			}
+		} else {
+			original, ok := originals[*line.InvoiceLineItemID]
+			if !ok {
+				return fmt.Errorf("line %d: invoice line item not found on this invoice", i+1)
+			}
+			if line.Description == "" {
+				line.Description = original.Description
+			}
+			if line.Rate == 0 {
+				// Default to the rate net of discounts, which is what the
+				// buyer was actually charged per unit
+				line.Rate = original.Amount.Prorate(money.QuantityFromInt(1), original.Quantity)
+			}
+			if line.GSTRate == nil {
+				line.GSTRate = &original.GSTRate
+			}
+		}
+		if line.GSTRate == nil {
+			// Lines of their own without a rate, such as bank charges,
+			// carry no GST
+			line.GSTRate = new(int)
+		}
+
+		if line.Quantity <= 0 {
+			return fmt.Errorf("line %d: quantity must be positive", i+1)
+		}
+		if line.Rate <= 0 {
+			return fmt.Errorf("line %d: rate must be positive", i+1)
+		}
+
+		if note.NoteType == constants.NoteTypeCredit && line.InvoiceLineItemID != nil {
+			credited[*line.InvoiceLineItemID] += line.Quantity.MulAmount(line.Rate)
+		}
+	}
+
+	// Include what earlier credit notes already took off each line
+	for lineID, amount := range credited {
+		var previous money.Amount
+		if err := tx.Model(&models.AdjustmentNoteLineItem{}).
+			Joins("JOIN adjustment_notes ON adjustment_notes.id = adjustment_note_line_items.note_id").
+			Where("adjustment_note_line_items.invoice_line_item_id = ? AND adjustment_notes.note_type = ?", lineID, constants.NoteTypeCredit).
+			Select("COALESCE(SUM(adjustment_note_line_items.amount), 0)").Row().Scan(&previous); err != nil {
+			return err
+		}
+		if previous+amount > originals[lineID].Amount {
+			return fmt.Errorf("credit for %q exceeds the original line value", originals[lineID].Description)
+		}
+	}
+
+	return nil
+}
+
+// calculateNoteTotals computes note line and total amounts using the same
+// per-line rounding and GST split as invoices
+func calculateNoteTotals(note *models.AdjustmentNote) {
+	var subTotal, totalCGST, totalSGST, totalIGST money.Amount
+	interState := note.SupplyType == constants.SupplyTypeInterState
+
+	for i := range note.LineItems {
+		line := &note.LineItems[i]
+		line.Amount = line.Quantity.MulAmount(line.Rate)
+		line.CGSTAmount, line.SGSTAmount, line.IGSTAmount = calculateLineTax(line.Amount, *line.GSTRate, interState)
+		line.GSTAmount = line.CGSTAmount + line.SGSTAmount + line.IGSTAmount
+		line.TotalAmount = line.Amount + line.GSTAmount
+
+		subTotal += line.Amount
+		totalCGST += line.CGSTAmount
+		totalSGST += line.SGSTAmount
+		totalIGST += line.IGSTAmount
+	}
+
+	note.SubTotal = subTotal
+	note.TotalCGST = totalCGST
+	note.TotalSGST = totalSGST
+	note.TotalIGST = totalIGST
+	note.TotalGST = totalCGST + totalSGST + totalIGST
+	note.TotalAmount = subTotal + note.TotalGST
+}
+
+// GetNotes returns credit and debit notes with pagination and access control
+func (s *AdjustmentNoteService) GetNotes(userID uint, isAdmin bool, noteType string, page, limit int) ([]models.AdjustmentNote, int64, error) {
+	var notes []models.AdjustmentNote
+	query := database.GetDB().Preload("LineItems")
+
+	if !isAdmin {
+		query = query.Where("generated_by_id = ? OR generated_for_id = ?", userID, userID)
+	}
+	if noteType != "" {
+		query = query.Where("note_type = ?", noteType)
+	}
+
+	var total int64
+	query.Model(&models.AdjustmentNote{}).Count(&total)
+
+	offset := (page - 1) * limit
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&notes).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return notes, total, nil
+}
+
+// GetNote returns a single note with its original invoice
+func (s *AdjustmentNoteService) GetNote(id uint, userID uint, isAdmin bool) (*models.AdjustmentNote, error) {
+	var note models.AdjustmentNote
+	query := database.GetDB().Preload("LineItems").Preload("Invoice")
+
+	if !isAdmin {
+		query = query.Where("generated_by_id = ? OR generated_for_id = ?", userID, userID)
+	}
+
+	if err := query.First(&note, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("note not found")
+		}
+		return nil, err
+	}
+
+	return &note, nil
+}
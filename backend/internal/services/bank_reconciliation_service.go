@@ -0,0 +1,711 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"invoice-generator/internal/bankstatement"
+	"invoice-generator/internal/constants"
+	"invoice-generator/internal/database"
+	"invoice-generator/internal/models"
+)
+
+// BankReconciliationService imports bank statements and matches their
+// transactions to the payments, receipts and invoices they settle
+type BankReconciliationService struct {
+	invoiceService *InvoiceService
+}
+
+// NewBankReconciliationService creates a new bank reconciliation service
+func NewBankReconciliationService(invoiceService *InvoiceService) *BankReconciliationService {
+	return &BankReconciliationService{invoiceService: invoiceService}
+}
+
+// bankMatch is a payment, receipt or invoice a bank transaction could be
+// matched to, scored by how much of it agrees with the transaction
+type bankMatch struct {
+	matchType string
+	id        uint
+	invoiceID *uint
+	score     int
+	reason    string
+}
+
+// Scores of the parts of a match. A reference found in the transaction
+// outweighs amount and date together.
+const (
+	matchScoreAmount    = 1
+	matchScoreDate      = 1
+	matchScoreReference = 3
+)
+
+// minReferenceLength is the shortest reference looked for in a
+// transaction's narration, so that short numbers do not match by chance
+const minReferenceLength = 4
+
+// ImportStatement reads a statement file and stores its transactions for
+// the user, skipping any imported before. Matches are then proposed for
+// the new transactions, looking for payments and receipts dated within
+// windowDays of each transaction.
+func (s *BankReconciliationService) ImportStatement(statement *models.BankStatement, r io.Reader, mapping *bankstatement.CSVMapping, windowDays int, userID uint) error {
+	if err := checkMatchWindow(windowDays); err != nil {
+		return err
+	}
+	statement.Format = strings.ToUpper(statement.Format)
+	parsed, err := bankstatement.Parse(statement.Format, r, mapping)
+	if err != nil {
+		return err
+	}
+	if len(parsed.Transactions) == 0 {
+		return errors.New("statement has no transactions")
+	}
+
+	*statement = models.BankStatement{
+		SellerID:      userID,
+		Format:        statement.Format,
+		FileName:      statement.FileName,
+		AccountNumber: parsed.AccountNumber,
+		CreatedByID:   userID,
+	}
+	for _, line := range parsed.Transactions {
+		date := line.Date
+		if statement.FromDate == nil || date.Before(*statement.FromDate) {
+			statement.FromDate = &date
+		}
+		if statement.ToDate == nil || date.After(*statement.ToDate) {
+			statement.ToDate = &date
+		}
+	}
+
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Transactions").Create(statement).Error; err != nil {
+			return fmt.Errorf("failed to import statement: %w", err)
+		}
+
+		// Identical lines in one file are told apart by their occurrence
+		seen := map[string]int{}
+		var imported []models.BankTransaction
+		for _, line := range parsed.Transactions {
+			key := statementLineKey(parsed.AccountNumber, line)
+			seen[key]++
+			transaction := models.BankTransaction{
+				StatementID:     statement.ID,
+				SellerID:        userID,
+				TransactionDate: line.Date,
+				Amount:          line.Amount,
+				Reference:       line.Reference,
+				BankReference:   line.BankReference,
+				Description:     line.Description,
+				Fingerprint:     fingerprint(fmt.Sprintf("%s|%d", key, seen[key])),
+				Status:          constants.BankTransactionUnmatched,
+			}
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&transaction)
+			if result.Error != nil {
+				return fmt.Errorf("failed to import transaction: %w", result.Error)
+			}
+			if result.RowsAffected == 0 {
+				statement.DuplicateCount++
+				continue
+			}
+			statement.TransactionCount++
+			imported = append(imported, transaction)
+		}
+
+		if err := tx.Model(statement).Updates(map[string]interface{}{
+			"transaction_count": statement.TransactionCount,
+			"duplicate_count":   statement.DuplicateCount,
+		}).Error; err != nil {
+			return err
+		}
+		return s.proposeMatches(tx, userID, imported, windowDays)
+	})
+	if err != nil {
+		return err
+	}
+
+	loaded, err := s.GetStatement(statement.ID, userID, false)
+	if err != nil {
+		return err
+	}
+	*statement = *loaded
+	return nil
+}
+
+// Reconcile proposes matches again for a statement's transactions that
+// are unmatched or awaiting confirmation, such as after more invoices or
+// payments have been recorded
+func (s *BankReconciliationService) Reconcile(statementID uint, windowDays int, userID uint, isAdmin bool) (*models.BankStatement, error) {
+	if err := checkMatchWindow(windowDays); err != nil {
+		return nil, err
+	}
+	statement, err := s.GetStatement(statementID, userID, isAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var transactions []models.BankTransaction
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("statement_id = ? AND status IN ?", statement.ID,
+				[]string{constants.BankTransactionUnmatched, constants.BankTransactionProposed}).
+			Order("transaction_date, id").Find(&transactions).Error; err != nil {
+			return err
+		}
+		return s.proposeMatches(tx, statement.SellerID, transactions, windowDays)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetStatement(statementID, userID, isAdmin)
+}
+
+// GetStatements returns imported statements with pagination and access
+// control, newest first
+func (s *BankReconciliationService) GetStatements(userID uint, isAdmin bool, page, limit int) ([]models.BankStatement, int64, error) {
+	var statements []models.BankStatement
+	query := database.GetDB().Model(&models.BankStatement{})
+
+	if !isAdmin {
+		query = query.Where("seller_id = ?", userID)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	offset := (page - 1) * limit
+	if err := query.Order("id DESC").Limit(limit).Offset(offset).Find(&statements).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return statements, total, nil
+}
+
+// GetStatement returns a statement with its transactions in date order
+func (s *BankReconciliationService) GetStatement(id uint, userID uint, isAdmin bool) (*models.BankStatement, error) {
+	var statement models.BankStatement
+	query := database.GetDB().Preload("Transactions", func(db *gorm.DB) *gorm.DB {
+		return db.Order("transaction_date, id")
+	})
+	if !isAdmin {
+		query = query.Where("seller_id = ?", userID)
+	}
+
+	if err := query.First(&statement, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("bank statement not found")
+		}
+		return nil, err
+	}
+
+	return &statement, nil
+}
+
+// GetTransactions returns bank transactions across statements with
+// pagination, optionally only those with the given status, in date order
+func (s *BankReconciliationService) GetTransactions(userID uint, isAdmin bool, status string, page, limit int) ([]models.BankTransaction, int64, error) {
+	var transactions []models.BankTransaction
+	query := database.GetDB().Model(&models.BankTransaction{})
+
+	if !isAdmin {
+		query = query.Where("seller_id = ?", userID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", strings.ToUpper(status))
+	}
+
+	var total int64
+	query.Count(&total)
+
+	offset := (page - 1) * limit
+	if err := query.Order("transaction_date, id").Limit(limit).Offset(offset).Find(&transactions).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return transactions, total, nil
+}
+
+// ConfirmMatch reconciles a bank transaction with its proposed match, or
+// with the invoice, payment or receipt given instead. Confirming an
+// invoice records a payment for the transaction's amount on it. Confirming
+// a cheque that has not cleared yet marks it as cleared on the
+// transaction's date.
+func (s *BankReconciliationService) ConfirmMatch(id uint, request *models.BankMatchRequest, userID uint, isAdmin bool) (*models.BankTransaction, error) {
+	chosen := 0
+	for _, matchID := range []*uint{request.InvoiceID, request.PaymentID, request.ReceiptID} {
+		if matchID != nil {
+			chosen++
+		}
+	}
+	if chosen > 1 {
+		return nil, errors.New("give only one of invoice_id, payment_id or receipt_id")
+	}
+
+	var transaction *models.BankTransaction
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var err error
+		if transaction, err = s.lockTransaction(tx, id, userID, isAdmin); err != nil {
+			return err
+		}
+		if transaction.Status == constants.BankTransactionReconciled {
+			return errors.New("transaction is already reconciled")
+		}
+
+		if chosen > 0 {
+			transaction.InvoiceID, transaction.PaymentID, transaction.ReceiptID = request.InvoiceID, request.PaymentID, request.ReceiptID
+			transaction.MatchReason = "Matched by hand"
+			switch {
+			case request.InvoiceID != nil:
+				transaction.MatchType = constants.MatchTypeInvoice
+			case request.PaymentID != nil:
+				transaction.MatchType = constants.MatchTypePayment
+			default:
+				transaction.MatchType = constants.MatchTypeReceipt
+			}
+		} else if transaction.Status != constants.BankTransactionProposed {
+			return errors.New("transaction has no proposed match; give an invoice_id, payment_id or receipt_id")
+		}
+
+		switch transaction.MatchType {
+		case constants.MatchTypeInvoice:
+			err = s.payInvoice(tx, transaction, request.Method, userID)
+		case constants.MatchTypePayment:
+			err = s.matchPayment(tx, transaction, userID)
+		case constants.MatchTypeReceipt:
+			err = s.matchReceipt(tx, transaction)
+		default:
+			err = errors.New("transaction has no proposed match")
+		}
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		transaction.Status = constants.BankTransactionReconciled
+		transaction.ReconciledAt = &now
+		transaction.ReconciledByID = &userID
+		if err := tx.Save(transaction).Error; err != nil {
+			return fmt.Errorf("failed to update bank transaction: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return transaction, nil
+}
+
+// IgnoreTransaction sets aside a bank transaction that does not settle an
+// invoice, such as bank interest or charges, so it is no longer matched
+func (s *BankReconciliationService) IgnoreTransaction(id uint, userID uint, isAdmin bool) (*models.BankTransaction, error) {
+	var transaction *models.BankTransaction
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var err error
+		if transaction, err = s.lockTransaction(tx, id, userID, isAdmin); err != nil {
+			return err
+		}
+		if transaction.Status == constants.BankTransactionReconciled {
+			return errors.New("reconciled transactions cannot be ignored")
+		}
+
+		clearMatch(transaction)
+		transaction.Status = constants.BankTransactionIgnored
+		return tx.Save(transaction).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return transaction, nil
+}
+
+// lockTransaction loads a bank transaction for update, checking that the
+// user may reconcile it
+func (s *BankReconciliationService) lockTransaction(tx *gorm.DB, id uint, userID uint, isAdmin bool) (*models.BankTransaction, error) {
+	var transaction models.BankTransaction
+	query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id)
+	if !isAdmin {
+		query = query.Where("seller_id = ?", userID)
+	}
+	if err := query.First(&transaction).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("bank transaction not found")
+		}
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+// payInvoice records the transaction as a payment on an invoice
+func (s *BankReconciliationService) payInvoice(tx *gorm.DB, transaction *models.BankTransaction, method string, userID uint) error {
+	if !transaction.Amount.IsPositive() {
+		return errors.New("only credits can be matched to invoices")
+	}
+	if method == "" {
+		method = constants.PaymentMethodBankTransfer
+	}
+	if !isAdvanceMethod(method) {
+		return errors.New("payment method must be CASH, BANK_TRANSFER, CHEQUE, UPI or CARD")
+	}
+
+	invoice, err := s.invoiceService.lockInvoice(tx, *transaction.InvoiceID, transaction.SellerID, false)
+	if err != nil {
+		return err
+	}
+
+	reference := transaction.Reference
+	if reference == "" {
+		reference = transaction.BankReference
+	}
+	date := transaction.TransactionDate
+	payment := models.Payment{
+		PaymentType:   constants.PaymentTypeReceipt,
+		Amount:        transaction.Amount,
+		PaymentMethod: method,
+		PaymentDate:   date,
+		Reference:     reference,
+		Notes:         transaction.Description,
+		Status:        constants.PaymentCleared,
+	}
+	if method == constants.PaymentMethodCheque {
+		payment.ClearedAt = &date
+	}
+	if err := recordPayment(tx, invoice, &payment, userID); err != nil {
+		return err
+	}
+	transaction.PaymentID = &payment.ID
+	return nil
+}
+
+// matchPayment links the transaction to a payment already recorded,
+// clearing it if it is a cheque still awaiting clearance
+func (s *BankReconciliationService) matchPayment(tx *gorm.DB, transaction *models.BankTransaction, userID uint) error {
+	var payment models.Payment
+	if err := tx.First(&payment, *transaction.PaymentID).Error; err != nil {
+		return errors.New("payment not found")
+	}
+	if _, err := s.invoiceService.lockInvoice(tx, payment.InvoiceID, transaction.SellerID, false); err != nil {
+		return errors.New("payment not found")
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, payment.ID).Error; err != nil {
+		return err
+	}
+
+	if payment.Status == constants.PaymentBounced || payment.Status == constants.PaymentReversed {
+		return fmt.Errorf("payment has been %s", strings.ToLower(payment.Status))
+	}
+	if payment.PaymentMethod == constants.PaymentMethodCredit {
+		return errors.New("payments made from credit do not pass through the bank")
+	}
+	if payment.ReceiptID != nil {
+		return errors.New("payment is an allocation of a receipt; match the receipt instead")
+	}
+	if payment.Amount+payment.CreditAmount != transaction.Amount {
+		return errors.New("amount does not match the payment")
+	}
+	if err := checkNotReconciled(tx, transaction, "payment_id", payment.ID); err != nil {
+		return err
+	}
+
+	if payment.Status == constants.PaymentReceived || payment.Status == constants.PaymentDeposited {
+		from := payment.Status
+		date := transaction.TransactionDate
+		if payment.DepositedAt == nil {
+			payment.DepositedAt = &date
+		}
+		payment.Status = constants.PaymentCleared
+		payment.ClearedAt = &date
+		if err := tx.Save(&payment).Error; err != nil {
+			return fmt.Errorf("failed to update payment: %w", err)
+		}
+		if err := recordPaymentEvent(tx, &payment, constants.PaymentActionCleared, from, "Cleared on bank statement", userID); err != nil {
+			return err
+		}
+	}
+
+	transaction.InvoiceID = &payment.InvoiceID
+	return nil
+}
+
+// matchReceipt links the transaction to a receipt already recorded
+func (s *BankReconciliationService) matchReceipt(tx *gorm.DB, transaction *models.BankTransaction) error {
+	var receipt models.Receipt
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND seller_id = ?", *transaction.ReceiptID, transaction.SellerID).
+		First(&receipt).Error; err != nil {
+		return errors.New("receipt not found")
+	}
+	if receipt.Amount != transaction.Amount {
+		return errors.New("amount does not match the receipt")
+	}
+	return checkNotReconciled(tx, transaction, "receipt_id", receipt.ID)
+}
+
+// proposeMatches looks for the best match of each transaction. A match is
+// proposed only when one candidate scores higher than all others;
+// otherwise the transaction is left unmatched for the user to choose.
+func (s *BankReconciliationService) proposeMatches(tx *gorm.DB, sellerID uint, transactions []models.BankTransaction, windowDays int) error {
+	// Earlier proposals are withdrawn first so that they do not hold on to
+	// their matches
+	var ids []uint
+	for _, transaction := range transactions {
+		if transaction.Status == constants.BankTransactionProposed {
+			ids = append(ids, transaction.ID)
+		}
+	}
+	if len(ids) > 0 {
+		if err := tx.Model(&models.BankTransaction{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"status":       constants.BankTransactionUnmatched,
+			"match_type":   "",
+			"invoice_id":   nil,
+			"payment_id":   nil,
+			"receipt_id":   nil,
+			"match_reason": "",
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	var openInvoices []models.Invoice
+	if err := tx.Select("id, invoice_number, party_id, invoice_date, due_date, amount_due").
+		Where("generated_by_id = ? AND status = ? AND amount_due > 0", sellerID, constants.InvoiceStatusIssued).
+		Order("due_date, id").Find(&openInvoices).Error; err != nil {
+		return err
+	}
+
+	// Invoices already proposed for another transaction are not proposed twice
+	var proposed []uint
+	if err := tx.Model(&models.BankTransaction{}).
+		Where("seller_id = ? AND status = ? AND match_type = ?", sellerID, constants.BankTransactionProposed, constants.MatchTypeInvoice).
+		Pluck("invoice_id", &proposed).Error; err != nil {
+		return err
+	}
+	taken := map[string]bool{}
+	for _, id := range proposed {
+		taken[matchKey(constants.MatchTypeInvoice, id)] = true
+	}
+
+	for i := range transactions {
+		transaction := &transactions[i]
+		candidates, err := s.findMatches(tx, transaction, openInvoices, windowDays)
+		if err != nil {
+			return err
+		}
+
+		var best *bankMatch
+		tied := 0
+		for j := range candidates {
+			candidate := &candidates[j]
+			if taken[matchKey(candidate.matchType, candidate.id)] {
+				continue
+			}
+			switch {
+			case best == nil || candidate.score > best.score:
+				best, tied = candidate, 1
+			case candidate.score == best.score:
+				tied++
+			}
+		}
+
+		clearMatch(transaction)
+		transaction.Status = constants.BankTransactionUnmatched
+		switch {
+		case tied > 1:
+			transaction.MatchReason = fmt.Sprintf("%d equally likely matches; choose one", tied)
+		case best != nil:
+			transaction.Status = constants.BankTransactionProposed
+			transaction.MatchType = best.matchType
+			transaction.MatchReason = best.reason
+			transaction.InvoiceID = best.invoiceID
+			switch best.matchType {
+			case constants.MatchTypePayment:
+				transaction.PaymentID = &best.id
+			case constants.MatchTypeReceipt:
+				transaction.ReceiptID = &best.id
+			}
+			taken[matchKey(best.matchType, best.id)] = true
+		}
+		if err := tx.Save(transaction).Error; err != nil {
+			return fmt.Errorf("failed to update bank transaction: %w", err)
+		}
+	}
+	return nil
+}
+
+// findMatches returns the payments, receipts and open invoices a
+// transaction could settle. Payments and receipts must have the same
+// amount and be dated within the window; an invoice must have the amount
+// due, or its number must appear in the transaction.
+func (s *BankReconciliationService) findMatches(tx *gorm.DB, transaction *models.BankTransaction, openInvoices []models.Invoice, windowDays int) ([]bankMatch, error) {
+	text := normalizeReference(transaction.Reference + " " + transaction.BankReference + " " + transaction.Description)
+	from := transaction.TransactionDate.AddDate(0, 0, -windowDays)
+	to := transaction.TransactionDate.AddDate(0, 0, windowDays+1)
+	linked := []string{constants.BankTransactionProposed, constants.BankTransactionReconciled}
+
+	var matches []bankMatch
+
+	// Cheques reach the bank when they are deposited, other payments when
+	// they are made
+	var payments []models.Payment
+	if err := tx.Select("payments.*").
+		Joins("JOIN invoices ON invoices.id = payments.invoice_id").
+		Where("invoices.generated_by_id = ? AND payments.status NOT IN ? AND payments.payment_method <> ? AND payments.receipt_id IS NULL",
+			transaction.SellerID, constants.InactivePaymentStatuses, constants.PaymentMethodCredit).
+		Where("payments.amount + payments.credit_amount = ?", transaction.Amount).
+		Where("COALESCE(payments.deposited_at, payments.payment_date) >= ? AND COALESCE(payments.deposited_at, payments.payment_date) < ?", from, to).
+		Where("NOT EXISTS (SELECT 1 FROM bank_transactions WHERE bank_transactions.payment_id = payments.id AND bank_transactions.status IN ? AND bank_transactions.id <> ?)",
+			linked, transaction.ID).
+		Order("payments.id").Find(&payments).Error; err != nil {
+		return nil, err
+	}
+	for _, payment := range payments {
+		match := bankMatch{
+			matchType: constants.MatchTypePayment,
+			id:        payment.ID,
+			invoiceID: &payment.InvoiceID,
+			score:     matchScoreAmount + matchScoreDate,
+			reason:    fmt.Sprintf("Amount and date match payment %d", payment.ID),
+		}
+		if containsReference(text, payment.Reference) {
+			match.score += matchScoreReference
+			match.reason += fmt.Sprintf(" and reference %s", payment.Reference)
+		}
+		matches = append(matches, match)
+	}
+
+	if !transaction.Amount.IsPositive() {
+		return matches, nil
+	}
+
+	var receipts []models.Receipt
+	if err := tx.Where("seller_id = ? AND amount = ? AND receipt_date >= ? AND receipt_date < ?", transaction.SellerID, transaction.Amount, from, to).
+		Where("NOT EXISTS (SELECT 1 FROM bank_transactions WHERE bank_transactions.receipt_id = receipts.id AND bank_transactions.status IN ? AND bank_transactions.id <> ?)",
+			linked, transaction.ID).
+		Order("id").Find(&receipts).Error; err != nil {
+		return nil, err
+	}
+	for _, receipt := range receipts {
+		match := bankMatch{
+			matchType: constants.MatchTypeReceipt,
+			id:        receipt.ID,
+			score:     matchScoreAmount + matchScoreDate,
+			reason:    fmt.Sprintf("Amount and date match receipt %d", receipt.ID),
+		}
+		if containsReference(text, receipt.Reference) {
+			match.score += matchScoreReference
+			match.reason += fmt.Sprintf(" and reference %s", receipt.Reference)
+		}
+		matches = append(matches, match)
+	}
+
+	for i := range openInvoices {
+		invoice := &openInvoices[i]
+		if invoice.InvoiceDate.After(to) {
+			continue
+		}
+		exact := invoice.AmountDue == transaction.Amount
+		numbered := containsReference(text, invoice.InvoiceNumber)
+		if !exact && !(numbered && (transaction.Amount < invoice.AmountDue || invoice.PartyID != nil)) {
+			continue
+		}
+
+		match := bankMatch{matchType: constants.MatchTypeInvoice, id: invoice.ID, invoiceID: &invoice.ID}
+		var parts []string
+		if exact {
+			match.score += matchScoreAmount
+			parts = append(parts, "amount due")
+		}
+		if !invoice.DueDate.Before(from) && invoice.DueDate.Before(to) {
+			match.score += matchScoreDate
+			parts = append(parts, "due date")
+		}
+		if numbered {
+			match.score += matchScoreReference
+			parts = append(parts, "invoice number")
+		}
+		match.reason = fmt.Sprintf("Invoice %s matches on %s", invoice.InvoiceNumber, strings.Join(parts, ", "))
+		matches = append(matches, match)
+	}
+
+	return matches, nil
+}
+
+// checkNotReconciled refuses a match to a payment or receipt another bank
+// transaction has already been reconciled with
+func checkNotReconciled(tx *gorm.DB, transaction *models.BankTransaction, column string, id uint) error {
+	var count int64
+	if err := tx.Model(&models.BankTransaction{}).
+		Where(column+" = ? AND status = ? AND id <> ?", id, constants.BankTransactionReconciled, transaction.ID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("already reconciled with another bank transaction")
+	}
+	return nil
+}
+
+// clearMatch removes a transaction's proposed match
+func clearMatch(transaction *models.BankTransaction) {
+	transaction.MatchType = ""
+	transaction.InvoiceID = nil
+	transaction.PaymentID = nil
+	transaction.ReceiptID = nil
+	transaction.MatchReason = ""
+}
+
+// checkMatchWindow validates the number of days either side of a
+// transaction in which payments and receipts are matched
+func checkMatchWindow(windowDays int) error {
+	if windowDays < 0 || windowDays > constants.MaxMatchWindowDays {
+		return fmt.Errorf("window_days must be between 0 and %d", constants.MaxMatchWindowDays)
+	}
+	return nil
+}
+
+// matchKey identifies a candidate across the transactions of one run
+func matchKey(matchType string, id uint) string {
+	return fmt.Sprintf("%s:%d", matchType, id)
+}
+
+// statementLineKey identifies a statement line by its contents
+func statementLineKey(account string, line bankstatement.Transaction) string {
+	return fmt.Sprintf("%s|%s|%d|%s|%s|%s", account, line.Date.Format("2006-01-02"), line.Amount.Paise(),
+		line.Reference, line.BankReference, line.Description)
+}
+
+// fingerprint hashes a statement line key to a fixed length
+func fingerprint(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeReference reduces a reference or narration to upper-case
+// letters and digits, so that "INV/25-26/00012" is found in
+// "NEFT-INV252600012-ACME"
+func normalizeReference(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		}
+		return -1
+	}, s)
+}
+
+// containsReference reports whether a reference appears in normalised
+// transaction text
+func containsReference(text, reference string) bool {
+	reference = normalizeReference(reference)
+	return len(reference) >= minReferenceLength && strings.Contains(text, reference)
+}
@@ -7,6 +7,7 @@ import (
 	"gorm.io/gorm"
 	"invoice-generator/internal/database"
 	"invoice-generator/internal/models"
+	"invoice-generator/internal/validation"
 )
 
 // CatalogService handles categories and items business logic
@@ -57,6 +58,13 @@ func (s *CatalogService) CreateItem(item *models.Item) error {
 		return err
 	}
 
+	item.HSNCode = strings.TrimSpace(item.HSNCode)
+	if item.HSNCode != "" {
+		if err := validation.ValidateHSN(item.HSNCode); err != nil {
+			return errors.New("hsn_code " + err.Error())
+		}
+	}
+
 	if err := database.GetDB().Create(item).Error; err != nil {
 		return errors.New("failed to create item")
 	}
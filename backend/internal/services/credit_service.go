@@ -0,0 +1,371 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"invoice-generator/internal/constants"
+	"invoice-generator/internal/database"
+	"invoice-generator/internal/models"
+	"invoice-generator/internal/money"
+)
+
+// CreditService keeps the credit balances of a seller's parties: advances
+// received before invoicing, the excess of overpayments, and credit
+// applied to later invoices
+type CreditService struct {
+	invoiceService *InvoiceService
+	partyService   *PartyService
+}
+
+// NewCreditService creates a new credit service
+func NewCreditService(invoiceService *InvoiceService, partyService *PartyService) *CreditService {
+	return &CreditService{invoiceService: invoiceService, partyService: partyService}
+}
+
+// AddAdvance records money received from a party before it is invoiced.
+// The advance is added to the party's credit balance, to be applied to
+// invoices later.
+func (s *CreditService) AddAdvance(partyID uint, entry *models.CreditEntry, userID uint, isAdmin bool) error {
+	*entry = models.CreditEntry{
+		EntryType:     constants.CreditEntryAdvance,
+		Amount:        entry.Amount,
+		EntryDate:     entry.EntryDate,
+		PaymentMethod: entry.PaymentMethod,
+		Reference:     strings.TrimSpace(entry.Reference),
+		Notes:         entry.Notes,
+		CreatedByID:   userID,
+	}
+	if entry.Amount <= 0 {
+		return errors.New("advance amount must be positive")
+	}
+	if !isAdvanceMethod(entry.PaymentMethod) {
+		return errors.New("payment method must be CASH, BANK_TRANSFER, CHEQUE, UPI or CARD")
+	}
+	if entry.EntryDate.IsZero() {
+		entry.EntryDate = time.Now()
+	}
+
+	return database.GetDB().Transaction(func(tx *gorm.DB) error {
+		party, err := s.partyService.lockParty(tx, partyID, userID, isAdmin)
+		if err != nil {
+			return err
+		}
+		entry.SellerID = party.OwnerID
+		entry.PartyID = party.ID
+
+		if err := tx.Omit("Party").Create(entry).Error; err != nil {
+			return fmt.Errorf("failed to add advance: %w", err)
+		}
+		return nil
+	})
+}
+
+// ReverseAdvance undoes an advance recorded by mistake with a reversing
+// entry, keeping both. The advance must not have been used yet.
+func (s *CreditService) ReverseAdvance(id uint, reason string, userID uint, isAdmin bool) (*models.CreditEntry, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, errors.New("reason is required")
+	}
+
+	var advance models.CreditEntry
+	if err := database.GetDB().First(&advance, id).Error; err != nil {
+		return nil, errors.New("credit entry not found")
+	}
+
+	var reversal models.CreditEntry
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if _, err := s.partyService.lockParty(tx, advance.PartyID, userID, isAdmin); err != nil {
+			return errors.New("credit entry not found")
+		}
+		if advance.EntryType != constants.CreditEntryAdvance {
+			return errors.New("only advances can be reversed; reverse the payment instead")
+		}
+		if advance.ReceiptID != nil {
+			return errors.New("advance is the unallocated part of a receipt; change the receipt's allocations instead")
+		}
+
+		var count int64
+		if err := tx.Model(&models.CreditEntry{}).Where("reversed_entry_id = ?", advance.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errors.New("advance has already been reversed")
+		}
+
+		reversal = models.CreditEntry{
+			SellerID:        advance.SellerID,
+			PartyID:         advance.PartyID,
+			EntryType:       constants.CreditEntryReversal,
+			Amount:          -advance.Amount,
+			EntryDate:       time.Now(),
+			Reference:       advance.Reference,
+			Notes:           reason,
+			ReversedEntryID: &advance.ID,
+			CreatedByID:     userID,
+		}
+		return addCreditEntry(tx, &reversal, "advance has already been applied to invoices; reverse those payments first")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &reversal, nil
+}
+
+// ApplyCredit pays an issued invoice from its party's credit balance. The
+// amount defaults to as much as is both available and due. The payment is
+// recorded on the invoice with the CREDIT method and can be reversed like
+// any other, which restores the credit.
+func (s *CreditService) ApplyCredit(invoiceID uint, amount money.Amount, userID uint, isAdmin bool) (*models.Payment, error) {
+	if amount < 0 {
+		return nil, errors.New("amount cannot be negative")
+	}
+
+	var payment models.Payment
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		invoice, err := s.invoiceService.lockInvoice(tx, invoiceID, userID, isAdmin)
+		if err != nil {
+			return err
+		}
+		if invoice.Status != constants.InvoiceStatusIssued {
+			return errors.New("credit can only be applied to issued invoices")
+		}
+		if invoice.PartyID == nil {
+			return errors.New("credit can only be applied to invoices billed to a party")
+		}
+		if invoice.AmountDue <= 0 {
+			return errors.New("invoice has nothing due")
+		}
+
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.Party{}, *invoice.PartyID).Error; err != nil {
+			return err
+		}
+		balance, err := creditBalance(tx, *invoice.PartyID)
+		if err != nil {
+			return err
+		}
+		if amount == 0 {
+			amount = money.Min(balance, invoice.AmountDue)
+		}
+		if amount <= 0 {
+			return errors.New("party has no credit available")
+		}
+		if amount > invoice.AmountDue {
+			return errors.New("amount cannot exceed amount due")
+		}
+
+		payment = models.Payment{
+			InvoiceID:     invoice.ID,
+			PaymentType:   constants.PaymentTypeReceipt,
+			Amount:        amount,
+			PaymentMethod: constants.PaymentMethodCredit,
+			PaymentDate:   time.Now(),
+			Status:        constants.PaymentCleared,
+		}
+		if err := tx.Create(&payment).Error; err != nil {
+			return fmt.Errorf("failed to add payment: %w", err)
+		}
+		entry := models.CreditEntry{
+			SellerID:    invoice.GeneratedByID,
+			PartyID:     *invoice.PartyID,
+			EntryType:   constants.CreditEntryApplied,
+			Amount:      -amount,
+			EntryDate:   payment.PaymentDate,
+			Reference:   invoice.InvoiceNumber,
+			InvoiceID:   &invoice.ID,
+			PaymentID:   &payment.ID,
+			CreatedByID: userID,
+		}
+		if err := addCreditEntry(tx, &entry, "amount exceeds the party's credit balance"); err != nil {
+			return err
+		}
+		if err := recordPaymentEvent(tx, &payment, constants.PaymentActionRecorded, "", "", userID); err != nil {
+			return err
+		}
+		return updateInvoicePaymentStatus(tx, invoice.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &payment, nil
+}
+
+// GetStatement returns a party's credit balance and entries
+func (s *CreditService) GetStatement(partyID uint, userID uint, isAdmin bool) (*models.CreditStatement, error) {
+	party, err := s.partyService.GetParty(partyID, userID, isAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	statement := &models.CreditStatement{PartyID: party.ID, Entries: []models.CreditEntry{}}
+	if err := database.GetDB().Where("party_id = ?", party.ID).Order("entry_date, id").
+		Find(&statement.Entries).Error; err != nil {
+		return nil, err
+	}
+	for _, entry := range statement.Entries {
+		statement.Balance += entry.Amount
+	}
+
+	return statement, nil
+}
+
+// GetBalances returns the parties with a nonzero balance, largest first;
+// a negative balance is owed by the party. Admins see the parties of
+// every seller.
+func (s *CreditService) GetBalances(userID uint, isAdmin bool) ([]models.CreditBalance, error) {
+	query := database.GetDB().Model(&models.CreditEntry{}).
+		Select("credit_entries.seller_id, credit_entries.party_id, parties.name AS party_name, " +
+			"parties.company_name, SUM(credit_entries.amount) AS balance").
+		Joins("JOIN parties ON parties.id = credit_entries.party_id").
+		Group("credit_entries.seller_id, credit_entries.party_id, parties.name, parties.company_name").
+		Having("SUM(credit_entries.amount) <> 0").
+		Order("balance DESC")
+	if !isAdmin {
+		query = query.Where("credit_entries.seller_id = ?", userID)
+	}
+
+	balances := []models.CreditBalance{}
+	if err := query.Scan(&balances).Error; err != nil {
+		return nil, err
+	}
+	return balances, nil
+}
+
+// creditBalance returns the credit available to a party. Callers that
+// change the balance must hold a lock on the party row.
+func creditBalance(tx *gorm.DB, partyID uint) (money.Amount, error) {
+	var balance money.Amount
+	err := tx.Model(&models.CreditEntry{}).Where("party_id = ?", partyID).
+		Select("COALESCE(SUM(amount), 0)").Row().Scan(&balance)
+	return balance, err
+}
+
+// addCreditEntry stores an entry in the caller's transaction. If
+// insufficient is given, an entry that would take the party's balance
+// below zero is refused with it. The party row must be locked.
+func addCreditEntry(tx *gorm.DB, entry *models.CreditEntry, insufficient string) error {
+	if entry.Amount < 0 && insufficient != "" {
+		balance, err := creditBalance(tx, entry.PartyID)
+		if err != nil {
+			return err
+		}
+		if balance+entry.Amount < 0 {
+			return errors.New(insufficient)
+		}
+	}
+	if err := tx.Omit("Party").Create(entry).Error; err != nil {
+		return fmt.Errorf("failed to record credit entry: %w", err)
+	}
+	return nil
+}
+
+// creditOverpayment adds the excess of a payment over the amount due to
+// the party's credit balance
+func creditOverpayment(tx *gorm.DB, invoice *models.Invoice, payment *models.Payment, userID uint) error {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.Party{}, *invoice.PartyID).Error; err != nil {
+		return err
+	}
+	return addCreditEntry(tx, &models.CreditEntry{
+		SellerID:      invoice.GeneratedByID,
+		PartyID:       *invoice.PartyID,
+		EntryType:     constants.CreditEntryOverpayment,
+		Amount:        payment.CreditAmount,
+		EntryDate:     payment.PaymentDate,
+		PaymentMethod: payment.PaymentMethod,
+		Reference:     payment.Reference,
+		InvoiceID:     &invoice.ID,
+		PaymentID:     &payment.ID,
+		CreatedByID:   userID,
+	}, "")
+}
+
+// creditUnallocatedReceipt keeps the party's credit balance in step with
+// the unallocated amount of a receipt. The difference from what is already
+// held for the receipt is posted as an advance, or taken back with a
+// reversal when a reallocation uses more of the receipt.
+func creditUnallocatedReceipt(tx *gorm.DB, receipt *models.Receipt, userID uint) error {
+	if receipt.PartyID == nil {
+		return nil
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.Party{}, *receipt.PartyID).Error; err != nil {
+		return err
+	}
+
+	var held money.Amount
+	if err := tx.Model(&models.CreditEntry{}).Where("receipt_id = ?", receipt.ID).
+		Select("COALESCE(SUM(amount), 0)").Row().Scan(&held); err != nil {
+		return err
+	}
+	difference := receipt.UnallocatedAmount - held
+	if difference.IsZero() {
+		return nil
+	}
+
+	entry := models.CreditEntry{
+		SellerID:      receipt.SellerID,
+		PartyID:       *receipt.PartyID,
+		EntryType:     constants.CreditEntryAdvance,
+		Amount:        difference,
+		EntryDate:     receipt.ReceiptDate,
+		PaymentMethod: receipt.PaymentMethod,
+		Reference:     receipt.Reference,
+		ReceiptID:     &receipt.ID,
+		CreatedByID:   userID,
+	}
+	if difference < 0 {
+		entry.EntryType = constants.CreditEntryReversal
+		entry.EntryDate = time.Now()
+		entry.PaymentMethod = ""
+		entry.Notes = reallocatedReason
+	}
+	return addCreditEntry(tx, &entry, "the unallocated amount of this receipt has already been applied as credit; reverse those payments first")
+}
+
+// reverseCredit undoes the credit side of a payment that has bounced or
+// been reversed: the excess of an overpayment is taken back off the
+// party's balance, and a payment made from credit gives the credit back.
+// A bounce has already happened at the bank, so the excess is taken back
+// even if it has been applied; the balance then goes negative, which the
+// party owes until later advances or overpayments make it up.
+func reverseCredit(tx *gorm.DB, invoice *models.Invoice, payment *models.Payment, userID uint) error {
+	amount := -payment.CreditAmount
+	if payment.PaymentMethod == constants.PaymentMethodCredit {
+		amount = payment.Amount
+	}
+	if amount == 0 || invoice.PartyID == nil {
+		return nil
+	}
+
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.Party{}, *invoice.PartyID).Error; err != nil {
+		return err
+	}
+	return addCreditEntry(tx, &models.CreditEntry{
+		SellerID:    invoice.GeneratedByID,
+		PartyID:     *invoice.PartyID,
+		EntryType:   constants.CreditEntryReversal,
+		Amount:      amount,
+		EntryDate:   time.Now(),
+		Reference:   payment.Reference,
+		Notes:       payment.Reason,
+		InvoiceID:   &invoice.ID,
+		PaymentID:   &payment.ID,
+		CreatedByID: userID,
+	}, "")
+}
+
+// isAdvanceMethod reports whether money can be received by a method
+func isAdvanceMethod(method string) bool {
+	switch method {
+	case constants.PaymentMethodCash, constants.PaymentMethodBankTransfer, constants.PaymentMethodCheque,
+		constants.PaymentMethodUPI, constants.PaymentMethodCard:
+		return true
+	}
+	return false
+}
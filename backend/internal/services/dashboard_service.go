@@ -1,8 +1,11 @@
 package services
 
 import (
+	"math"
 	"time"
 
+	"gorm.io/gorm"
+	"invoice-generator/internal/constants"
 	"invoice-generator/internal/database"
 	"invoice-generator/internal/models"
 )
@@ -15,6 +18,12 @@ func NewDashboardService() *DashboardService {
 	return &DashboardService{}
 }
 
+// issuedInvoices scopes a query to invoices that have been issued and not
+// voided or cancelled, which are the only ones that count towards totals
+func issuedInvoices() *gorm.DB {
+	return database.GetDB().Model(&models.Invoice{}).Where("status = ?", constants.InvoiceStatusIssued)
+}
+
 // GetDashboardStats returns dashboard statistics for a user
 func (s *DashboardService) GetDashboardStats(userID uint, isAdmin bool) (*models.DashboardStats, error) {
 	today := time.Now().Truncate(24 * time.Hour)
@@ -23,39 +32,39 @@ func (s *DashboardService) GetDashboardStats(userID uint, isAdmin bool) (*models
 	stats := &models.DashboardStats{}
 
 	// Today's sales (cash + credit sales where user is generator)
-	database.GetDB().Model(&models.Invoice{}).Where("generated_by_id = ? AND invoice_date >= ? AND invoice_date < ?",
+	issuedInvoices().Where("generated_by_id = ? AND invoice_date >= ? AND invoice_date < ?",
 		userID, today, tomorrow).
 		Select("COALESCE(SUM(total_amount), 0)").Row().Scan(&stats.TodaySales)
 
 	// Today's credit (invoices generated for others)
-	database.GetDB().Model(&models.Invoice{}).Where("generated_by_id = ? AND generated_for_id != ? AND invoice_date >= ? AND invoice_date < ?",
+	issuedInvoices().Where("generated_by_id = ? AND generated_for_id IS DISTINCT FROM ? AND invoice_date >= ? AND invoice_date < ?",
 		userID, userID, today, tomorrow).
 		Select("COALESCE(SUM(amount_due), 0)").Row().Scan(&stats.TodayCredit)
 
 	// Today's debit (invoices received from others)
-	database.GetDB().Model(&models.Invoice{}).Where("generated_for_id = ? AND generated_by_id != ? AND invoice_date >= ? AND invoice_date < ?",
+	issuedInvoices().Where("generated_for_id = ? AND generated_by_id != ? AND invoice_date >= ? AND invoice_date < ?",
 		userID, userID, today, tomorrow).
 		Select("COALESCE(SUM(amount_due), 0)").Row().Scan(&stats.TodayDebit)
 
 	// Total receivables (what others owe to user)
-	database.GetDB().Model(&models.Invoice{}).Where("generated_by_id = ? AND generated_for_id != ? AND amount_due > 0",
+	issuedInvoices().Where("generated_by_id = ? AND generated_for_id IS DISTINCT FROM ? AND amount_due > 0",
 		userID, userID).
 		Select("COALESCE(SUM(amount_due), 0)").Row().Scan(&stats.TotalReceivables)
 
 	// Total payables (what user owes to others)
-	database.GetDB().Model(&models.Invoice{}).Where("generated_for_id = ? AND generated_by_id != ? AND amount_due > 0",
+	issuedInvoices().Where("generated_for_id = ? AND generated_by_id != ? AND amount_due > 0",
 		userID, userID).
 		Select("COALESCE(SUM(amount_due), 0)").Row().Scan(&stats.TotalPayables)
 
 	// Pending invoices count
-	query := database.GetDB().Model(&models.Invoice{}).Where("payment_status != 'PAID'")
+	query := issuedInvoices().Where("payment_status != 'PAID'")
 	if !isAdmin {
 		query = query.Where("generated_by_id = ? OR generated_for_id = ?", userID, userID)
 	}
 	query.Count(&stats.PendingInvoices)
 
 	// Total invoices count
-	query = database.GetDB().Model(&models.Invoice{})
+	query = issuedInvoices()
 	if !isAdmin {
 		query = query.Where("generated_by_id = ? OR generated_for_id = ?", userID, userID)
 	}
@@ -63,16 +72,53 @@ func (s *DashboardService) GetDashboardStats(userID uint, isAdmin bool) (*models
 
 	// This month's sales
 	startOfMonth := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, today.Location())
-	database.GetDB().Model(&models.Invoice{}).Where("generated_by_id = ? AND invoice_date >= ?",
+	issuedInvoices().Where("generated_by_id = ? AND invoice_date >= ?",
 		userID, startOfMonth).
 		Select("COALESCE(SUM(total_amount), 0)").Row().Scan(&stats.ThisMonthSales)
 
+	// This month's output tax by type
+	issuedInvoices().Where("generated_by_id = ? AND invoice_date >= ?",
+		userID, startOfMonth).
+		Select("COALESCE(SUM(total_cgst), 0), COALESCE(SUM(total_sgst), 0), COALESCE(SUM(total_igst), 0)").
+		Row().Scan(&stats.ThisMonthCGST, &stats.ThisMonthSGST, &stats.ThisMonthIGST)
+
+	// This month's discounts allowed
+	issuedInvoices().Where("generated_by_id = ? AND invoice_date >= ?",
+		userID, startOfMonth).
+		Select("COALESCE(SUM(total_discount), 0)").Row().Scan(&stats.ThisMonthDiscounts)
+
+	// This month's credit and debit notes issued
+	database.GetDB().Model(&models.AdjustmentNote{}).Where("generated_by_id = ? AND note_date >= ?",
+		userID, startOfMonth).
+		Select("COALESCE(SUM(CASE WHEN note_type = ? THEN total_amount ELSE 0 END), 0), "+
+			"COALESCE(SUM(CASE WHEN note_type = ? THEN total_amount ELSE 0 END), 0)",
+			constants.NoteTypeCredit, constants.NoteTypeDebit).
+		Row().Scan(&stats.ThisMonthCredits, &stats.ThisMonthDebits)
+
 	// Last month's sales
 	lastMonth := startOfMonth.AddDate(0, -1, 0)
-	database.GetDB().Model(&models.Invoice{}).Where("generated_by_id = ? AND invoice_date >= ? AND invoice_date < ?",
+	issuedInvoices().Where("generated_by_id = ? AND invoice_date >= ? AND invoice_date < ?",
 		userID, lastMonth, startOfMonth).
 		Select("COALESCE(SUM(total_amount), 0)").Row().Scan(&stats.LastMonthSales)
 
+	// Quote-to-cash conversion
+	if err := expireQuotations(database.GetDB()); err != nil {
+		return nil, err
+	}
+	database.GetDB().Model(&models.Quotation{}).Where("generated_by_id = ?", userID).
+		Count(&stats.TotalQuotations)
+	database.GetDB().Model(&models.Quotation{}).Where("generated_by_id = ? AND EXISTS (?)", userID,
+		database.GetDB().Model(&models.Invoice{}).Select("1").
+			Where("invoices.quotation_id = quotations.id AND invoices.status = ?", constants.InvoiceStatusIssued)).
+		Count(&stats.ConvertedQuotations)
+	if stats.TotalQuotations > 0 {
+		rate := float64(stats.ConvertedQuotations) * 100 / float64(stats.TotalQuotations)
+		stats.QuotationConversionRate = math.Round(rate*100) / 100
+	}
+	database.GetDB().Model(&models.Quotation{}).Where("generated_by_id = ? AND status IN ?", userID,
+		[]string{constants.QuotationStatusOpen, constants.QuotationStatusAccepted}).
+		Select("COALESCE(SUM(total_amount), 0)").Row().Scan(&stats.OpenQuotationValue)
+
 	return stats, nil
 }
 
@@ -87,22 +133,30 @@ func (s *DashboardService) GetAdminStats() (*models.AdminStats, error) {
 	database.GetDB().Model(&models.User{}).Count(&stats.TotalUsers)
 
 	// Count invoices
-	database.GetDB().Model(&models.Invoice{}).Count(&stats.TotalInvoices)
+	issuedInvoices().Count(&stats.TotalInvoices)
 
 	// Total amount
-	database.GetDB().Model(&models.Invoice{}).Select("COALESCE(SUM(total_amount), 0)").Row().Scan(&stats.TotalAmount)
+	issuedInvoices().Select("COALESCE(SUM(total_amount), 0)").Row().Scan(&stats.TotalAmount)
 
 	// Pending amount
-	database.GetDB().Model(&models.Invoice{}).Where("payment_status != 'PAID'").
+	issuedInvoices().Where("payment_status != 'PAID'").
 		Select("COALESCE(SUM(amount_due), 0)").Row().Scan(&stats.PendingAmount)
 
 	// Today's invoices
-	database.GetDB().Model(&models.Invoice{}).Where("invoice_date >= ? AND invoice_date < ?", today, tomorrow).
+	issuedInvoices().Where("invoice_date >= ? AND invoice_date < ?", today, tomorrow).
 		Count(&stats.TodayInvoices)
 
 	// Today's amount
-	database.GetDB().Model(&models.Invoice{}).Where("invoice_date >= ? AND invoice_date < ?", today, tomorrow).
+	issuedInvoices().Where("invoice_date >= ? AND invoice_date < ?", today, tomorrow).
 		Select("COALESCE(SUM(total_amount), 0)").Row().Scan(&stats.TodayAmount)
 
+	// Tax collected by type
+	issuedInvoices().
+		Select("COALESCE(SUM(total_cgst), 0), COALESCE(SUM(total_sgst), 0), COALESCE(SUM(total_igst), 0)").
+		Row().Scan(&stats.TotalCGST, &stats.TotalSGST, &stats.TotalIGST)
+
+	// Discounts allowed
+	issuedInvoices().Select("COALESCE(SUM(total_discount), 0)").Row().Scan(&stats.TotalDiscount)
+
 	return stats, nil
 }
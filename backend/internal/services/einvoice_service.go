@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"invoice-generator/internal/constants"
+	"invoice-generator/internal/database"
+	"invoice-generator/internal/einvoice"
+	"invoice-generator/internal/models"
+	"invoice-generator/internal/validation"
+)
+
+// EInvoiceService reports issued invoices to the Invoice Registration
+// Portal (IRP) as e-invoices and keeps the IRN it returns
+type EInvoiceService struct {
+	invoiceService *InvoiceService
+	client         einvoice.Client
+}
+
+// NewEInvoiceService creates a new e-invoice service that registers
+// e-invoices through client
+func NewEInvoiceService(invoiceService *InvoiceService, client einvoice.Client) *EInvoiceService {
+	return &EInvoiceService{invoiceService: invoiceService, client: client}
+}
+
+// GetPayload builds the e-invoice of an issued invoice without uploading
+// it, together with any schema violations that would make the IRP reject
+// it
+func (s *EInvoiceService) GetPayload(id uint, userID uint, isAdmin bool) (*einvoice.Invoice, validation.Errors, error) {
+	invoice, err := s.invoiceService.GetInvoice(id, userID, isAdmin)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !isAdmin && invoice.GeneratedByID != userID {
+		return nil, nil, errors.New("invoice not found")
+	}
+	if err := checkEInvoiceable(invoice); err != nil {
+		return nil, nil, err
+	}
+
+	payload := einvoice.FromInvoice(invoice)
+	var problems validation.Errors
+	errors.As(payload.Validate(), &problems)
+	return payload, problems, nil
+}
+
+// GenerateIRN uploads an issued invoice to the IRP and stores the IRN,
+// acknowledgement and signed QR code it returns. The invoice stays locked
+// while the IRP is called so that it is registered at most once.
+func (s *EInvoiceService) GenerateIRN(ctx context.Context, id uint, userID uint, isAdmin bool) (*models.Invoice, error) {
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		invoice, err := s.invoiceService.lockInvoice(tx, id, userID, isAdmin)
+		if err != nil {
+			return err
+		}
+		if err := checkEInvoiceable(invoice); err != nil {
+			return err
+		}
+		if invoice.IRN != "" {
+			if invoice.IRNCancelledAt != nil {
+				return errors.New("the IRN of this invoice has been cancelled; its number cannot be registered again")
+			}
+			return errors.New("invoice already has an IRN")
+		}
+		if err := tx.Preload("Item").Where("invoice_id = ?", invoice.ID).Order("id").
+			Find(&invoice.LineItems).Error; err != nil {
+			return err
+		}
+
+		payload := einvoice.FromInvoice(invoice)
+		if err := payload.Validate(); err != nil {
+			return err
+		}
+		ack, err := s.client.GenerateIRN(ctx, payload)
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(invoice).Updates(map[string]interface{}{
+			"irn":            ack.IRN,
+			"ack_no":         ack.AckNo,
+			"ack_date":       ack.AckDate,
+			"signed_qr_code": ack.SignedQRCode,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.invoiceService.GetInvoice(id, userID, isAdmin)
+}
+
+// CancelIRN cancels the IRN of an invoice on the IRP and records the
+// cancellation, after which the invoice itself can be cancelled. The IRP
+// only accepts cancellations within a day of registration.
+func (s *EInvoiceService) CancelIRN(ctx context.Context, id uint, request *models.IRNCancelRequest, userID uint, isAdmin bool) (*models.Invoice, error) {
+	remarks := strings.TrimSpace(request.Remarks)
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		invoice, err := s.invoiceService.lockInvoice(tx, id, userID, isAdmin)
+		if err != nil {
+			return err
+		}
+		if invoice.IRN == "" {
+			return errors.New("invoice has no IRN")
+		}
+		if invoice.IRNCancelledAt != nil {
+			return errors.New("IRN has already been cancelled")
+		}
+		if invoice.AckDate != nil && time.Since(*invoice.AckDate) > einvoice.CancelWindow {
+			return errors.New("the IRP only cancels an IRN within 24 hours of registration; issue a credit note instead")
+		}
+
+		cancellation := &einvoice.Cancellation{
+			IRN:        invoice.IRN,
+			ReasonCode: request.ReasonCode,
+			Remarks:    remarks,
+		}
+		if err := cancellation.Validate(); err != nil {
+			return err
+		}
+		ack, err := s.client.CancelIRN(ctx, cancellation)
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(invoice).Updates(map[string]interface{}{
+			"irn_cancelled_at":   ack.CancelDate,
+			"irn_cancel_remarks": remarks,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.invoiceService.GetInvoice(id, userID, isAdmin)
+}
+
+// checkEInvoiceable makes sure an invoice is one the IRP accepts: issued,
+// and to a buyer registered for GST
+func checkEInvoiceable(invoice *models.Invoice) error {
+	if invoice.Status != constants.InvoiceStatusIssued {
+		return errors.New("only issued invoices can be registered with the IRP")
+	}
+	if invoice.Buyer.GSTIN == "" {
+		return errors.New("e-invoices can only be generated for buyers with a GSTIN")
+	}
+	return nil
+}
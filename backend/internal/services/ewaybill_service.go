@@ -0,0 +1,86 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"invoice-generator/internal/constants"
+	"invoice-generator/internal/ewaybill"
+	"invoice-generator/internal/models"
+	"invoice-generator/internal/validation"
+)
+
+// EWayBillService builds e-way bills for issued goods invoices, to be
+// uploaded to the e-way bill portal's bulk generation tool
+type EWayBillService struct {
+	invoiceService *InvoiceService
+}
+
+// NewEWayBillService creates a new e-way bill service
+func NewEWayBillService(invoiceService *InvoiceService) *EWayBillService {
+	return &EWayBillService{invoiceService: invoiceService}
+}
+
+// GetEWayBill builds the e-way bill of one issued invoice, together with
+// any missing or invalid fields that would make the portal reject it
+func (s *EWayBillService) GetEWayBill(id uint, userID uint, isAdmin bool) (*ewaybill.Bill, validation.Errors, error) {
+	invoice, err := s.invoiceService.GetInvoice(id, userID, isAdmin)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !isAdmin && invoice.GeneratedByID != userID {
+		return nil, nil, errors.New("invoice not found")
+	}
+	if invoice.Status != constants.InvoiceStatusIssued {
+		return nil, nil, errors.New("e-way bills can only be generated for issued invoices")
+	}
+
+	bill := ewaybill.FromInvoice(invoice)
+	var problems validation.Errors
+	errors.As(bill.Validate(), &problems)
+	return bill, problems, nil
+}
+
+// ExportEWayBills builds the bulk generation file of a seller's issued
+// invoices dated from and to (inclusive) whose goods are worth more than
+// the e-way bill threshold. Invoices with missing or invalid fields are
+// left out of the file and reported as errors, so that they can be
+// corrected and exported again.
+func (s *EWayBillService) ExportEWayBills(sellerID uint, from, to time.Time) (*ewaybill.BulkFile, []models.ReturnError, error) {
+	var invoices []models.Invoice
+	if err := issuedInvoices().Preload("LineItems", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).Preload("LineItems.Item").
+		Where("generated_by_id = ? AND invoice_date >= ? AND invoice_date < ?", sellerID, from, to.AddDate(0, 0, 1)).
+		Order("invoice_date, id").Find(&invoices).Error; err != nil {
+		return nil, nil, err
+	}
+
+	file := &ewaybill.BulkFile{Version: ewaybill.Version, Bills: []ewaybill.Bill{}}
+	returnErrors := []models.ReturnError{}
+	for i := range invoices {
+		invoice := &invoices[i]
+		if ewaybill.ConsignmentValue(invoice) <= ewaybill.Threshold {
+			continue
+		}
+
+		bill := ewaybill.FromInvoice(invoice)
+		var problems validation.Errors
+		if errors.As(bill.Validate(), &problems) {
+			for _, problem := range problems {
+				returnErrors = append(returnErrors, models.ReturnError{
+					DocumentType:   constants.DocumentTypeInvoice,
+					DocumentID:     invoice.ID,
+					DocumentNumber: invoice.InvoiceNumber,
+					Field:          problem.Field,
+					Message:        problem.Message,
+				})
+			}
+			continue
+		}
+		file.Bills = append(file.Bills, *bill)
+	}
+
+	return file, returnErrors, nil
+}
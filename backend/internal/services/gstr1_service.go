@@ -0,0 +1,556 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"invoice-generator/internal/constants"
+	"invoice-generator/internal/database"
+	"invoice-generator/internal/gst"
+	"invoice-generator/internal/models"
+	"invoice-generator/internal/money"
+	"invoice-generator/internal/validation"
+)
+
+// GSTR1Service builds GSTR-1 returns from a seller's invoices and credit
+// and debit notes
+type GSTR1Service struct{}
+
+// NewGSTR1Service creates a new GSTR-1 service
+func NewGSTR1Service() *GSTR1Service {
+	return &GSTR1Service{}
+}
+
+// GenerateGSTR1 builds the GSTR-1 return of a seller for a period. Invoices
+// and notes missing a field the return requires are left out of it and
+// reported as errors, so that the file still imports and the documents can
+// be corrected and added before filing.
+func (s *GSTR1Service) GenerateGSTR1(sellerID uint, period gst.ReturnPeriod) (*gst.GSTR1, []models.ReturnError, error) {
+	db := database.GetDB()
+
+	var seller models.User
+	if err := db.First(&seller, sellerID).Error; err != nil {
+		return nil, nil, errors.New("seller not found")
+	}
+	gstin := validation.Normalise(seller.GSTIN)
+	if gstin == "" {
+		return nil, nil, errors.New("a GSTIN is required on the seller's profile to file GSTR-1")
+	}
+	if err := validation.ValidateGSTIN(gstin); err != nil {
+		return nil, nil, fmt.Errorf("seller GSTIN %s", err)
+	}
+
+	var invoices []models.Invoice
+	if err := db.Preload("GeneratedBy").Preload("GeneratedFor").Preload("Party").Preload("LineItems.Item").
+		Where("generated_by_id = ? AND status IN ? AND invoice_number <> ''", sellerID,
+			[]string{constants.InvoiceStatusIssued, constants.InvoiceStatusCancelled}).
+		Where("invoice_date >= ? AND invoice_date < ?", period.From, period.To).
+		Order("invoice_date, sequence_number").Find(&invoices).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var notes []models.AdjustmentNote
+	if err := db.Preload("LineItems").Preload("Invoice.GeneratedFor").Preload("Invoice.Party").
+		Preload("Invoice.LineItems.Item").
+		Where("generated_by_id = ? AND note_date >= ? AND note_date < ?", sellerID, period.From, period.To).
+		Order("note_date, sequence_number").Find(&notes).Error; err != nil {
+		return nil, nil, err
+	}
+
+	b := newGSTR1Builder()
+	for i := range invoices {
+		b.addInvoice(&invoices[i])
+	}
+	for i := range notes {
+		b.addNote(&notes[i])
+	}
+
+	if b.errors == nil {
+		b.errors = []models.ReturnError{}
+	}
+	return b.build(gstin, period), b.errors, nil
+}
+
+// gstr1Builder accumulates the sections of a GSTR-1 return
+type gstr1Builder struct {
+	b2b    map[string]*gst.B2B
+	b2cl   map[string]*gst.B2CL
+	b2cs   map[b2csKey]*gst.B2CS
+	cdnr   map[string]*gst.CDNR
+	cdnur  []gst.CDNURNote
+	hsnB2B map[hsnKey]*gst.HSNRow
+	hsnB2C map[hsnKey]*gst.HSNRow
+	docs   map[docKey]*docRange
+	errors []models.ReturnError
+}
+
+type b2csKey struct {
+	placeOfSupply string
+	rate          int
+}
+
+type hsnKey struct {
+	hsn  string
+	rate int
+}
+
+type docKey struct {
+	documentType int
+	seriesID     uint
+}
+
+// docRange tracks the lowest and highest number issued in a series
+type docRange struct {
+	first, last      int64
+	from, to         string
+	total, cancelled int
+}
+
+// taxLine is an invoice or note line as it is reported in a return
+type taxLine struct {
+	hsn          string
+	description  string
+	unit         string
+	quantity     money.Quantity
+	rate         int
+	taxableValue money.Amount
+	igst         money.Amount
+	cgst         money.Amount
+	sgst         money.Amount
+}
+
+func newGSTR1Builder() *gstr1Builder {
+	return &gstr1Builder{
+		b2b:    make(map[string]*gst.B2B),
+		b2cl:   make(map[string]*gst.B2CL),
+		b2cs:   make(map[b2csKey]*gst.B2CS),
+		cdnr:   make(map[string]*gst.CDNR),
+		hsnB2B: make(map[hsnKey]*gst.HSNRow),
+		hsnB2C: make(map[hsnKey]*gst.HSNRow),
+		docs:   make(map[docKey]*docRange),
+	}
+}
+
+// addInvoice reports an invoice under B2B, B2CL or B2CS and in the HSN and
+// document summaries. Cancelled invoices only count in the document
+// summary.
+func (b *gstr1Builder) addInvoice(invoice *models.Invoice) {
+	cancelled := invoice.Status == constants.InvoiceStatusCancelled
+	b.countDocument(gst.DocOutwardInvoices, invoice.SeriesID, invoice.SequenceNumber, invoice.InvoiceNumber, cancelled)
+	if cancelled {
+		return
+	}
+
+	buyer := invoiceBuyer(invoice)
+	ctin := validation.Normalise(buyer.GSTIN)
+	lines := make([]taxLine, len(invoice.LineItems))
+	for i := range invoice.LineItems {
+		lines[i] = invoiceTaxLine(&invoice.LineItems[i])
+	}
+
+	var errs validation.Errors
+	checkPlaceOfSupply(&errs, invoice.PlaceOfSupply)
+	if ctin != "" {
+		if err := validation.ValidateGSTIN(ctin); err != nil {
+			errs.Add("buyer.gstin", err.Error())
+		}
+	}
+	checkTaxLines(&errs, lines, "an HSN or SAC code is required for the HSN summary")
+	if b.reject(constants.DocumentTypeInvoice, invoice.ID, invoice.InvoiceNumber, errs) {
+		return
+	}
+
+	date := invoice.InvoiceDate.Format(gst.ReturnDate)
+	items := rateItems(lines)
+	switch {
+	case ctin != "":
+		entry := b.b2b[ctin]
+		if entry == nil {
+			entry = &gst.B2B{CTIN: ctin}
+			b.b2b[ctin] = entry
+		}
+		entry.Invoices = append(entry.Invoices, gst.B2BInvoice{
+			Number:        invoice.InvoiceNumber,
+			Date:          date,
+			Value:         invoice.TotalAmount,
+			PlaceOfSupply: invoice.PlaceOfSupply,
+			ReverseCharge: gst.ReverseChargeNo,
+			InvoiceType:   gst.InvoiceTypeRegular,
+			Items:         items,
+		})
+		addHSNLines(b.hsnB2B, lines, 1)
+	case isB2CL(invoice):
+		entry := b.b2cl[invoice.PlaceOfSupply]
+		if entry == nil {
+			entry = &gst.B2CL{PlaceOfSupply: invoice.PlaceOfSupply}
+			b.b2cl[invoice.PlaceOfSupply] = entry
+		}
+		entry.Invoices = append(entry.Invoices, gst.B2CLInvoice{
+			Number: invoice.InvoiceNumber,
+			Date:   date,
+			Value:  invoice.TotalAmount,
+			Items:  items,
+		})
+		addHSNLines(b.hsnB2C, lines, 1)
+	default:
+		b.addB2CS(invoice, lines, 1)
+		addHSNLines(b.hsnB2C, lines, 1)
+	}
+}
+
+// addNote reports a credit or debit note under CDNR or CDNUR, or as an
+// adjustment of B2CS when the original invoice was reported there
+func (b *gstr1Builder) addNote(note *models.AdjustmentNote) {
+	documentType, noteType, sign := gst.DocCreditNotes, gst.NoteTypeCredit, int64(-1)
+	if note.NoteType == constants.NoteTypeDebit {
+		documentType, noteType, sign = gst.DocDebitNotes, gst.NoteTypeDebit, 1
+	}
+	b.countDocument(documentType, note.SeriesID, note.SequenceNumber, note.NoteNumber, false)
+
+	invoice := note.Invoice
+	if invoice == nil {
+		b.reject(note.NoteType, note.ID, note.NoteNumber, validation.Errors{{Field: "invoice_id", Message: "original invoice not found"}})
+		return
+	}
+
+	lines := noteTaxLines(note)
+	buyer := invoiceBuyer(invoice)
+	ctin := validation.Normalise(buyer.GSTIN)
+
+	var errs validation.Errors
+	checkPlaceOfSupply(&errs, note.PlaceOfSupply)
+	if ctin != "" {
+		if err := validation.ValidateGSTIN(ctin); err != nil {
+			errs.Add("buyer.gstin", err.Error())
+		}
+	}
+	checkTaxLines(&errs, lines, "must refer to an invoice line with an HSN or SAC code for the HSN summary")
+	if b.reject(note.NoteType, note.ID, note.NoteNumber, errs) {
+		return
+	}
+
+	date := note.NoteDate.Format(gst.ReturnDate)
+	items := rateItems(lines)
+	switch {
+	case ctin != "":
+		entry := b.cdnr[ctin]
+		if entry == nil {
+			entry = &gst.CDNR{CTIN: ctin}
+			b.cdnr[ctin] = entry
+		}
+		entry.Notes = append(entry.Notes, gst.Note{
+			Type:          noteType,
+			Number:        note.NoteNumber,
+			Date:          date,
+			Value:         note.TotalAmount,
+			PlaceOfSupply: note.PlaceOfSupply,
+			ReverseCharge: gst.ReverseChargeNo,
+			InvoiceType:   gst.InvoiceTypeRegular,
+			Items:         items,
+		})
+		addHSNLines(b.hsnB2B, lines, sign)
+	case isB2CL(invoice):
+		b.cdnur = append(b.cdnur, gst.CDNURNote{
+			Type:          gst.CDNURTypeB2CL,
+			NoteType:      noteType,
+			Number:        note.NoteNumber,
+			Date:          date,
+			Value:         note.TotalAmount,
+			PlaceOfSupply: note.PlaceOfSupply,
+			Items:         items,
+		})
+		addHSNLines(b.hsnB2C, lines, sign)
+	default:
+		b.addB2CS(invoice, lines, sign)
+		addHSNLines(b.hsnB2C, lines, sign)
+	}
+}
+
+// reject records the errors of a document and reports whether there were
+// any
+func (b *gstr1Builder) reject(documentType string, id uint, number string, errs validation.Errors) bool {
+	for _, fieldErr := range errs {
+		b.errors = append(b.errors, models.ReturnError{
+			DocumentType:   documentType,
+			DocumentID:     id,
+			DocumentNumber: number,
+			Field:          fieldErr.Field,
+			Message:        fieldErr.Message,
+		})
+	}
+	return len(errs) > 0
+}
+
+// addB2CS adds lines to the state and rate totals of B2CS, negated for
+// credit notes
+func (b *gstr1Builder) addB2CS(invoice *models.Invoice, lines []taxLine, sign int64) {
+	for _, line := range lines {
+		key := b2csKey{placeOfSupply: invoice.PlaceOfSupply, rate: line.rate}
+		entry := b.b2cs[key]
+		if entry == nil {
+			supplyType := gst.SupplyIntra
+			if invoice.SupplyType == constants.SupplyTypeInterState {
+				supplyType = gst.SupplyInter
+			}
+			entry = &gst.B2CS{
+				SupplyType:    supplyType,
+				PlaceOfSupply: invoice.PlaceOfSupply,
+				Type:          gst.B2CSTypeOther,
+				Rate:          line.rate,
+			}
+			b.b2cs[key] = entry
+		}
+		entry.TaxableValue += line.taxableValue * money.Amount(sign)
+		entry.IGST += line.igst * money.Amount(sign)
+		entry.CGST += line.cgst * money.Amount(sign)
+		entry.SGST += line.sgst * money.Amount(sign)
+	}
+}
+
+// addHSNLines adds lines to an HSN summary, negated for credit notes
+func addHSNLines(summary map[hsnKey]*gst.HSNRow, lines []taxLine, sign int64) {
+	for _, line := range lines {
+		key := hsnKey{hsn: line.hsn, rate: line.rate}
+		row := summary[key]
+		if row == nil {
+			row = &gst.HSNRow{
+				HSN:         line.hsn,
+				Description: line.description,
+				UQC:         gst.UQC(line.unit, line.hsn),
+				Rate:        line.rate,
+			}
+			summary[key] = row
+		}
+		if row.UQC != "NA" {
+			row.Quantity += line.quantity * money.Quantity(sign)
+		}
+		row.TaxableValue += line.taxableValue * money.Amount(sign)
+		row.IGST += line.igst * money.Amount(sign)
+		row.CGST += line.cgst * money.Amount(sign)
+		row.SGST += line.sgst * money.Amount(sign)
+	}
+}
+
+// countDocument adds a numbered document to the document summary
+func (b *gstr1Builder) countDocument(documentType int, seriesID *uint, sequence int64, number string, cancelled bool) {
+	key := docKey{documentType: documentType}
+	if seriesID != nil {
+		key.seriesID = *seriesID
+	}
+	r := b.docs[key]
+	if r == nil {
+		r = &docRange{first: sequence, last: sequence, from: number, to: number}
+		b.docs[key] = r
+	}
+	if sequence < r.first {
+		r.first, r.from = sequence, number
+	}
+	if sequence > r.last {
+		r.last, r.to = sequence, number
+	}
+	r.total++
+	if cancelled {
+		r.cancelled++
+	}
+}
+
+// build assembles the return, ordering every section so that the same
+// documents always produce the same file
+func (b *gstr1Builder) build(gstin string, period gst.ReturnPeriod) *gst.GSTR1 {
+	ret := &gst.GSTR1{
+		GSTIN:   gstin,
+		Period:  period.FilingPeriod(),
+		Version: gst.GSTR1Version,
+		Hash:    "hash",
+		CDNUR:   b.cdnur,
+	}
+
+	for _, entry := range b.b2b {
+		ret.B2B = append(ret.B2B, *entry)
+	}
+	sort.Slice(ret.B2B, func(i, j int) bool { return ret.B2B[i].CTIN < ret.B2B[j].CTIN })
+
+	for _, entry := range b.b2cl {
+		ret.B2CL = append(ret.B2CL, *entry)
+	}
+	sort.Slice(ret.B2CL, func(i, j int) bool { return ret.B2CL[i].PlaceOfSupply < ret.B2CL[j].PlaceOfSupply })
+
+	for _, entry := range b.b2cs {
+		ret.B2CS = append(ret.B2CS, *entry)
+	}
+	sort.Slice(ret.B2CS, func(i, j int) bool {
+		if ret.B2CS[i].PlaceOfSupply != ret.B2CS[j].PlaceOfSupply {
+			return ret.B2CS[i].PlaceOfSupply < ret.B2CS[j].PlaceOfSupply
+		}
+		return ret.B2CS[i].Rate < ret.B2CS[j].Rate
+	})
+
+	for _, entry := range b.cdnr {
+		ret.CDNR = append(ret.CDNR, *entry)
+	}
+	sort.Slice(ret.CDNR, func(i, j int) bool { return ret.CDNR[i].CTIN < ret.CDNR[j].CTIN })
+
+	if len(b.hsnB2B) > 0 || len(b.hsnB2C) > 0 {
+		ret.HSN = &gst.HSNSummary{B2B: hsnRows(b.hsnB2B), B2C: hsnRows(b.hsnB2C)}
+	}
+
+	if len(b.docs) > 0 {
+		keys := make([]docKey, 0, len(b.docs))
+		for key := range b.docs {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if keys[i].documentType != keys[j].documentType {
+				return keys[i].documentType < keys[j].documentType
+			}
+			return keys[i].seriesID < keys[j].seriesID
+		})
+
+		ret.DocIssue = &gst.DocIssue{}
+		for _, key := range keys {
+			n := len(ret.DocIssue.Details)
+			if n == 0 || ret.DocIssue.Details[n-1].DocumentType != key.documentType {
+				ret.DocIssue.Details = append(ret.DocIssue.Details, gst.DocDetail{DocumentType: key.documentType})
+				n++
+			}
+			detail := &ret.DocIssue.Details[n-1]
+			r := b.docs[key]
+			detail.Documents = append(detail.Documents, gst.DocRange{
+				Number:    len(detail.Documents) + 1,
+				From:      r.from,
+				To:        r.to,
+				Total:     r.total,
+				Cancelled: r.cancelled,
+				NetIssued: r.total - r.cancelled,
+			})
+		}
+	}
+
+	return ret
+}
+
+// hsnRows orders and numbers the rows of an HSN summary
+func hsnRows(summary map[hsnKey]*gst.HSNRow) []gst.HSNRow {
+	rows := make([]gst.HSNRow, 0, len(summary))
+	for _, row := range summary {
+		rows = append(rows, *row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].HSN != rows[j].HSN {
+			return rows[i].HSN < rows[j].HSN
+		}
+		return rows[i].Rate < rows[j].Rate
+	})
+	for i := range rows {
+		rows[i].Number = i + 1
+	}
+	return rows
+}
+
+// invoiceTaxLine returns an invoice line as reported in a return
+func invoiceTaxLine(line *models.InvoiceLineItem) taxLine {
+	reported := taxLine{
+		hsn:          lineHSNCode(line),
+		description:  line.Description,
+		quantity:     line.Quantity,
+		rate:         line.GSTRate,
+		taxableValue: line.Amount,
+		igst:         line.IGSTAmount,
+		cgst:         line.CGSTAmount,
+		sgst:         line.SGSTAmount,
+	}
+	if line.Item != nil {
+		reported.description = line.Item.Name
+		reported.unit = line.Item.Unit
+	}
+	return reported
+}
+
+// noteTaxLines returns the lines of a note as reported in a return. Lines
+// take their HSN code from the invoice line they refer to, so the note's
+// Invoice must be loaded with its line items and their items.
+func noteTaxLines(note *models.AdjustmentNote) []taxLine {
+	invoiceLines := make(map[uint]*models.InvoiceLineItem)
+	if note.Invoice != nil {
+		for i := range note.Invoice.LineItems {
+			invoiceLines[note.Invoice.LineItems[i].ID] = &note.Invoice.LineItems[i]
+		}
+	}
+
+	lines := make([]taxLine, len(note.LineItems))
+	for i, line := range note.LineItems {
+		lines[i] = taxLine{
+			description:  line.Description,
+			quantity:     line.Quantity,
+			rate:         *line.GSTRate,
+			taxableValue: line.Amount,
+			igst:         line.IGSTAmount,
+			cgst:         line.CGSTAmount,
+			sgst:         line.SGSTAmount,
+		}
+		if line.InvoiceLineItemID != nil {
+			if original, ok := invoiceLines[*line.InvoiceLineItemID]; ok {
+				reported := invoiceTaxLine(original)
+				lines[i].hsn, lines[i].description, lines[i].unit = reported.hsn, reported.description, reported.unit
+			}
+		}
+	}
+	return lines
+}
+
+// rateItems totals document lines by GST rate
+func rateItems(lines []taxLine) []gst.Item {
+	byRate := make(map[int]*gst.ItemDetail)
+	var rates []int
+	for _, line := range lines {
+		detail := byRate[line.rate]
+		if detail == nil {
+			detail = &gst.ItemDetail{Rate: line.rate}
+			byRate[line.rate] = detail
+			rates = append(rates, line.rate)
+		}
+		detail.TaxableValue += line.taxableValue
+		detail.IGST += line.igst
+		detail.CGST += line.cgst
+		detail.SGST += line.sgst
+	}
+	sort.Ints(rates)
+
+	items := make([]gst.Item, len(rates))
+	for i, rate := range rates {
+		items[i] = gst.Item{Number: i + 1, Details: *byRate[rate]}
+	}
+	return items
+}
+
+// isB2CL reports whether an invoice to an unregistered buyer is reported
+// individually in B2CL
+func isB2CL(invoice *models.Invoice) bool {
+	return invoice.SupplyType == constants.SupplyTypeInterState && invoice.TotalAmount > gst.B2CLThreshold
+}
+
+// checkPlaceOfSupply validates the place of supply of a document
+func checkPlaceOfSupply(errs *validation.Errors, placeOfSupply string) {
+	if placeOfSupply == "" {
+		errs.Add("place_of_supply", "is required")
+	} else if !gst.IsValidStateCode(placeOfSupply) {
+		errs.Add("place_of_supply", fmt.Sprintf("%q is not a valid GST state code", placeOfSupply))
+	}
+}
+
+// checkTaxLines validates the GST rate and HSN code of each line, using
+// missingHSN as the message for lines without an HSN code
+func checkTaxLines(errs *validation.Errors, lines []taxLine, missingHSN string) {
+	for i, line := range lines {
+		if !gst.IsValidRate(line.rate) {
+			errs.Add(fmt.Sprintf("line_items[%d].gst_rate", i), fmt.Sprintf("%d%% is not a GST rate", line.rate))
+		}
+		field := fmt.Sprintf("line_items[%d].hsn_code", i)
+		if line.hsn == "" {
+			errs.Add(field, missingHSN)
+		} else if err := validation.ValidateHSN(line.hsn); err != nil {
+			errs.Add(field, err.Error())
+		}
+	}
+}
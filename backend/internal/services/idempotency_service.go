@@ -0,0 +1,104 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"invoice-generator/internal/constants"
+	"invoice-generator/internal/database"
+	"invoice-generator/internal/models"
+)
+
+var (
+	// ErrIdempotencyKeyReused is returned when a key is replayed with a
+	// different request than the one it was first used for
+	ErrIdempotencyKeyReused = errors.New("idempotency key has already been used for a different request")
+
+	// ErrIdempotencyKeyInProgress is returned when a key is replayed while
+	// the original request is still being processed
+	ErrIdempotencyKeyInProgress = errors.New("a request with this idempotency key is still being processed")
+)
+
+// IdempotencyService stores requests made with an Idempotency-Key header
+// and their responses, so that retried requests are not processed twice
+type IdempotencyService struct {
+	ttl time.Duration
+}
+
+// NewIdempotencyService creates a new idempotency service that keeps
+// responses for the given duration
+func NewIdempotencyService(ttl time.Duration) *IdempotencyService {
+	return &IdempotencyService{ttl: ttl}
+}
+
+// Begin claims a key for a request. If the key is new, or was abandoned by
+// a request that never finished, an in-progress record is returned and the
+// caller must Complete or Release it. If the key has already completed for
+// the same request, the completed record is returned for replay.
+func (s *IdempotencyService) Begin(userID uint, key, fingerprint string) (*models.IdempotencyKey, error) {
+	var record *models.IdempotencyKey
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		// Expired keys can be reused
+		if err := tx.Where("user_id = ? AND expires_at < ?", userID, now).
+			Delete(&models.IdempotencyKey{}).Error; err != nil {
+			return err
+		}
+
+		record = &models.IdempotencyKey{
+			UserID:      userID,
+			Key:         key,
+			Fingerprint: fingerprint,
+			Status:      constants.IdempotencyStatusInProgress,
+			ExpiresAt:   now.Add(s.ttl),
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 1 {
+			return nil
+		}
+
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND key = ?", userID, key).First(record).Error; err != nil {
+			return err
+		}
+		if record.Fingerprint != fingerprint {
+			return ErrIdempotencyKeyReused
+		}
+		if record.Status == constants.IdempotencyStatusCompleted {
+			return nil
+		}
+		if record.UpdatedAt.After(now.Add(-constants.IdempotencyLockTimeout)) {
+			return ErrIdempotencyKeyInProgress
+		}
+
+		// The original request never finished; take the key over
+		return tx.Model(record).Update("updated_at", now).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Complete stores the response to a request so that it can be replayed
+func (s *IdempotencyService) Complete(record *models.IdempotencyKey, code int, contentType string, body []byte) error {
+	return database.GetDB().Model(record).Updates(map[string]interface{}{
+		"status":        constants.IdempotencyStatusCompleted,
+		"response_code": code,
+		"content_type":  contentType,
+		"response_body": body,
+	}).Error
+}
+
+// Release frees a key whose request failed without a definitive response,
+// so that the client can retry it
+func (s *IdempotencyService) Release(record *models.IdempotencyKey) error {
+	return database.GetDB().Where("status = ?", constants.IdempotencyStatusInProgress).
+		Delete(record).Error
+}
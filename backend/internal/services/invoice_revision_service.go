@@ -0,0 +1,243 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"gorm.io/gorm"
+	"invoice-generator/internal/constants"
+	"invoice-generator/internal/database"
+	"invoice-generator/internal/models"
+)
+
+// InvoiceRevisionService exposes the revision history of invoices
+type InvoiceRevisionService struct{}
+
+// NewInvoiceRevisionService creates a new invoice revision service
+func NewInvoiceRevisionService() *InvoiceRevisionService {
+	return &InvoiceRevisionService{}
+}
+
+// GetRevisions returns all revisions of an invoice, oldest first
+func (s *InvoiceRevisionService) GetRevisions(invoiceID uint, userID uint, isAdmin bool) ([]models.InvoiceRevision, error) {
+	if err := s.checkAccess(invoiceID, userID, isAdmin); err != nil {
+		return nil, err
+	}
+
+	var revisions []models.InvoiceRevision
+	if err := database.GetDB().Preload("EditedBy", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id, name, email")
+	}).Where("invoice_id = ?", invoiceID).Order("revision_number").Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// DiffRevisions returns the fields that differ between two revisions of an
+// invoice
+func (s *InvoiceRevisionService) DiffRevisions(invoiceID uint, from, to int, userID uint, isAdmin bool) ([]models.FieldChange, error) {
+	if err := s.checkAccess(invoiceID, userID, isAdmin); err != nil {
+		return nil, err
+	}
+
+	var revisions []models.InvoiceRevision
+	if err := database.GetDB().Where("invoice_id = ? AND revision_number IN ?", invoiceID, []int{from, to}).
+		Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+
+	byNumber := make(map[int]models.InvoiceRevision, len(revisions))
+	for _, revision := range revisions {
+		byNumber[revision.RevisionNumber] = revision
+	}
+	fromRevision, ok := byNumber[from]
+	if !ok {
+		return nil, fmt.Errorf("revision %d not found", from)
+	}
+	toRevision, ok := byNumber[to]
+	if !ok {
+		return nil, fmt.Errorf("revision %d not found", to)
+	}
+
+	return diffSnapshots(fromRevision.Snapshot, toRevision.Snapshot)
+}
+
+// checkAccess applies the same visibility rules as InvoiceService.GetInvoice
+func (s *InvoiceRevisionService) checkAccess(invoiceID uint, userID uint, isAdmin bool) error {
+	query := database.GetDB().Model(&models.Invoice{}).Where("id = ?", invoiceID)
+	if !isAdmin {
+		query = query.Where("generated_by_id = ? OR (generated_for_id = ? AND status <> ?)",
+			userID, userID, constants.InvoiceStatusDraft)
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return errors.New("invoice not found")
+	}
+	return nil
+}
+
+// recordInvoiceRevision stores the current state of an invoice as a new
+// revision. It must run in the transaction that made the change, after the
+// change has been written.
+func recordInvoiceRevision(tx *gorm.DB, invoiceID uint, userID uint, action string) error {
+	var invoice models.Invoice
+	if err := tx.Preload("LineItems", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).First(&invoice, invoiceID).Error; err != nil {
+		return err
+	}
+	snapshot := snapshotInvoice(&invoice)
+
+	var previous models.InvoiceRevision
+	number := 1
+	var changes []models.FieldChange
+	err := tx.Where("invoice_id = ?", invoiceID).Order("revision_number DESC").First(&previous).Error
+	switch {
+	case err == nil:
+		number = previous.RevisionNumber + 1
+		if changes, err = diffSnapshots(previous.Snapshot, snapshot); err != nil {
+			return err
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		changes = []models.FieldChange{}
+	default:
+		return err
+	}
+
+	revision := models.InvoiceRevision{
+		InvoiceID:      invoiceID,
+		RevisionNumber: number,
+		Action:         action,
+		EditedByID:     userID,
+		Snapshot:       snapshot,
+		Changes:        changes,
+	}
+	if err := tx.Create(&revision).Error; err != nil {
+		return fmt.Errorf("failed to record invoice revision: %w", err)
+	}
+	return nil
+}
+
+// snapshotInvoice captures the revision-tracked fields of an invoice
+func snapshotInvoice(invoice *models.Invoice) models.InvoiceSnapshot {
+	snapshot := models.InvoiceSnapshot{
+		InvoiceNumber:  invoice.InvoiceNumber,
+		Status:         invoice.Status,
+		GeneratedForID: invoice.GeneratedForID,
+		PartyID:        invoice.PartyID,
+		ShipTo:         invoice.ShipTo,
+		Transport:      invoice.Transport,
+		InvoiceType:    invoice.InvoiceType,
+		InvoiceDate:    invoice.InvoiceDate.UTC(),
+		DueDate:        invoice.DueDate.UTC(),
+		PlaceOfSupply:  invoice.PlaceOfSupply,
+		SupplyType:     invoice.SupplyType,
+		Notes:          invoice.Notes,
+		Terms:          invoice.Terms,
+		Discount:       invoice.Discount,
+		TotalDiscount:  invoice.TotalDiscount,
+		SubTotal:       invoice.SubTotal,
+		TotalCGST:      invoice.TotalCGST,
+		TotalSGST:      invoice.TotalSGST,
+		TotalIGST:      invoice.TotalIGST,
+		TotalGST:       invoice.TotalGST,
+		TotalAmount:    invoice.TotalAmount,
+		LineItems:      make([]models.InvoiceSnapshotLine, 0, len(invoice.LineItems)),
+	}
+	for _, line := range invoice.LineItems {
+		snapshot.LineItems = append(snapshot.LineItems, models.InvoiceSnapshotLine{
+			ItemID:      line.ItemID,
+			Description: line.Description,
+			HSNCode:     line.HSNCode,
+			Quantity:    line.Quantity,
+			Rate:        line.Rate,
+			Discount:    line.Discount,
+			Amount:      line.Amount,
+			GSTRate:     line.GSTRate,
+			GSTAmount:   line.GSTAmount,
+			TotalAmount: line.TotalAmount,
+		})
+	}
+	return snapshot
+}
+
+// diffSnapshots compares two snapshots field by field. Both are flattened
+// through their JSON form so that line items are compared by position and
+// each changed attribute is reported on its own.
+func diffSnapshots(from, to models.InvoiceSnapshot) ([]models.FieldChange, error) {
+	oldFields, err := flattenJSON(from)
+	if err != nil {
+		return nil, err
+	}
+	newFields, err := flattenJSON(to)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]bool, len(oldFields)+len(newFields))
+	for key := range oldFields {
+		keys[key] = true
+	}
+	for key := range newFields {
+		keys[key] = true
+	}
+
+	changes := []models.FieldChange{}
+	for key := range keys {
+		oldValue, newValue := oldFields[key], newFields[key]
+		if !reflect.DeepEqual(oldValue, newValue) {
+			changes = append(changes, models.FieldChange{Field: key, Old: oldValue, New: newValue})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+
+	return changes, nil
+}
+
+// flattenJSON converts a value into a map of dotted JSON paths to leaf values
+func flattenJSON(v interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var decoded interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]interface{})
+	var walk func(prefix string, value interface{})
+	walk = func(prefix string, value interface{}) {
+		switch typed := value.(type) {
+		case map[string]interface{}:
+			for key, child := range typed {
+				path := key
+				if prefix != "" {
+					path = prefix + "." + key
+				}
+				walk(path, child)
+			}
+		case []interface{}:
+			for i, child := range typed {
+				walk(fmt.Sprintf("%s[%d]", prefix, i), child)
+			}
+		default:
+			fields[prefix] = value
+		}
+	}
+	walk("", decoded)
+
+	return fields, nil
+}
@@ -3,12 +3,17 @@ package services
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"invoice-generator/internal/constants"
 	"invoice-generator/internal/database"
+	"invoice-generator/internal/gst"
 	"invoice-generator/internal/models"
+	"invoice-generator/internal/money"
+	"invoice-generator/internal/validation"
 )
 
 // InvoiceService handles invoice-related business logic
@@ -19,19 +24,65 @@ func NewInvoiceService() *InvoiceService {
 	return &InvoiceService{}
 }
 
-// CreateInvoice creates a new invoice
+// CreateInvoice creates a new draft invoice. Drafts have no invoice number
+// until they are issued; setting Issue on the request issues the invoice
+// straight away in the same transaction.
 func (s *InvoiceService) CreateInvoice(invoice *models.Invoice, userID uint) error {
-	invoice.GeneratedByID = userID
+	// Invoices are linked to quotations only by converting the quotation
+	invoice.QuotationID = nil
+	for i := range invoice.LineItems {
+		invoice.LineItems[i].QuotationLineItemID = nil
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := s.createInvoice(tx, invoice, userID); err != nil {
+			return err
+		}
 
-	// Generate invoice number
-	invoice.InvoiceNumber = s.generateInvoiceNumber()
+		// Load relationships
+		return tx.Preload("GeneratedBy").Preload("GeneratedFor").Preload("Party").
+			Preload("LineItems.Item.Category").Preload("Payments").
+			First(invoice, invoice.ID).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create invoice: %w", err)
+	}
+	return nil
+}
+
+// createInvoice stores a new invoice within the caller's transaction, so
+// that callers such as the recurring invoice scheduler can create the
+// invoice atomically with their own records
+func (s *InvoiceService) createInvoice(tx *gorm.DB, invoice *models.Invoice, userID uint) error {
+	invoice.ID = 0
+	invoice.GeneratedByID = userID
+	invoice.Status = constants.InvoiceStatusDraft
+	invoice.InvoiceNumber = ""
+	invoice.Seller = models.PartySnapshot{}
+	invoice.Buyer = models.PartySnapshot{}
 
 	// Set default dates if not provided
 	if invoice.InvoiceDate.IsZero() {
 		invoice.InvoiceDate = time.Now()
 	}
+
+	if err := validateInvoiceDiscounts(invoice); err != nil {
+		return err
+	}
+	if err := validateTransport(invoice); err != nil {
+		return err
+	}
+	if err := resolveLineHSNCodes(tx, invoice.LineItems); err != nil {
+		return err
+	}
+
+	// Determine place of supply and whether CGST+SGST or IGST applies
+	buyer, err := s.determineSupplyType(tx, invoice)
+	if err != nil {
+		return err
+	}
 	if invoice.DueDate.IsZero() {
-		invoice.DueDate = invoice.InvoiceDate.AddDate(0, 0, constants.DefaultDueDays)
+		invoice.DueDate = invoice.InvoiceDate.AddDate(0, 0, paymentTermsDays(buyer))
 	}
 
 	// Calculate totals
@@ -40,27 +91,432 @@ func (s *InvoiceService) CreateInvoice(invoice *models.Invoice, userID uint) err
 	// Set amount due
 	invoice.AmountDue = invoice.TotalAmount
 
-	if err := database.GetDB().Create(invoice).Error; err != nil {
-		return fmt.Errorf("failed to create invoice: %w", err)
+	if err := tx.Omit("GeneratedBy", "GeneratedFor", "Party").Create(invoice).Error; err != nil {
+		return err
+	}
+	if err := recordInvoiceRevision(tx, invoice.ID, userID, constants.RevisionActionCreated); err != nil {
+		return err
+	}
+	if invoice.Issue {
+		if err := s.issue(tx, invoice); err != nil {
+			return err
+		}
+		return recordInvoiceRevision(tx, invoice.ID, userID, constants.RevisionActionIssued)
 	}
+	return nil
+}
 
-	// Load relationships
-	database.GetDB().Preload("GeneratedBy").Preload("GeneratedFor").
-		Preload("LineItems.Item.Category").Preload("Payments").
-		First(invoice, invoice.ID)
+// UpdateInvoice replaces the contents of an invoice. Drafts can be edited
+// freely. An issued invoice can be amended, keeping its number, as long as
+// no payments or credit/debit notes have been recorded against it and the
+// amended total keeps its party within its credit limit. Every edit is
+// kept as a revision.
+func (s *InvoiceService) UpdateInvoice(id uint, updateData *models.Invoice, userID uint, isAdmin bool) (*models.Invoice, error) {
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		invoice, err := s.lockInvoice(tx, id, userID, isAdmin)
+		if err != nil {
+			return err
+		}
+
+		amending := invoice.Status == constants.InvoiceStatusIssued
+		switch {
+		case invoice.Status == constants.InvoiceStatusDraft:
+		case amending:
+			var paymentCount, noteCount int64
+			activePayments(tx, id).Count(&paymentCount)
+			tx.Model(&models.AdjustmentNote{}).Where("invoice_id = ?", id).Count(&noteCount)
+			if paymentCount > 0 {
+				return errors.New("cannot edit an invoice with payments")
+			}
+			if noteCount > 0 {
+				return errors.New("cannot edit an invoice with credit or debit notes; issue a note instead")
+			}
+			if invoice.IRN != "" {
+				return errors.New("cannot edit an invoice registered with the IRP; issue a credit or debit note instead")
+			}
+		default:
+			return errors.New("only draft or issued invoices can be edited")
+		}
+
+		invoice.GeneratedForID = updateData.GeneratedForID
+		invoice.PartyID = updateData.PartyID
+		invoice.ShipTo = updateData.ShipTo
+		invoice.Transport = updateData.Transport
+		invoice.InvoiceType = updateData.InvoiceType
+		invoice.PlaceOfSupply = updateData.PlaceOfSupply
+		invoice.Notes = updateData.Notes
+		invoice.Terms = updateData.Terms
+		invoice.Discount = updateData.Discount
+		if !amending {
+			// The series of an issued invoice is fixed by its number
+			invoice.Series = updateData.Series
+		}
+		if !updateData.InvoiceDate.IsZero() {
+			invoice.InvoiceDate = updateData.InvoiceDate
+		}
+		if amending && financialYear(invoice.InvoiceDate) != invoice.FinancialYear {
+			return errors.New("invoice date cannot be moved out of the financial year of the invoice number")
+		}
+		invoice.DueDate = updateData.DueDate
+
+		invoice.LineItems = updateData.LineItems
+		for i := range invoice.LineItems {
+			invoice.LineItems[i].ID = 0
+			invoice.LineItems[i].InvoiceID = invoice.ID
+		}
+		if err := s.checkQuotationLinks(tx, invoice); err != nil {
+			return err
+		}
+		if err := validateInvoiceDiscounts(invoice); err != nil {
+			return err
+		}
+		if err := validateTransport(invoice); err != nil {
+			return err
+		}
+		if err := resolveLineHSNCodes(tx, invoice.LineItems); err != nil {
+			return err
+		}
+
+		buyer, err := s.determineSupplyType(tx, invoice)
+		if err != nil {
+			return err
+		}
+		if invoice.DueDate.IsZero() {
+			invoice.DueDate = invoice.InvoiceDate.AddDate(0, 0, paymentTermsDays(buyer))
+		}
+		s.calculateInvoiceTotals(invoice)
+		invoice.AmountDue = invoice.TotalAmount
+		if amending {
+			if err := checkCreditLimit(tx, invoice); err != nil {
+				return err
+			}
+			// An amended invoice shows the parties as at the amendment
+			if err := snapshotInvoiceParties(tx, invoice); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("invoice_id = ?", invoice.ID).Delete(&models.InvoiceLineItem{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Omit("GeneratedBy", "GeneratedFor", "Party").Save(invoice).Error; err != nil {
+			return err
+		}
+		if err := recordInvoiceRevision(tx, invoice.ID, userID, constants.RevisionActionUpdated); err != nil {
+			return err
+		}
+		if invoice.QuotationID != nil {
+			if err := syncQuotationProgress(tx, *invoice.QuotationID); err != nil {
+				return err
+			}
+		}
+
+		if updateData.Issue && !amending {
+			if err := s.issue(tx, invoice); err != nil {
+				return err
+			}
+			return recordInvoiceRevision(tx, invoice.ID, userID, constants.RevisionActionIssued)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
+	return s.GetInvoice(id, userID, isAdmin)
+}
+
+// IssueInvoice finalises a draft invoice and allocates its invoice number
+func (s *InvoiceService) IssueInvoice(id uint, userID uint, isAdmin bool) (*models.Invoice, error) {
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		invoice, err := s.lockInvoice(tx, id, userID, isAdmin)
+		if err != nil {
+			return err
+		}
+		if invoice.Status != constants.InvoiceStatusDraft {
+			return errors.New("only draft invoices can be issued")
+		}
+		if err := tx.Where("invoice_id = ?", invoice.ID).Find(&invoice.LineItems).Error; err != nil {
+			return err
+		}
+
+		// Party details may have changed since the draft was saved
+		if _, err := s.determineSupplyType(tx, invoice); err != nil {
+			return err
+		}
+		s.calculateInvoiceTotals(invoice)
+		invoice.AmountDue = invoice.TotalAmount
+		if err := tx.Omit("GeneratedBy", "GeneratedFor", "Party").Save(invoice).Error; err != nil {
+			return err
+		}
+
+		if err := s.issue(tx, invoice); err != nil {
+			return err
+		}
+		return recordInvoiceRevision(tx, invoice.ID, userID, constants.RevisionActionIssued)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetInvoice(id, userID, isAdmin)
+}
+
+// UpdateTransport replaces the transport details of a draft or issued
+// invoice. Vehicles and transporters are often only known once the goods
+// are dispatched, so unlike other fields they can be changed after
+// payments, notes or an IRN have been recorded.
+func (s *InvoiceService) UpdateTransport(id uint, transport *models.TransportDetails, userID uint, isAdmin bool) (*models.Invoice, error) {
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		invoice, err := s.lockInvoice(tx, id, userID, isAdmin)
+		if err != nil {
+			return err
+		}
+		if invoice.Status != constants.InvoiceStatusDraft && invoice.Status != constants.InvoiceStatusIssued {
+			return errors.New("transport details can only be changed on draft or issued invoices")
+		}
+
+		invoice.Transport = *transport
+		if err := validateTransport(invoice); err != nil {
+			return err
+		}
+		if err := tx.Model(invoice).Updates(map[string]interface{}{
+			"transport_transporter_id":   invoice.Transport.TransporterID,
+			"transport_transporter_name": invoice.Transport.TransporterName,
+			"transport_mode":             invoice.Transport.Mode,
+			"transport_vehicle_number":   invoice.Transport.VehicleNumber,
+			"transport_vehicle_type":     invoice.Transport.VehicleType,
+			"transport_document_number":  invoice.Transport.DocumentNumber,
+			"transport_document_date":    invoice.Transport.DocumentDate,
+			"transport_distance_km":      invoice.Transport.DistanceKm,
+		}).Error; err != nil {
+			return err
+		}
+		return recordInvoiceRevision(tx, invoice.ID, userID, constants.RevisionActionTransport)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetInvoice(id, userID, isAdmin)
+}
+
+// VoidInvoice discards a draft invoice. The record is kept with the reason
+// and time it was voided.
+func (s *InvoiceService) VoidInvoice(id uint, reason string, userID uint, isAdmin bool) (*models.Invoice, error) {
+	return s.closeInvoice(id, constants.InvoiceStatusDraft, constants.InvoiceStatusVoid, constants.RevisionActionVoided, reason, userID, isAdmin)
+}
+
+// CancelInvoice cancels an issued invoice. The invoice and its number are
+// retained for audit with the cancellation reason and time, and it no
+// longer counts towards receivables.
+func (s *InvoiceService) CancelInvoice(id uint, reason string, userID uint, isAdmin bool) (*models.Invoice, error) {
+	return s.closeInvoice(id, constants.InvoiceStatusIssued, constants.InvoiceStatusCancelled, constants.RevisionActionCancelled, reason, userID, isAdmin)
+}
+
+// closeInvoice moves an invoice from one status to a terminal status and
+// records the change as a revision with the given action
+func (s *InvoiceService) closeInvoice(id uint, from, to, action, reason string, userID uint, isAdmin bool) (*models.Invoice, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, errors.New("reason is required")
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		invoice, err := s.lockInvoice(tx, id, userID, isAdmin)
+		if err != nil {
+			return err
+		}
+		if invoice.Status != from {
+			return fmt.Errorf("only %s invoices can be set to %s", strings.ToLower(from), strings.ToLower(to))
+		}
+
+		var paymentCount, noteCount int64
+		activePayments(tx, id).Count(&paymentCount)
+		tx.Model(&models.AdjustmentNote{}).Where("invoice_id = ?", id).Count(&noteCount)
+		if paymentCount > 0 {
+			return errors.New("cannot cancel an invoice with payments")
+		}
+		if noteCount > 0 {
+			return errors.New("cannot cancel an invoice with credit or debit notes")
+		}
+		if invoice.IRN != "" && invoice.IRNCancelledAt == nil {
+			return errors.New("cannot cancel an invoice registered with the IRP; cancel its IRN first, or issue a credit note")
+		}
+
+		now := time.Now()
+		if err := tx.Model(invoice).Updates(map[string]interface{}{
+			"status":              to,
+			"cancellation_reason": reason,
+			"cancelled_at":        now,
+			"cancelled_by_id":     userID,
+		}).Error; err != nil {
+			return err
+		}
+		if err := recordInvoiceRevision(tx, invoice.ID, userID, action); err != nil {
+			return err
+		}
+
+		// Release the quoted quantities so they can be invoiced again
+		if invoice.QuotationID != nil {
+			return syncQuotationProgress(tx, *invoice.QuotationID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetInvoice(id, userID, isAdmin)
+}
+
+// checkQuotationLinks makes sure the lines of an invoice only refer to
+// lines of the quotation the invoice was converted from
+func (s *InvoiceService) checkQuotationLinks(tx *gorm.DB, invoice *models.Invoice) error {
+	if invoice.QuotationID == nil {
+		for i := range invoice.LineItems {
+			invoice.LineItems[i].QuotationLineItemID = nil
+		}
+		return nil
+	}
+
+	var lineIDs []uint
+	if err := tx.Model(&models.QuotationLineItem{}).Where("quotation_id = ?", *invoice.QuotationID).
+		Pluck("id", &lineIDs).Error; err != nil {
+		return err
+	}
+	allowed := make(map[uint]bool, len(lineIDs))
+	for _, id := range lineIDs {
+		allowed[id] = true
+	}
+
+	for i, line := range invoice.LineItems {
+		if line.QuotationLineItemID != nil && !allowed[*line.QuotationLineItemID] {
+			return fmt.Errorf("line %d: quotation line item not found on the linked quotation", i+1)
+		}
+	}
 	return nil
 }
 
+// lockInvoice loads an invoice for update, restricted to the seller unless
+// the user is an admin
+func (s *InvoiceService) lockInvoice(tx *gorm.DB, id uint, userID uint, isAdmin bool) (*models.Invoice, error) {
+	var invoice models.Invoice
+	query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id)
+	if !isAdmin {
+		query = query.Where("generated_by_id = ?", userID)
+	}
+	if err := query.First(&invoice).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("invoice not found")
+		}
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// issue allocates the invoice number and marks a draft as issued. The
+// number is allocated in the caller's transaction so that it is released
+// again if the transaction fails. Issuing to a party is refused if it
+// would take the party over its credit limit. The seller's and buyer's
+// current details are copied onto the invoice so that it keeps showing
+// them as issued.
+func (s *InvoiceService) issue(tx *gorm.DB, invoice *models.Invoice) error {
+	if err := checkCreditLimit(tx, invoice); err != nil {
+		return err
+	}
+	if err := snapshotInvoiceParties(tx, invoice); err != nil {
+		return err
+	}
+
+	number, err := allocateDocumentNumber(tx, invoice.GeneratedByID, constants.DocumentTypeInvoice, invoice.Series, invoice.InvoiceDate)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	invoice.InvoiceNumber = number.Number
+	invoice.SeriesID = &number.SeriesID
+	invoice.SequenceNumber = number.Sequence
+	invoice.FinancialYear = number.FinancialYear
+	invoice.Status = constants.InvoiceStatusIssued
+	invoice.IssuedAt = &now
+
+	updates := map[string]interface{}{
+		"invoice_number":  invoice.InvoiceNumber,
+		"series_id":       invoice.SeriesID,
+		"sequence_number": invoice.SequenceNumber,
+		"financial_year":  invoice.FinancialYear,
+		"status":          invoice.Status,
+		"issued_at":       invoice.IssuedAt,
+	}
+	for column, value := range snapshotColumns("seller_", invoice.Seller) {
+		updates[column] = value
+	}
+	for column, value := range snapshotColumns("buyer_", invoice.Buyer) {
+		updates[column] = value
+	}
+	return tx.Model(invoice).Updates(updates).Error
+}
+
+// snapshotInvoiceParties copies the current details of the seller and the
+// buyer onto an invoice
+func snapshotInvoiceParties(tx *gorm.DB, invoice *models.Invoice) error {
+	var seller models.User
+	if err := tx.First(&seller, invoice.GeneratedByID).Error; err != nil {
+		return errors.New("seller not found")
+	}
+	buyer, err := resolveBuyer(tx, invoice.GeneratedByID, invoice.GeneratedForID, invoice.PartyID)
+	if err != nil {
+		return err
+	}
+
+	invoice.Seller = snapshotParty(userAsParty(&seller))
+	invoice.Buyer = snapshotParty(buyer)
+
+	// Details saved before validation existed are checked again, since a
+	// wrong GSTIN on an issued invoice cannot be corrected later
+	var errs validation.Errors
+	invoice.Seller.GSTIN = validation.Normalise(invoice.Seller.GSTIN)
+	invoice.Buyer.GSTIN = validation.Normalise(invoice.Buyer.GSTIN)
+	validation.CheckRegistration(&errs, "seller.gstin", invoice.Seller.GSTIN, "", "",
+		"seller.state", invoice.Seller.State)
+	validation.CheckRegistration(&errs, "buyer.gstin", invoice.Buyer.GSTIN, "", "",
+		"buyer.state", invoice.Buyer.State)
+	return errs.Err()
+}
+
+// snapshotColumns returns the column values of a party snapshot stored
+// under a column prefix, for use in partial updates
+func snapshotColumns(prefix string, snapshot models.PartySnapshot) map[string]interface{} {
+	return map[string]interface{}{
+		prefix + "name":         snapshot.Name,
+		prefix + "company_name": snapshot.CompanyName,
+		prefix + "gstin":        snapshot.GSTIN,
+		prefix + "email":        snapshot.Email,
+		prefix + "phone":        snapshot.Phone,
+		prefix + "address":      snapshot.Address.Address,
+		prefix + "city":         snapshot.City,
+		prefix + "state":        snapshot.State,
+		prefix + "pincode":      snapshot.Pincode,
+		prefix + "state_code":   snapshot.StateCode,
+	}
+}
+
 // GetInvoices returns invoices with pagination and access control
-func (s *InvoiceService) GetInvoices(userID uint, isAdmin bool, page, limit int) ([]models.Invoice, int64, error) {
+func (s *InvoiceService) GetInvoices(userID uint, isAdmin bool, status string, page, limit int) ([]models.Invoice, int64, error) {
 	var invoices []models.Invoice
-	query := database.GetDB().Preload("GeneratedBy").Preload("GeneratedFor").
+	query := database.GetDB().Preload("GeneratedBy").Preload("GeneratedFor").Preload("Party").
 		Preload("LineItems.Item.Category").Preload("Payments")
 
 	if !isAdmin {
-		// Regular users can only see invoices they generated or received
-		query = query.Where("generated_by_id = ? OR generated_for_id = ?", userID, userID)
+		// Regular users can see invoices they generated, and invoices they
+		// received once issued
+		query = query.Where("generated_by_id = ? OR (generated_for_id = ? AND status <> ?)",
+			userID, userID, constants.InvoiceStatusDraft)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
 	}
 
 	// Count total
@@ -79,11 +535,13 @@ func (s *InvoiceService) GetInvoices(userID uint, isAdmin bool, page, limit int)
 // GetInvoice returns a single invoice by ID with access control
 func (s *InvoiceService) GetInvoice(id uint, userID uint, isAdmin bool) (*models.Invoice, error) {
 	var invoice models.Invoice
-	query := database.GetDB().Preload("GeneratedBy").Preload("GeneratedFor").
-		Preload("LineItems.Item.Category").Preload("Payments")
+	query := database.GetDB().Preload("GeneratedBy").Preload("GeneratedFor").Preload("Party").
+		Preload("LineItems.Item.Category").Preload("Payments").
+		Preload("AdjustmentNotes.LineItems")
 
 	if !isAdmin {
-		query = query.Where("generated_by_id = ? OR generated_for_id = ?", userID, userID)
+		query = query.Where("generated_by_id = ? OR (generated_for_id = ? AND status <> ?)",
+			userID, userID, constants.InvoiceStatusDraft)
 	}
 
 	if err := query.First(&invoice, id).Error; err != nil {
@@ -96,109 +554,507 @@ func (s *InvoiceService) GetInvoice(id uint, userID uint, isAdmin bool) (*models
 	return &invoice, nil
 }
 
-// AddPayment adds a payment to an invoice
+// AddPayment adds a payment to an invoice. The invoice row is locked for
+// the duration of the transaction so that concurrent payments are checked
+// against the up-to-date amount due. On an invoice billed to a party, any
+// amount above the amount due is added to the party's credit balance;
+// other invoices cannot be overpaid. Cheques start out received and count
+// as paid until they bounce; other payments are cleared straight away.
 func (s *InvoiceService) AddPayment(invoiceID uint, payment *models.Payment, userID uint, isAdmin bool) error {
-	payment.InvoiceID = invoiceID
-
-	// Validate invoice exists and user has access
-	var invoice models.Invoice
-	query := database.GetDB().Where("id = ?", invoiceID)
-	if !isAdmin {
-		query = query.Where("generated_by_id = ? OR generated_for_id = ?", userID, userID)
+	*payment = models.Payment{
+		InvoiceID:     invoiceID,
+		PaymentType:   constants.PaymentTypeReceipt,
+		Amount:        payment.Amount,
+		PaymentMethod: payment.PaymentMethod,
+		PaymentDate:   payment.PaymentDate,
+		Reference:     strings.TrimSpace(payment.Reference),
+		Notes:         payment.Notes,
+		Status:        constants.PaymentCleared,
 	}
-
-	if err := query.First(&invoice).Error; err != nil {
-		return errors.New("invoice not found")
+	if payment.PaymentMethod == constants.PaymentMethodCheque {
+		payment.Status = constants.PaymentReceived
 	}
 
 	// Validate payment amount
 	if payment.Amount <= 0 {
 		return errors.New("payment amount must be positive")
 	}
+	if payment.PaymentMethod == constants.PaymentMethodCredit {
+		return errors.New("apply the party's credit to pay from its credit balance")
+	}
 
+	return database.GetDB().Transaction(func(tx *gorm.DB) error {
+		// Validate invoice exists and user has access
+		var invoice models.Invoice
+		query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", invoiceID)
+		if !isAdmin {
+			query = query.Where("generated_by_id = ? OR generated_for_id = ?", userID, userID)
+		}
+		if err := query.First(&invoice).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("invoice not found")
+			}
+			return err
+		}
+
+		return recordPayment(tx, &invoice, payment, userID)
+	})
+}
+
+// recordPayment stores a payment on an invoice locked by the caller,
+// adding any amount above the amount due to the party's credit balance,
+// and updates the invoice's payment status. Credit balances are kept for
+// parties only, so invoices billed to registered users cannot be overpaid.
+func recordPayment(tx *gorm.DB, invoice *models.Invoice, payment *models.Payment, userID uint) error {
+	if invoice.Status != constants.InvoiceStatusIssued {
+		return errors.New("payments can only be recorded against issued invoices")
+	}
 	if payment.Amount > invoice.AmountDue {
-		return errors.New("payment amount cannot exceed amount due")
+		if invoice.PartyID == nil {
+			return errors.New("payment amount cannot exceed amount due; only invoices billed to a party can be overpaid")
+		}
+		if invoice.AmountDue <= 0 {
+			return errors.New("invoice has nothing due; record the payment as an advance instead")
+		}
+		payment.CreditAmount = payment.Amount - invoice.AmountDue
+		payment.Amount = invoice.AmountDue
 	}
 
-	if err := database.GetDB().Create(payment).Error; err != nil {
-		return errors.New("failed to add payment")
+	payment.InvoiceID = invoice.ID
+	if err := tx.Create(payment).Error; err != nil {
+		return fmt.Errorf("failed to add payment: %w", err)
+	}
+	if err := recordPaymentEvent(tx, payment, constants.PaymentActionRecorded, "", "", userID); err != nil {
+		return err
+	}
+	if payment.CreditAmount > 0 {
+		if err := creditOverpayment(tx, invoice, payment, userID); err != nil {
+			return err
+		}
 	}
 
 	// Update invoice payment status
-	s.updateInvoicePaymentStatus(invoiceID)
-
+	if err := updateInvoicePaymentStatus(tx, invoice.ID); err != nil {
+		return fmt.Errorf("failed to update payment status: %w", err)
+	}
 	return nil
 }
 
-// DeleteInvoice deletes an invoice (admin only)
+// DeleteInvoice deletes a draft invoice (admin only). Issued invoices are
+// retained and must be cancelled instead. The invoice is locked so that it
+// cannot be issued while it is being deleted.
 func (s *InvoiceService) DeleteInvoice(id uint) error {
-	// Check if invoice exists and has no payments
-	var invoice models.Invoice
-	if err := database.GetDB().Preload("Payments").First(&invoice, id).Error; err != nil {
-		return errors.New("invoice not found")
+	return database.GetDB().Transaction(func(tx *gorm.DB) error {
+		invoice, err := s.lockInvoice(tx, id, 0, true)
+		if err != nil {
+			return err
+		}
+
+		if invoice.Status != constants.InvoiceStatusDraft {
+			return errors.New("only draft invoices can be deleted; cancel issued invoices instead")
+		}
+
+		var noteCount int64
+		if err := tx.Model(&models.AdjustmentNote{}).Where("invoice_id = ?", id).Count(&noteCount).Error; err != nil {
+			return err
+		}
+		if noteCount > 0 {
+			return errors.New("cannot delete invoice with credit or debit notes")
+		}
+
+		// Delete line items and revisions first
+		if err := tx.Where("invoice_id = ?", id).Delete(&models.InvoiceLineItem{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("invoice_id = ?", id).Delete(&models.InvoiceRevision{}).Error; err != nil {
+			return err
+		}
+
+		// Delete invoice
+		if err := tx.Delete(invoice).Error; err != nil {
+			return errors.New("failed to delete invoice")
+		}
+
+		if invoice.QuotationID != nil {
+			return syncQuotationProgress(tx, *invoice.QuotationID)
+		}
+
+		return nil
+	})
+}
+
+// determineSupplyType resolves the buyer, ship-to address and place of
+// supply of an invoice and whether it is an intra-state or inter-state
+// supply. Without a ship-to address, goods ship to the party's shipping
+// address if it has one. It returns the buyer for callers that need its
+// payment terms.
+func (s *InvoiceService) determineSupplyType(tx *gorm.DB, invoice *models.Invoice) (*models.Party, error) {
+	buyer, err := resolveBuyer(tx, invoice.GeneratedByID, invoice.GeneratedForID, invoice.PartyID)
+	if err != nil {
+		return nil, err
 	}
 
-	if len(invoice.Payments) > 0 {
-		return errors.New("cannot delete invoice with payments")
+	if invoice.ShipTo.Address == (models.Address{}) && buyer.ShippingAddress != (models.Address{}) {
+		invoice.ShipTo = models.PartySnapshot{
+			Name:        buyer.Name,
+			CompanyName: buyer.CompanyName,
+			Address:     buyer.ShippingAddress,
+		}
+	}
+	invoice.ShipTo.GSTIN = strings.ToUpper(strings.TrimSpace(invoice.ShipTo.GSTIN))
+	invoice.ShipTo.StateCode = gst.ResolveStateCode(invoice.ShipTo.GSTIN, invoice.ShipTo.State)
+	if invoice.ShipTo.State != "" && invoice.ShipTo.StateCode == "" {
+		return nil, errors.New("invalid ship-to state")
 	}
 
-	// Delete line items first
-	database.GetDB().Where("invoice_id = ?", id).Delete(&models.InvoiceLineItem{})
+	placeOfSupply, supplyType, err := resolvePlaceOfSupply(tx, invoice.GeneratedByID, buyer, invoice.ShipTo.StateCode, invoice.PlaceOfSupply)
+	if err != nil {
+		return nil, err
+	}
+	invoice.PlaceOfSupply = placeOfSupply
+	invoice.SupplyType = supplyType
+	return buyer, nil
+}
 
-	// Delete invoice
-	if err := database.GetDB().Delete(&invoice).Error; err != nil {
-		return errors.New("failed to delete invoice")
+// resolvePlaceOfSupply returns the GST state code of the place of supply
+// and the supply type for a document between a seller and a buyer. The
+// place of supply is taken from the document when set explicitly, then
+// from the state goods are shipped to, then from the buyer's GSTIN or
+// state, falling back to the seller's state for unregistered buyers
+// without an address.
+func resolvePlaceOfSupply(tx *gorm.DB, sellerID uint, buyer *models.Party, shipToState, placeOfSupply string) (string, string, error) {
+	var seller models.User
+	if err := tx.First(&seller, sellerID).Error; err != nil {
+		return "", "", errors.New("seller not found")
 	}
 
-	return nil
-}
+	sellerState := gst.ResolveStateCode(seller.GSTIN, seller.State)
+	if sellerState == "" {
+		return "", "", errors.New("seller GSTIN or state is required to determine place of supply")
+	}
+
+	if placeOfSupply != "" {
+		code := gst.StateCodeByName(placeOfSupply)
+		if code == "" {
+			return "", "", errors.New("invalid place of supply")
+		}
+		placeOfSupply = code
+	} else if shipToState != "" {
+		placeOfSupply = shipToState
+	} else if code := gst.ResolveStateCode(buyer.GSTIN, buyer.BillingAddress.State); code != "" {
+		placeOfSupply = code
+	} else {
+		placeOfSupply = sellerState
+	}
 
-// generateInvoiceNumber generates a unique invoice number
-func (s *InvoiceService) generateInvoiceNumber() string {
-	var count int64
-	database.GetDB().Model(&models.Invoice{}).Count(&count)
-	year := time.Now().Year()
-	return fmt.Sprintf("INV-%d-%06d", year, count+1)
+	if placeOfSupply == sellerState {
+		return placeOfSupply, constants.SupplyTypeIntraState, nil
+	}
+	return placeOfSupply, constants.SupplyTypeInterState, nil
 }
 
-// calculateInvoiceTotals calculates invoice totals
+// calculateInvoiceTotals calculates invoice totals. Line and invoice-level
+// discounts are taken off first, and GST is charged on the discounted
+// taxable value. Intra-state supplies split the GST rate equally between
+// CGST and SGST; inter-state supplies levy the full rate as IGST. Amounts
+// are rounded per line as described in the money package and the invoice
+// totals are sums of the rounded lines.
 func (s *InvoiceService) calculateInvoiceTotals(invoice *models.Invoice) {
-	var subTotal, totalGST float64
+	var subTotal, totalCGST, totalSGST, totalIGST money.Amount
+	interState := invoice.SupplyType == constants.SupplyTypeInterState
+
+	grosses := make([]money.Amount, len(invoice.LineItems))
+	lineDiscounts := make([]models.Discount, len(invoice.LineItems))
+	for i, lineItem := range invoice.LineItems {
+		grosses[i] = lineItem.Quantity.MulAmount(lineItem.Rate)
+		lineDiscounts[i] = lineItem.Discount
+	}
+	discounts, shares := applyDiscounts(grosses, lineDiscounts, invoice.Discount)
 
+	var grossAmount, lineDiscount, invoiceDiscount money.Amount
 	for i := range invoice.LineItems {
 		lineItem := &invoice.LineItems[i]
-		lineItem.Amount = lineItem.Quantity * lineItem.Rate
-		lineItem.GSTAmount = (lineItem.Amount * float64(lineItem.GSTRate)) / 100
+		lineItem.GrossAmount = grosses[i]
+		lineItem.DiscountAmount = discounts[i]
+		lineItem.InvoiceDiscountAmount = shares[i]
+		lineItem.Amount = lineItem.GrossAmount - lineItem.DiscountAmount - lineItem.InvoiceDiscountAmount
+		lineItem.CGSTAmount, lineItem.SGSTAmount, lineItem.IGSTAmount = calculateLineTax(lineItem.Amount, lineItem.GSTRate, interState)
+		lineItem.GSTAmount = lineItem.CGSTAmount + lineItem.SGSTAmount + lineItem.IGSTAmount
 		lineItem.TotalAmount = lineItem.Amount + lineItem.GSTAmount
 
+		grossAmount += lineItem.GrossAmount
+		lineDiscount += lineItem.DiscountAmount
+		invoiceDiscount += lineItem.InvoiceDiscountAmount
 		subTotal += lineItem.Amount
-		totalGST += lineItem.GSTAmount
+		totalCGST += lineItem.CGSTAmount
+		totalSGST += lineItem.SGSTAmount
+		totalIGST += lineItem.IGSTAmount
 	}
 
+	invoice.GrossAmount = grossAmount
+	invoice.DiscountAmount = invoiceDiscount
+	invoice.TotalDiscount = lineDiscount + invoiceDiscount
 	invoice.SubTotal = subTotal
-	invoice.TotalGST = totalGST
-	invoice.TotalAmount = subTotal + totalGST
+	invoice.TotalCGST = totalCGST
+	invoice.TotalSGST = totalSGST
+	invoice.TotalIGST = totalIGST
+	invoice.TotalGST = totalCGST + totalSGST + totalIGST
+	invoice.TotalAmount = subTotal + invoice.TotalGST
+}
+
+// validateInvoiceDiscounts checks the line and invoice-level discounts of
+// an invoice
+func validateInvoiceDiscounts(invoice *models.Invoice) error {
+	grosses := make([]money.Amount, len(invoice.LineItems))
+	lineDiscounts := make([]models.Discount, len(invoice.LineItems))
+	for i, lineItem := range invoice.LineItems {
+		grosses[i] = lineItem.Quantity.MulAmount(lineItem.Rate)
+		lineDiscounts[i] = lineItem.Discount
+	}
+	return validateDiscounts(grosses, lineDiscounts, invoice.Discount)
 }
 
-// updateInvoicePaymentStatus updates the payment status of an invoice
-func (s *InvoiceService) updateInvoicePaymentStatus(invoiceID uint) {
+// validateTransport normalises the transport details of an invoice and
+// checks them. A vehicle number without a mode is taken to travel by road;
+// other modes need the transport document instead of a vehicle.
+func validateTransport(invoice *models.Invoice) error {
+	t := &invoice.Transport
+	t.TransporterID = validation.Normalise(t.TransporterID)
+	t.TransporterName = strings.TrimSpace(t.TransporterName)
+	t.Mode = strings.ToUpper(strings.TrimSpace(t.Mode))
+	t.VehicleNumber = validation.Normalise(t.VehicleNumber)
+	t.VehicleType = strings.ToUpper(strings.TrimSpace(t.VehicleType))
+	t.DocumentNumber = strings.TrimSpace(t.DocumentNumber)
+	if t.Mode == "" && t.VehicleNumber != "" {
+		t.Mode = constants.TransportModeRoad
+	}
+	if t.Mode == constants.TransportModeRoad && t.VehicleType == "" {
+		t.VehicleType = constants.VehicleTypeRegular
+	}
+
+	var errs validation.Errors
+	if t.TransporterID != "" {
+		if err := validation.ValidateGSTIN(t.TransporterID); err != nil {
+			errs.Add("transport.transporter_id", err.Error())
+		}
+	}
+
+	switch t.Mode {
+	case "":
+	case constants.TransportModeRoad:
+		if t.VehicleNumber == "" {
+			errs.Add("transport.vehicle_number", "is required for road transport")
+		} else if err := validation.ValidateVehicleNumber(t.VehicleNumber); err != nil {
+			errs.Add("transport.vehicle_number", err.Error())
+		}
+		if t.VehicleType != constants.VehicleTypeRegular && t.VehicleType != constants.VehicleTypeODC {
+			errs.Add("transport.vehicle_type", "must be REGULAR or ODC")
+		}
+	case constants.TransportModeRail, constants.TransportModeAir, constants.TransportModeShip:
+		if t.DocumentNumber == "" {
+			errs.Add("transport.document_number", "is required for "+strings.ToLower(t.Mode)+" transport")
+		}
+		if t.DocumentDate == nil {
+			errs.Add("transport.document_date", "is required for "+strings.ToLower(t.Mode)+" transport")
+		}
+		if t.VehicleNumber != "" || t.VehicleType != "" {
+			errs.Add("transport.vehicle_number", "only applies to road transport")
+		}
+	default:
+		errs.Add("transport.mode", "must be ROAD, RAIL, AIR or SHIP")
+	}
+
+	if len(t.DocumentNumber) > 15 {
+		errs.Add("transport.document_number", "must be at most 15 characters")
+	}
+	if t.DocumentDate != nil && t.DocumentDate.Format("2006-01-02") < invoice.InvoiceDate.Format("2006-01-02") {
+		errs.Add("transport.document_date", "cannot be before the invoice date")
+	}
+	if t.DistanceKm < 0 || t.DistanceKm > constants.MaxTransportDistanceKm {
+		errs.Add("transport.distance_km", fmt.Sprintf("must be between 0 and %d", constants.MaxTransportDistanceKm))
+	}
+	return errs.Err()
+}
+
+// resolveLineHSNCodes checks the HSN code given on each line and defaults
+// it to the item's HSN code when the line has none
+func resolveLineHSNCodes(tx *gorm.DB, lines []models.InvoiceLineItem) error {
+	for i := range lines {
+		line := &lines[i]
+		if err := checkLineHSNCode(i, &line.HSNCode); err != nil {
+			return err
+		}
+		if line.HSNCode == "" && line.ItemID != nil {
+			var item models.Item
+			if err := tx.Select("hsn_code").First(&item, *line.ItemID).Error; err != nil {
+				return fmt.Errorf("line %d: item not found", i+1)
+			}
+			line.HSNCode = strings.TrimSpace(item.HSNCode)
+		}
+	}
+	return nil
+}
+
+// lineHSNCode returns the HSN code of an invoice line. Lines saved before
+// lines had their own code fall back to the item's.
+func lineHSNCode(line *models.InvoiceLineItem) string {
+	if line.HSNCode == "" && line.Item != nil {
+		return line.Item.HSNCode
+	}
+	return line.HSNCode
+}
+
+// checkLineHSNCode trims the HSN code of a line and checks its format
+func checkLineHSNCode(i int, hsn *string) error {
+	*hsn = strings.TrimSpace(*hsn)
+	if *hsn == "" {
+		return nil
+	}
+	if err := validation.ValidateHSN(*hsn); err != nil {
+		return fmt.Errorf("line %d: hsn_code %s", i+1, err)
+	}
+	return nil
+}
+
+// validateDiscounts checks discount types and values, and that no flat
+// discount exceeds the value it applies to
+func validateDiscounts(grosses []money.Amount, lineDiscounts []models.Discount, documentDiscount models.Discount) error {
+	var net money.Amount
+	for i, discount := range lineDiscounts {
+		if err := validateDiscount(discount); err != nil {
+			return fmt.Errorf("line %d: %w", i+1, err)
+		}
+		if discount.DiscountType == constants.DiscountTypeFlat && discount.DiscountValue > grosses[i] {
+			return fmt.Errorf("line %d: discount cannot exceed the line value", i+1)
+		}
+		net += grosses[i] - discountOn(grosses[i], discount)
+	}
+
+	if err := validateDiscount(documentDiscount); err != nil {
+		return err
+	}
+	if documentDiscount.DiscountType == constants.DiscountTypeFlat && documentDiscount.DiscountValue > net {
+		return errors.New("discount cannot exceed the value of the lines")
+	}
+	return nil
+}
+
+// validateDiscount checks a single discount's type and value
+func validateDiscount(discount models.Discount) error {
+	switch discount.DiscountType {
+	case "":
+		if discount.DiscountValue != 0 {
+			return errors.New("discount_type is required with discount_value")
+		}
+	case constants.DiscountTypePercent:
+		if discount.DiscountValue <= 0 || discount.DiscountValue > money.FromRupees(100) {
+			return errors.New("discount percentage must be greater than 0 and at most 100")
+		}
+	case constants.DiscountTypeFlat:
+		if discount.DiscountValue <= 0 {
+			return errors.New("flat discount must be positive")
+		}
+	default:
+		return errors.New("discount_type must be PERCENT or FLAT")
+	}
+	return nil
+}
+
+// applyDiscounts returns the line discount of each line and its share of
+// the document-level discount. A percentage document discount is taken
+// off every line at the same rate; a flat one is shared out in proportion
+// to the line values after line discounts, with the last line taking the
+// rounding difference so the shares add up exactly.
+func applyDiscounts(grosses []money.Amount, lineDiscounts []models.Discount, documentDiscount models.Discount) (discounts, shares []money.Amount) {
+	discounts = make([]money.Amount, len(grosses))
+	shares = make([]money.Amount, len(grosses))
+
+	nets := make([]money.Amount, len(grosses))
+	var total money.Amount
+	last := -1
+	for i, gross := range grosses {
+		discounts[i] = discountOn(gross, lineDiscounts[i])
+		nets[i] = gross - discounts[i]
+		total += nets[i]
+		if nets[i] > 0 {
+			last = i
+		}
+	}
+
+	switch documentDiscount.DiscountType {
+	case constants.DiscountTypePercent:
+		for i, net := range nets {
+			shares[i] = discountOn(net, documentDiscount)
+		}
+	case constants.DiscountTypeFlat:
+		discount := money.Min(documentDiscount.DiscountValue, total)
+		remaining := discount
+		for i, net := range nets {
+			if i == last {
+				shares[i] = remaining
+				break
+			}
+			shares[i] = discount.Allocate(net, total)
+			remaining -= shares[i]
+		}
+	}
+
+	return discounts, shares
+}
+
+// discountOn returns the discount on an amount, never more than the amount
+// itself. Percentages are held with two decimals, e.g. 12.5% as 12.50.
+func discountOn(amount money.Amount, discount models.Discount) money.Amount {
+	switch discount.DiscountType {
+	case constants.DiscountTypePercent:
+		return money.Min(amount.PercentOf(discount.DiscountValue.Paise(), 100), amount)
+	case constants.DiscountTypeFlat:
+		return money.Min(discount.DiscountValue, amount)
+	}
+	return 0
+}
+
+// calculateLineTax splits the GST on a taxable amount into CGST, SGST and
+// IGST. Each component is rounded to the paisa on its own, so for
+// intra-state supplies CGST and SGST are always equal.
+func calculateLineTax(taxable money.Amount, gstRate int, interState bool) (cgst, sgst, igst money.Amount) {
+	if interState {
+		return 0, 0, taxable.Percent(gstRate)
+	}
+	half := taxable.PercentOf(int64(gstRate), 2)
+	return half, half, 0
+}
+
+// updateInvoicePaymentStatus recomputes the amount paid, amount due and
+// payment status of an invoice from its payments, net of refunds and
+// leaving out bounced and reversed ones, and its adjustment notes. It
+// must run in the same transaction as the change that triggered it; the
+// invoice row is locked so concurrent recalculations cannot interleave.
+func updateInvoicePaymentStatus(tx *gorm.DB, invoiceID uint) error {
 	var invoice models.Invoice
-	database.GetDB().First(&invoice, invoiceID)
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&invoice, invoiceID).Error; err != nil {
+		return err
+	}
 
-	var totalPaid float64
-	database.GetDB().Model(&models.Payment{}).Where("invoice_id = ?", invoiceID).
-		Select("COALESCE(SUM(amount), 0)").Row().Scan(&totalPaid)
+	var totalPaid money.Amount
+	if err := activePayments(tx, invoiceID).
+		Select("COALESCE(SUM(amount), 0)").Row().Scan(&totalPaid); err != nil {
+		return err
+	}
 
-	invoice.AmountPaid = totalPaid
-	invoice.AmountDue = invoice.TotalAmount - totalPaid
+	netAmount := invoice.TotalAmount + invoice.TotalDebited - invoice.TotalCredited
+	amountDue := netAmount - totalPaid
 
-	if totalPaid >= invoice.TotalAmount {
-		invoice.PaymentStatus = constants.PaymentStatusPaid
+	status := constants.PaymentStatusPending
+	if amountDue <= 0 {
+		status = constants.PaymentStatusPaid
 	} else if totalPaid > 0 {
-		invoice.PaymentStatus = constants.PaymentStatusPartial
-	} else {
-		invoice.PaymentStatus = constants.PaymentStatusPending
+		status = constants.PaymentStatusPartial
 	}
 
-	database.GetDB().Save(&invoice)
+	return tx.Model(&invoice).Updates(map[string]interface{}{
+		"amount_paid":    totalPaid,
+		"amount_due":     amountDue,
+		"payment_status": status,
+	}).Error
 }
@@ -0,0 +1,285 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"invoice-generator/internal/constants"
+	"invoice-generator/internal/database"
+	"invoice-generator/internal/models"
+)
+
+// NumberingService manages document number series
+type NumberingService struct{}
+
+// NewNumberingService creates a new numbering service
+func NewNumberingService() *NumberingService {
+	return &NumberingService{}
+}
+
+var (
+	seriesNamePattern   = regexp.MustCompile(`^[A-Z0-9-]{1,10}$`)
+	patternTokenPattern = regexp.MustCompile(`\{[^}]*\}`)
+	patternSeqToken     = regexp.MustCompile(`^\{SEQ(?::([1-9]))?\}$`)
+	patternLiteralChars = regexp.MustCompile(`^[A-Za-z0-9/-]*$`)
+)
+
+// defaultSeriesNames maps each document type to the name of the series
+// created for a seller on first use
+var defaultSeriesNames = map[string]string{
+	constants.DocumentTypeInvoice:    constants.DefaultInvoiceSeriesName,
+	constants.DocumentTypeCreditNote: constants.DefaultCreditNoteSeriesName,
+	constants.DocumentTypeDebitNote:  constants.DefaultDebitNoteSeriesName,
+	constants.DocumentTypeQuotation:  constants.DefaultQuotationSeriesName,
+}
+
+// allocatedNumber is a document number reserved from a series
+type allocatedNumber struct {
+	Number        string
+	SeriesID      uint
+	Sequence      int64
+	FinancialYear string
+}
+
+// GetSeries returns the number series configured for a seller
+func (s *NumberingService) GetSeries(sellerID uint) ([]models.NumberSeries, error) {
+	var series []models.NumberSeries
+	if err := database.GetDB().Where("seller_id = ?", sellerID).
+		Order("document_type, name").Find(&series).Error; err != nil {
+		return nil, err
+	}
+	return series, nil
+}
+
+// CreateSeries creates a new number series for a seller
+func (s *NumberingService) CreateSeries(sellerID uint, series *models.NumberSeries) error {
+	series.ID = 0
+	series.SellerID = sellerID
+	series.Name = strings.ToUpper(strings.TrimSpace(series.Name))
+	if series.DocumentType == "" {
+		series.DocumentType = constants.DocumentTypeInvoice
+	}
+	if series.Pattern == "" {
+		series.Pattern = constants.DefaultNumberSeriesPattern
+	}
+
+	if err := validateSeries(series); err != nil {
+		return err
+	}
+
+	return database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if series.IsDefault {
+			if err := clearDefaultSeries(tx, sellerID, series.DocumentType); err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(series).Error; err != nil {
+			if strings.Contains(err.Error(), "duplicate key") {
+				return errors.New("series name already exists")
+			}
+			return errors.New("failed to create series")
+		}
+		return nil
+	})
+}
+
+// UpdateSeries changes the pattern or default flag of a seller's series.
+// Numbers already issued are not affected and the sequence continues.
+func (s *NumberingService) UpdateSeries(id, sellerID uint, updateData *models.NumberSeries) (*models.NumberSeries, error) {
+	var series models.NumberSeries
+	if err := database.GetDB().Where("id = ? AND seller_id = ?", id, sellerID).First(&series).Error; err != nil {
+		return nil, errors.New("series not found")
+	}
+
+	if updateData.Pattern != "" {
+		series.Pattern = updateData.Pattern
+	}
+	if err := validateSeries(&series); err != nil {
+		return nil, err
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if updateData.IsDefault && !series.IsDefault {
+			if err := clearDefaultSeries(tx, sellerID, series.DocumentType); err != nil {
+				return err
+			}
+			series.IsDefault = true
+		}
+		return tx.Save(&series).Error
+	})
+	if err != nil {
+		return nil, errors.New("failed to update series")
+	}
+
+	return &series, nil
+}
+
+// clearDefaultSeries removes the default flag from a seller's series of
+// the given document type
+func clearDefaultSeries(tx *gorm.DB, sellerID uint, documentType string) error {
+	return tx.Model(&models.NumberSeries{}).
+		Where("seller_id = ? AND document_type = ? AND is_default = ?", sellerID, documentType, true).
+		Update("is_default", false).Error
+}
+
+// validateSeries checks the series name and pattern
+func validateSeries(series *models.NumberSeries) error {
+	if !seriesNamePattern.MatchString(series.Name) {
+		return errors.New("series name must be 1-10 characters of A-Z, 0-9 or '-'")
+	}
+	if _, ok := defaultSeriesNames[series.DocumentType]; !ok {
+		return errors.New("invalid document type")
+	}
+
+	hasSeq, hasFY := false, false
+	for _, token := range patternTokenPattern.FindAllString(series.Pattern, -1) {
+		switch {
+		case patternSeqToken.MatchString(token):
+			hasSeq = true
+		case token == "{FY}" || token == "{FY_SHORT}":
+			hasFY = true
+		case token == "{SERIES}" || token == "{YYYY}" || token == "{MM}":
+		default:
+			return fmt.Errorf("unknown placeholder %s in pattern", token)
+		}
+	}
+	if !hasSeq {
+		return errors.New("pattern must contain {SEQ} or {SEQ:n}")
+	}
+	if !hasFY {
+		return errors.New("pattern must contain {FY} or {FY_SHORT} so numbers stay unique across financial years")
+	}
+	if !patternLiteralChars.MatchString(patternTokenPattern.ReplaceAllString(series.Pattern, "")) {
+		return errors.New("pattern may only contain letters, digits, '/' and '-' besides placeholders")
+	}
+
+	// Make sure a realistic number fits the length allowed by GST rules
+	sample := formatDocumentNumber(series.Pattern, series.Name, "2025-26", time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC), 1)
+	if len(sample) > constants.MaxDocumentNumberLength {
+		return fmt.Errorf("pattern produces numbers longer than %d characters (e.g. %s)", constants.MaxDocumentNumberLength, sample)
+	}
+	return nil
+}
+
+// allocateDocumentNumber reserves the next number in a seller's series.
+// It must be called inside the transaction that stores the document: the
+// sequence row stays locked until that transaction ends, and a rollback
+// releases the number again so the series remains gapless.
+func allocateDocumentNumber(tx *gorm.DB, sellerID uint, documentType, seriesName string, date time.Time) (*allocatedNumber, error) {
+	series, err := findSeries(tx, sellerID, documentType, seriesName)
+	if err != nil {
+		return nil, err
+	}
+
+	fy := financialYear(date)
+	sequence := models.NumberSequence{SeriesID: series.ID, FinancialYear: fy}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&sequence).Error; err != nil {
+		return nil, fmt.Errorf("failed to initialise number sequence: %w", err)
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("series_id = ? AND financial_year = ?", series.ID, fy).
+		First(&sequence).Error; err != nil {
+		return nil, fmt.Errorf("failed to lock number sequence: %w", err)
+	}
+
+	sequence.LastNumber++
+	if err := tx.Model(&sequence).Update("last_number", sequence.LastNumber).Error; err != nil {
+		return nil, fmt.Errorf("failed to advance number sequence: %w", err)
+	}
+
+	number := formatDocumentNumber(series.Pattern, series.Name, fy, date, sequence.LastNumber)
+	if len(number) > constants.MaxDocumentNumberLength {
+		return nil, fmt.Errorf("document number %s exceeds %d characters", number, constants.MaxDocumentNumberLength)
+	}
+
+	return &allocatedNumber{
+		Number:        number,
+		SeriesID:      series.ID,
+		Sequence:      sequence.LastNumber,
+		FinancialYear: fy,
+	}, nil
+}
+
+// findSeries returns the named series, or the seller's default series for
+// the document type, creating the default series on first use
+func findSeries(tx *gorm.DB, sellerID uint, documentType, seriesName string) (*models.NumberSeries, error) {
+	var series models.NumberSeries
+
+	if seriesName != "" {
+		err := tx.Where("seller_id = ? AND document_type = ? AND name = ?", sellerID, documentType, strings.ToUpper(seriesName)).
+			First(&series).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("number series %s not found", seriesName)
+			}
+			return nil, err
+		}
+		return &series, nil
+	}
+
+	err := tx.Where("seller_id = ? AND document_type = ? AND is_default = ?", sellerID, documentType, true).
+		First(&series).Error
+	if err == nil {
+		return &series, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	series = models.NumberSeries{
+		SellerID:     sellerID,
+		Name:         defaultSeriesNames[documentType],
+		DocumentType: documentType,
+		Pattern:      constants.DefaultNumberSeriesPattern,
+		IsDefault:    true,
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&series).Error; err != nil {
+		return nil, fmt.Errorf("failed to create default number series: %w", err)
+	}
+	if err := tx.Where("seller_id = ? AND name = ?", sellerID, series.Name).First(&series).Error; err != nil {
+		return nil, fmt.Errorf("failed to load default number series: %w", err)
+	}
+	return &series, nil
+}
+
+// formatDocumentNumber expands the placeholders of a series pattern
+func formatDocumentNumber(pattern, seriesName, fy string, date time.Time, sequence int64) string {
+	return patternTokenPattern.ReplaceAllStringFunc(pattern, func(token string) string {
+		switch token {
+		case "{SERIES}":
+			return seriesName
+		case "{FY}":
+			return fy
+		case "{FY_SHORT}":
+			return strings.ReplaceAll(fy, "-", "")[2:]
+		case "{YYYY}":
+			return strconv.Itoa(date.Year())
+		case "{MM}":
+			return fmt.Sprintf("%02d", int(date.Month()))
+		}
+		if m := patternSeqToken.FindStringSubmatch(token); m != nil {
+			width := 1
+			if m[1] != "" {
+				width, _ = strconv.Atoi(m[1])
+			}
+			return fmt.Sprintf("%0*d", width, sequence)
+		}
+		return token
+	})
+}
+
+// financialYear returns the Indian financial year (April to March) that
+// contains date, formatted as e.g. "2025-26"
+func financialYear(date time.Time) string {
+	start := date.Year()
+	if int(date.Month()) < constants.FinancialYearStartMonth {
+		start--
+	}
+	return fmt.Sprintf("%d-%02d", start, (start+1)%100)
+}
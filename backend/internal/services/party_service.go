@@ -0,0 +1,331 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"invoice-generator/internal/constants"
+	"invoice-generator/internal/database"
+	"invoice-generator/internal/gst"
+	"invoice-generator/internal/models"
+	"invoice-generator/internal/money"
+	"invoice-generator/internal/validation"
+)
+
+// PartyService manages a seller's customers and vendors
+type PartyService struct{}
+
+// NewPartyService creates a new party service
+func NewPartyService() *PartyService {
+	return &PartyService{}
+}
+
+// CreateParty validates and stores a new party owned by the user
+func (s *PartyService) CreateParty(party *models.Party, userID uint) error {
+	party.ID = 0
+	party.OwnerID = userID
+	for i := range party.Contacts {
+		party.Contacts[i].ID = 0
+	}
+
+	if err := validateParty(party); err != nil {
+		return err
+	}
+
+	if err := database.GetDB().Create(party).Error; err != nil {
+		return fmt.Errorf("failed to create party: %w", err)
+	}
+	return nil
+}
+
+// GetParties returns the parties visible to a user, optionally filtered by
+// type and by a search on name, company name, GSTIN, email or phone
+func (s *PartyService) GetParties(userID uint, isAdmin bool, partyType, search string, page, limit int) ([]models.Party, int64, error) {
+	var parties []models.Party
+	query := database.GetDB().Preload("Contacts")
+
+	if !isAdmin {
+		query = query.Where("owner_id = ?", userID)
+	}
+	if partyType != "" {
+		query = query.Where("party_type = ? OR party_type = ?", partyType, constants.PartyTypeBoth)
+	}
+	if search = strings.TrimSpace(search); search != "" {
+		like := "%" + search + "%"
+		query = query.Where("name ILIKE ? OR company_name ILIKE ? OR gstin ILIKE ? OR email ILIKE ? OR phone LIKE ?",
+			like, like, like, like, like)
+	}
+
+	var total int64
+	query.Model(&models.Party{}).Count(&total)
+
+	offset := (page - 1) * limit
+	if err := query.Order("name").Limit(limit).Offset(offset).Find(&parties).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return parties, total, nil
+}
+
+// GetParty returns a single party with access control
+func (s *PartyService) GetParty(id uint, userID uint, isAdmin bool) (*models.Party, error) {
+	var party models.Party
+	query := database.GetDB().Preload("Contacts")
+
+	if !isAdmin {
+		query = query.Where("owner_id = ?", userID)
+	}
+
+	if err := query.First(&party, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("party not found")
+		}
+		return nil, err
+	}
+
+	return &party, nil
+}
+
+// UpdateParty replaces the details and contacts of a party. Documents
+// already issued to the party are not affected.
+func (s *PartyService) UpdateParty(id uint, updateData *models.Party, userID uint, isAdmin bool) (*models.Party, error) {
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		party, err := s.lockParty(tx, id, userID, isAdmin)
+		if err != nil {
+			return err
+		}
+
+		party.PartyType = updateData.PartyType
+		party.Name = updateData.Name
+		party.CompanyName = updateData.CompanyName
+		party.GSTIN = updateData.GSTIN
+		party.PAN = updateData.PAN
+		party.Email = updateData.Email
+		party.Phone = updateData.Phone
+		party.BillingAddress = updateData.BillingAddress
+		party.ShippingAddress = updateData.ShippingAddress
+		party.PaymentTermsDays = updateData.PaymentTermsDays
+		party.CreditLimit = updateData.CreditLimit
+		party.Notes = updateData.Notes
+		party.Contacts = updateData.Contacts
+		for i := range party.Contacts {
+			party.Contacts[i].ID = 0
+			party.Contacts[i].PartyID = party.ID
+		}
+
+		if err := validateParty(party); err != nil {
+			return err
+		}
+
+		if err := tx.Where("party_id = ?", party.ID).Delete(&models.PartyContact{}).Error; err != nil {
+			return err
+		}
+		return tx.Save(party).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetParty(id, userID, isAdmin)
+}
+
+// DeleteParty removes a party that no document refers to
+func (s *PartyService) DeleteParty(id uint, userID uint, isAdmin bool) error {
+	return database.GetDB().Transaction(func(tx *gorm.DB) error {
+		party, err := s.lockParty(tx, id, userID, isAdmin)
+		if err != nil {
+			return err
+		}
+
+		for _, model := range []interface{}{&models.Invoice{}, &models.Quotation{}, &models.RecurringInvoice{}, &models.CreditEntry{}} {
+			var count int64
+			if err := tx.Model(model).Where("party_id = ?", party.ID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return errors.New("cannot delete a party with invoices, quotations, recurring invoices or credit entries")
+			}
+		}
+
+		if err := tx.Where("party_id = ?", party.ID).Delete(&models.PartyContact{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(party).Error
+	})
+}
+
+// lockParty loads a party for update, restricted to its owner unless the
+// user is an admin
+func (s *PartyService) lockParty(tx *gorm.DB, id uint, userID uint, isAdmin bool) (*models.Party, error) {
+	var party models.Party
+	query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id)
+	if !isAdmin {
+		query = query.Where("owner_id = ?", userID)
+	}
+	if err := query.First(&party).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("party not found")
+		}
+		return nil, err
+	}
+	return &party, nil
+}
+
+// validateParty normalises and checks a party before it is saved
+func validateParty(party *models.Party) error {
+	party.Name = strings.TrimSpace(party.Name)
+	party.GSTIN = validation.Normalise(party.GSTIN)
+	party.PAN = validation.Normalise(party.PAN)
+	if party.PartyType == "" {
+		party.PartyType = constants.PartyTypeCustomer
+	}
+
+	if party.Name == "" {
+		return errors.New("name is required")
+	}
+	validType := false
+	for _, t := range constants.ValidPartyTypes {
+		if party.PartyType == t {
+			validType = true
+			break
+		}
+	}
+	if !validType {
+		return errors.New("party_type must be CUSTOMER, VENDOR or BOTH")
+	}
+	if party.PaymentTermsDays < 0 {
+		return errors.New("payment_terms_days cannot be negative")
+	}
+	if party.CreditLimit < 0 {
+		return errors.New("credit_limit cannot be negative")
+	}
+
+	primary := 0
+	for i, contact := range party.Contacts {
+		if strings.TrimSpace(contact.Name) == "" {
+			return fmt.Errorf("contact %d: name is required", i+1)
+		}
+		if contact.IsPrimary {
+			primary++
+		}
+	}
+	if primary > 1 {
+		return errors.New("only one contact can be primary")
+	}
+
+	var errs validation.Errors
+	validation.CheckRegistration(&errs, "gstin", party.GSTIN, "pan", party.PAN,
+		"billing_address.state", party.BillingAddress.State)
+	if party.ShippingAddress.State != "" {
+		if err := validation.ValidateState(party.ShippingAddress.State); err != nil {
+			errs.Add("shipping_address.state", err.Error())
+		}
+	}
+	return errs.Err()
+}
+
+// resolveBuyer checks that a document is addressed to exactly one of a
+// registered user or one of the seller's own parties, and returns the
+// buyer. A registered user is returned as an unsaved party carrying the
+// user's details, so callers can treat both kinds of buyer alike.
+func resolveBuyer(tx *gorm.DB, sellerID uint, generatedForID, partyID *uint) (*models.Party, error) {
+	switch {
+	case generatedForID != nil && partyID != nil:
+		return nil, errors.New("set either generated_for_id or party_id, not both")
+	case partyID != nil:
+		var party models.Party
+		if err := tx.Where("owner_id = ?", sellerID).First(&party, *partyID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.New("party not found")
+			}
+			return nil, err
+		}
+		if party.PartyType == constants.PartyTypeVendor {
+			return nil, errors.New("party is a vendor, not a customer")
+		}
+		return &party, nil
+	case generatedForID != nil:
+		var user models.User
+		if err := tx.First(&user, *generatedForID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.New("customer not found")
+			}
+			return nil, err
+		}
+		return userAsParty(&user), nil
+	default:
+		return nil, errors.New("generated_for_id or party_id is required")
+	}
+}
+
+// userAsParty presents a registered user's details in the shape of a party
+func userAsParty(user *models.User) *models.Party {
+	return &models.Party{
+		Name:        user.Name,
+		CompanyName: user.CompanyName,
+		GSTIN:       user.GSTIN,
+		PAN:         user.PAN,
+		Email:       user.Email,
+		Phone:       user.Phone,
+		BillingAddress: models.Address{
+			Address: user.Address,
+			City:    user.City,
+			State:   user.State,
+			Pincode: user.Pincode,
+		},
+	}
+}
+
+// snapshotParty copies a party's details for storing on a document
+func snapshotParty(party *models.Party) models.PartySnapshot {
+	return models.PartySnapshot{
+		Name:        party.Name,
+		CompanyName: party.CompanyName,
+		GSTIN:       party.GSTIN,
+		Email:       party.Email,
+		Phone:       party.Phone,
+		Address:     party.BillingAddress,
+		StateCode:   gst.ResolveStateCode(party.GSTIN, party.BillingAddress.State),
+	}
+}
+
+// paymentTermsDays returns the number of days after the invoice date that
+// an invoice to the buyer falls due
+func paymentTermsDays(buyer *models.Party) int {
+	if buyer != nil && buyer.PaymentTermsDays > 0 {
+		return buyer.PaymentTermsDays
+	}
+	return constants.DefaultDueDays
+}
+
+// checkCreditLimit refuses to issue or amend an invoice if that would take
+// its party's outstanding balance over the party's credit limit. The party
+// row is locked so that concurrent issues are checked one after the other.
+func checkCreditLimit(tx *gorm.DB, invoice *models.Invoice) error {
+	if invoice.PartyID == nil {
+		return nil
+	}
+
+	var party models.Party
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&party, *invoice.PartyID).Error; err != nil {
+		return err
+	}
+	if party.CreditLimit == 0 {
+		return nil
+	}
+
+	var outstanding money.Amount
+	if err := tx.Model(&models.Invoice{}).
+		Where("party_id = ? AND status = ? AND id <> ?", party.ID, constants.InvoiceStatusIssued, invoice.ID).
+		Select("COALESCE(SUM(amount_due), 0)").Row().Scan(&outstanding); err != nil {
+		return err
+	}
+	if outstanding+invoice.AmountDue > party.CreditLimit {
+		return fmt.Errorf("invoice would exceed the credit limit of %s (outstanding %s, limit %s)",
+			party.Name, outstanding, party.CreditLimit)
+	}
+	return nil
+}
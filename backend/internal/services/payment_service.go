@@ -0,0 +1,302 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"invoice-generator/internal/constants"
+	"invoice-generator/internal/database"
+	"invoice-generator/internal/models"
+	"invoice-generator/internal/money"
+)
+
+// PaymentService handles what happens to payments after they are
+// recorded: refunds, the deposit, clearing and bouncing of cheques, and
+// reversal of mistaken entries. Every change is kept in the payment's
+// audit trail.
+type PaymentService struct {
+	invoiceService *InvoiceService
+	noteService    *AdjustmentNoteService
+}
+
+// NewPaymentService creates a new payment service
+func NewPaymentService(invoiceService *InvoiceService, noteService *AdjustmentNoteService) *PaymentService {
+	return &PaymentService{invoiceService: invoiceService, noteService: noteService}
+}
+
+// AddRefund records money paid back to the buyer of an issued invoice, for
+// example after a credit note for a return left a paid invoice overpaid,
+// with a negative amount due. The refund is stored as a payment with a
+// negative amount and cannot exceed what has been paid. Only the seller
+// (or an admin) may record refunds.
+func (s *PaymentService) AddRefund(invoiceID uint, refund *models.Payment, userID uint, isAdmin bool) error {
+	*refund = models.Payment{
+		InvoiceID:     invoiceID,
+		PaymentType:   constants.PaymentTypeRefund,
+		Amount:        -refund.Amount,
+		PaymentMethod: refund.PaymentMethod,
+		PaymentDate:   refund.PaymentDate,
+		Reference:     strings.TrimSpace(refund.Reference),
+		Notes:         refund.Notes,
+		Status:        constants.PaymentCleared,
+		Reason:        strings.TrimSpace(refund.Reason),
+	}
+	if refund.Amount >= 0 {
+		return errors.New("refund amount must be positive")
+	}
+	if refund.Reason == "" {
+		return errors.New("reason is required")
+	}
+	if refund.PaymentMethod == constants.PaymentMethodCredit {
+		return errors.New("refunds must be paid out by cash, bank transfer, cheque, UPI or card")
+	}
+	if refund.PaymentDate.IsZero() {
+		refund.PaymentDate = time.Now()
+	}
+
+	return database.GetDB().Transaction(func(tx *gorm.DB) error {
+		invoice, err := s.invoiceService.lockInvoice(tx, invoiceID, userID, isAdmin)
+		if err != nil {
+			return err
+		}
+		if invoice.Status != constants.InvoiceStatusIssued {
+			return errors.New("refunds can only be recorded against issued invoices")
+		}
+		if -refund.Amount > invoice.AmountPaid {
+			return errors.New("refund amount cannot exceed the amount paid")
+		}
+
+		if err := tx.Create(refund).Error; err != nil {
+			return fmt.Errorf("failed to add refund: %w", err)
+		}
+		if err := recordPaymentEvent(tx, refund, constants.PaymentActionRecorded, "", refund.Reason, userID); err != nil {
+			return err
+		}
+		return updateInvoicePaymentStatus(tx, invoiceID)
+	})
+}
+
+// DepositCheque marks a received cheque as deposited in the bank
+func (s *PaymentService) DepositCheque(id uint, request *models.PaymentStatusRequest, userID uint, isAdmin bool) (*models.Payment, error) {
+	return s.changeStatus(id, request.Reason, userID, isAdmin, func(tx *gorm.DB, payment *models.Payment) (string, error) {
+		if payment.PaymentMethod != constants.PaymentMethodCheque || payment.Status != constants.PaymentReceived {
+			return "", errors.New("only received cheques can be deposited")
+		}
+		date := statusDate(request.Date)
+		if date.Before(payment.PaymentDate.Truncate(24 * time.Hour)) {
+			return "", errors.New("deposit date cannot be before the cheque was received")
+		}
+		payment.Status = constants.PaymentDeposited
+		payment.DepositedAt = &date
+		return constants.PaymentActionDeposited, nil
+	})
+}
+
+// ClearCheque marks a deposited cheque as cleared by the bank
+func (s *PaymentService) ClearCheque(id uint, request *models.PaymentStatusRequest, userID uint, isAdmin bool) (*models.Payment, error) {
+	return s.changeStatus(id, request.Reason, userID, isAdmin, func(tx *gorm.DB, payment *models.Payment) (string, error) {
+		if payment.PaymentMethod != constants.PaymentMethodCheque || payment.Status != constants.PaymentDeposited {
+			return "", errors.New("only deposited cheques can be cleared")
+		}
+		date := statusDate(request.Date)
+		if payment.DepositedAt != nil && date.Before(payment.DepositedAt.Truncate(24*time.Hour)) {
+			return "", errors.New("clearing date cannot be before the cheque was deposited")
+		}
+		payment.Status = constants.PaymentCleared
+		payment.ClearedAt = &date
+		return constants.PaymentActionCleared, nil
+	})
+}
+
+// BounceCheque marks a deposited cheque as returned unpaid. The cheque no
+// longer counts as paid, so the invoice is reopened with its amount due
+// again, and the excess of an overpaying cheque is taken back off the
+// party's credit balance, even if that takes it below zero. Bank charges,
+// if given, are recovered from the buyer with a debit note issued in the
+// same transaction.
+func (s *PaymentService) BounceCheque(id uint, request *models.ChequeBounceRequest, userID uint, isAdmin bool) (*models.Payment, error) {
+	reason := strings.TrimSpace(request.Reason)
+	if reason == "" {
+		return nil, errors.New("reason is required")
+	}
+	if request.BankCharges < 0 {
+		return nil, errors.New("bank charges cannot be negative")
+	}
+
+	return s.changeStatus(id, reason, userID, isAdmin, func(tx *gorm.DB, payment *models.Payment) (string, error) {
+		if payment.PaymentMethod != constants.PaymentMethodCheque || payment.Status != constants.PaymentDeposited {
+			return "", errors.New("only deposited cheques can bounce")
+		}
+		date := statusDate(request.Date)
+		payment.Status = constants.PaymentBounced
+		payment.BouncedAt = &date
+		payment.Reason = reason
+		payment.BankCharges = request.BankCharges
+		if request.BankCharges.IsZero() {
+			return constants.PaymentActionBounced, nil
+		}
+
+		// Charges for a dishonoured cheque are a penalty rather than a
+		// supply, so the debit note carries no GST
+		note := models.AdjustmentNote{
+			NoteType: constants.NoteTypeDebit,
+			NoteDate: date,
+			Reason:   fmt.Sprintf("Bank charges for bounced cheque %s", payment.Reference),
+			LineItems: []models.AdjustmentNoteLineItem{{
+				Description: "Cheque return charges",
+				Quantity:    money.QuantityFromInt(1),
+				Rate:        request.BankCharges,
+			}},
+		}
+		if err := s.noteService.createNote(tx, payment.InvoiceID, &note, userID, isAdmin); err != nil {
+			return "", fmt.Errorf("failed to raise debit note for bank charges: %w", err)
+		}
+		payment.DebitNoteID = &note.ID
+		return constants.PaymentActionBounced, nil
+	})
+}
+
+// ReversePayment voids a payment or refund recorded by mistake. The entry
+// is kept, marked as reversed, and no longer counts towards the amount
+// paid. Credit used by the payment is given back to the party, and the
+// excess of an overpayment is taken back off its credit balance, even if
+// that takes it below zero. A payment cannot be reversed while refunds of
+// it would leave the invoice with less than nothing paid, and the
+// allocations of a receipt are changed through the receipt instead.
+func (s *PaymentService) ReversePayment(id uint, request *models.PaymentStatusRequest, userID uint, isAdmin bool) (*models.Payment, error) {
+	reason := strings.TrimSpace(request.Reason)
+	if reason == "" {
+		return nil, errors.New("reason is required")
+	}
+
+	return s.changeStatus(id, reason, userID, isAdmin, func(tx *gorm.DB, payment *models.Payment) (string, error) {
+		if payment.Status == constants.PaymentBounced || payment.Status == constants.PaymentReversed {
+			return "", fmt.Errorf("%s payments cannot be reversed", strings.ToLower(payment.Status))
+		}
+		if payment.ReceiptID != nil {
+			return "", errors.New("payment is an allocation of a receipt; change the receipt's allocation instead")
+		}
+
+		if err := checkReversible(tx, payment); err != nil {
+			return "", err
+		}
+
+		date := statusDate(request.Date)
+		payment.Status = constants.PaymentReversed
+		payment.ReversedAt = &date
+		payment.Reason = reason
+		return constants.PaymentActionReversed, nil
+	})
+}
+
+// GetPaymentEvents returns the audit trail of the payments on an invoice,
+// oldest first
+func (s *PaymentService) GetPaymentEvents(invoiceID uint, userID uint, isAdmin bool) ([]models.PaymentEvent, error) {
+	if _, err := s.invoiceService.GetInvoice(invoiceID, userID, isAdmin); err != nil {
+		return nil, err
+	}
+
+	var events []models.PaymentEvent
+	if err := database.GetDB().Preload("PerformedBy", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id, name, email")
+	}).Where("invoice_id = ?", invoiceID).Order("id").Find(&events).Error; err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// changeStatus applies a change to a payment with its invoice locked, then
+// records it in the audit trail with reason and recomputes what is paid on the
+// invoice. Only the invoice's seller (or an admin) may change payments.
+func (s *PaymentService) changeStatus(id uint, reason string, userID uint, isAdmin bool, change func(tx *gorm.DB, payment *models.Payment) (string, error)) (*models.Payment, error) {
+	var payment models.Payment
+	if err := database.GetDB().First(&payment, id).Error; err != nil {
+		return nil, errors.New("payment not found")
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		invoice, err := s.invoiceService.lockInvoice(tx, payment.InvoiceID, userID, isAdmin)
+		if err != nil {
+			return errors.New("payment not found")
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, id).Error; err != nil {
+			return err
+		}
+
+		from := payment.Status
+		action, err := change(tx, &payment)
+		if err != nil {
+			return err
+		}
+		if err := tx.Save(&payment).Error; err != nil {
+			return fmt.Errorf("failed to update payment: %w", err)
+		}
+		if err := recordPaymentEvent(tx, &payment, action, from, reason, userID); err != nil {
+			return err
+		}
+		if payment.Status == constants.PaymentBounced || payment.Status == constants.PaymentReversed {
+			if err := reverseCredit(tx, invoice, &payment, userID); err != nil {
+				return err
+			}
+		}
+		return updateInvoicePaymentStatus(tx, invoice.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &payment, nil
+}
+
+// checkReversible refuses to reverse a payment if refunds on its invoice
+// would then leave less than nothing paid
+func checkReversible(tx *gorm.DB, payment *models.Payment) error {
+	var totalPaid money.Amount
+	if err := activePayments(tx, payment.InvoiceID).
+		Select("COALESCE(SUM(amount), 0)").Row().Scan(&totalPaid); err != nil {
+		return err
+	}
+	if totalPaid-payment.Amount < 0 {
+		return errors.New("payment has been refunded; reverse the refund first")
+	}
+	return nil
+}
+
+// activePayments selects the payments and refunds of an invoice that count
+// towards its amount paid
+func activePayments(tx *gorm.DB, invoiceID uint) *gorm.DB {
+	return tx.Model(&models.Payment{}).
+		Where("invoice_id = ? AND status NOT IN ?", invoiceID, constants.InactivePaymentStatuses)
+}
+
+// recordPaymentEvent adds an entry to a payment's audit trail. It must run
+// in the transaction that made the change.
+func recordPaymentEvent(tx *gorm.DB, payment *models.Payment, action, from, reason string, userID uint) error {
+	event := models.PaymentEvent{
+		PaymentID:     payment.ID,
+		InvoiceID:     payment.InvoiceID,
+		Action:        action,
+		FromStatus:    from,
+		ToStatus:      payment.Status,
+		Amount:        payment.Amount,
+		Reason:        reason,
+		PerformedByID: userID,
+	}
+	if err := tx.Create(&event).Error; err != nil {
+		return fmt.Errorf("failed to record payment event: %w", err)
+	}
+	return nil
+}
+
+// statusDate returns the date a payment's status changed, defaulting to now
+func statusDate(date *time.Time) time.Time {
+	if date == nil || date.IsZero() {
+		return time.Now()
+	}
+	return *date
+}
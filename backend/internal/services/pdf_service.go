@@ -0,0 +1,612 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"invoice-generator/internal/constants"
+	"invoice-generator/internal/gst"
+	"invoice-generator/internal/models"
+	"invoice-generator/internal/money"
+	"invoice-generator/internal/pdf"
+	"invoice-generator/internal/qr"
+)
+
+// PDFService renders stored invoices as PDF documents
+type PDFService struct{}
+
+// NewPDFService creates a new PDF service
+func NewPDFService() *PDFService {
+	return &PDFService{}
+}
+
+// Page layout in points
+const (
+	pdfMargin       = 40.0
+	pdfContentRight = pdf.A4Width - pdfMargin
+	pdfContentWidth = pdfContentRight - pdfMargin
+	pdfPageBottom   = pdf.A4Height - 60
+	pdfRowHeight    = 12.0
+	pdfBodySize     = 8.5
+	pdfSmallSize    = 7.5
+	pdfQRSize       = 120.0 // Largest side of a printed QR code
+	pdfUPIQRSize    = 90.0  // Largest side of the UPI payment QR code
+)
+
+// pdfColumn describes one column of the line item table
+type pdfColumn struct {
+	title string
+	x     float64 // left edge
+	width float64
+	right bool // right-align values
+}
+
+var invoiceColumns = []pdfColumn{
+	{title: "#", x: pdfMargin, width: 18},
+	{title: "Description", x: pdfMargin + 18, width: 122},
+	{title: "HSN/SAC", x: pdfMargin + 140, width: 48},
+	{title: "Qty", x: pdfMargin + 188, width: 36, right: true},
+	{title: "Rate", x: pdfMargin + 224, width: 52, right: true},
+	{title: "Disc.", x: pdfMargin + 276, width: 46, right: true},
+	{title: "Taxable", x: pdfMargin + 322, width: 58, right: true},
+	{title: "GST %", x: pdfMargin + 380, width: 30, right: true},
+	{title: "GST Amt", x: pdfMargin + 410, width: 48, right: true},
+	{title: "Total", x: pdfMargin + 458, width: pdfContentWidth - 458, right: true},
+}
+
+// invoiceRenderer keeps the drawing state while laying out an invoice
+type invoiceRenderer struct {
+	doc     *pdf.Document
+	pages   []*pdf.Page
+	page    *pdf.Page
+	y       float64
+	invoice *models.Invoice
+}
+
+// RenderInvoice renders an invoice, with its parties, line items and
+// payments preloaded, as a GST tax invoice
+func (s *PDFService) RenderInvoice(invoice *models.Invoice) ([]byte, error) {
+	r := &invoiceRenderer{doc: pdf.New(), invoice: invoice}
+	r.newPage()
+
+	r.drawHeader()
+	r.drawEInvoice()
+	r.drawParties()
+	r.drawLineItems()
+	r.drawTotals()
+	r.drawUPI()
+	r.drawNotes()
+	r.drawSignature()
+	r.drawFooters()
+
+	return r.doc.Bytes()
+}
+
+// newPage starts a new page and resets the cursor to the top margin
+func (r *invoiceRenderer) newPage() {
+	r.page = r.doc.AddPage()
+	r.pages = append(r.pages, r.page)
+	r.y = pdfMargin
+}
+
+// ensureSpace moves to a new page when fewer than height points remain
+func (r *invoiceRenderer) ensureSpace(height float64) bool {
+	if r.y+height <= pdfPageBottom {
+		return false
+	}
+	r.newPage()
+	return true
+}
+
+func (r *invoiceRenderer) drawHeader() {
+	p := r.page
+	p.TextCenter(pdf.A4Width/2, r.y+12, pdf.HelveticaBold, 16, "TAX INVOICE")
+	r.y += 18
+	if banner := statusBanner(r.invoice.Status); banner != "" {
+		p.TextCenter(pdf.A4Width/2, r.y+10, pdf.HelveticaBold, 10, banner)
+		r.y += 14
+	}
+	r.y += 12
+
+	seller := invoiceSeller(r.invoice)
+	top := r.y
+
+	// Seller on the left
+	p.Text(pdfMargin, r.y+10, pdf.HelveticaBold, 12, partyName(&seller))
+	r.y += 16
+	for _, line := range partyLines(&seller) {
+		p.Text(pdfMargin, r.y+9, pdf.Helvetica, pdfBodySize, line)
+		r.y += pdfRowHeight
+	}
+	left := r.y
+
+	// Invoice details on the right
+	r.y = top
+	number := r.invoice.InvoiceNumber
+	if number == "" {
+		number = "Not issued"
+	}
+	details := [][2]string{
+		{"Invoice No.", number},
+		{"Invoice Date", formatPDFDate(r.invoice.InvoiceDate)},
+		{"Due Date", formatPDFDate(r.invoice.DueDate)},
+		{"Invoice Type", r.invoice.InvoiceType},
+		{"Place of Supply", placeOfSupplyLabel(r.invoice.PlaceOfSupply)},
+		{"Payment Status", r.invoice.PaymentStatus},
+	}
+	for _, d := range details {
+		p.Text(360, r.y+10, pdf.HelveticaBold, pdfBodySize, d[0])
+		p.TextRight(pdfContentRight, r.y+10, pdf.Helvetica, pdfBodySize, d[1])
+		r.y += pdfRowHeight
+	}
+
+	r.y = math.Max(left, r.y) + 8
+	p.Line(pdfMargin, r.y, pdfContentRight, r.y, 0.75)
+	r.y += 10
+}
+
+// drawEInvoice prints the IRN, acknowledgement and signed QR code of an
+// invoice registered with the IRP, unless the IRN has been cancelled
+func (r *invoiceRenderer) drawEInvoice() {
+	if r.invoice.IRN == "" || r.invoice.IRNCancelledAt != nil {
+		return
+	}
+	p := r.page
+	top := r.y
+
+	// QR code on the right, sized to a whole number of quarter points per
+	// module so that adjacent modules meet without gaps
+	if code, err := qr.Encode(r.invoice.SignedQRCode, qr.Medium); err == nil && r.invoice.SignedQRCode != "" {
+		module := math.Floor(pdfQRSize/float64(code.Size)*4) / 4
+		side := module * float64(code.Size)
+		drawQRCode(p, code, pdfContentRight-side, top, module)
+		r.y = top + side
+	}
+
+	ackDate := ""
+	if r.invoice.AckDate != nil {
+		ackDate = r.invoice.AckDate.Format("02 Jan 2006 15:04")
+	}
+	y := top
+	p.Text(pdfMargin, y+10, pdf.HelveticaBold, 10, "e-Invoice")
+	y += 16
+	for _, d := range [][2]string{
+		{"IRN", r.invoice.IRN},
+		{"Ack No.", r.invoice.AckNo},
+		{"Ack Date", ackDate},
+	} {
+		p.Text(pdfMargin, y+9, pdf.HelveticaBold, pdfBodySize, d[0])
+		p.Text(pdfMargin+50, y+9, pdf.Helvetica, pdfBodySize, d[1])
+		y += pdfRowHeight
+	}
+
+	r.y = math.Max(r.y, y) + 8
+	p.Line(pdfMargin, r.y, pdfContentRight, r.y, 0.75)
+	r.y += 10
+}
+
+// drawQRCode draws the dark modules of a QR code with its top-left corner
+// at (x, y), merging each row's runs of dark modules into one rectangle
+func drawQRCode(p *pdf.Page, code *qr.Code, x, y, module float64) {
+	for row := 0; row < code.Size; row++ {
+		for col := 0; col < code.Size; {
+			if !code.Dark(col, row) {
+				col++
+				continue
+			}
+			start := col
+			for col < code.Size && code.Dark(col, row) {
+				col++
+			}
+			p.Rect(x+float64(start)*module, y+float64(row)*module, float64(col-start)*module, module, 0, true)
+		}
+	}
+}
+
+func (r *invoiceRenderer) drawParties() {
+	p := r.page
+	buyer := invoiceBuyer(r.invoice)
+	top := r.y
+
+	p.Text(pdfMargin, r.y+9, pdf.HelveticaBold, pdfBodySize, "Bill To")
+	r.y += 14
+	p.Text(pdfMargin, r.y+9, pdf.HelveticaBold, 10, partyName(&buyer))
+	r.y += 14
+	for _, line := range partyLines(&buyer) {
+		p.Text(pdfMargin, r.y+9, pdf.Helvetica, pdfBodySize, line)
+		r.y += pdfRowHeight
+	}
+
+	// Ship-to address on the right when goods go elsewhere
+	shipTo := r.invoice.ShipTo
+	if shipTo.Address != (models.Address{}) {
+		left := r.y
+		r.y = top
+		name := partyName(&shipTo)
+		if name == "" {
+			name = partyName(&buyer)
+		}
+		p.Text(300, r.y+9, pdf.HelveticaBold, pdfBodySize, "Ship To")
+		r.y += 14
+		p.Text(300, r.y+9, pdf.HelveticaBold, 10, name)
+		r.y += 14
+		for _, line := range partyLines(&shipTo) {
+			p.Text(300, r.y+9, pdf.Helvetica, pdfBodySize, line)
+			r.y += pdfRowHeight
+		}
+		r.y = math.Max(left, r.y)
+	}
+	r.y += 10
+}
+
+func (r *invoiceRenderer) drawTableHeader() {
+	p := r.page
+	p.SetFillGray(0.9)
+	p.Rect(pdfMargin, r.y, pdfContentWidth, 16, 0, true)
+	p.SetFillGray(0)
+	for _, col := range invoiceColumns {
+		r.cell(col, r.y+11, pdf.HelveticaBold, col.title)
+	}
+	r.y += 20
+}
+
+func (r *invoiceRenderer) drawLineItems() {
+	r.drawTableHeader()
+
+	for i, line := range r.invoice.LineItems {
+		description := pdf.WrapText(pdf.Helvetica, pdfBodySize, line.Description, invoiceColumns[1].width-4)
+		height := float64(len(description)) * pdfRowHeight
+		if r.ensureSpace(height + 4) {
+			r.drawTableHeader()
+		}
+
+		discount := "-"
+		if d := line.DiscountAmount + line.InvoiceDiscountAmount; d > 0 {
+			discount = formatINR(d)
+		}
+
+		values := []string{
+			fmt.Sprintf("%d", i+1),
+			"",
+			lineHSNCode(&line),
+			line.Quantity.String(),
+			formatINR(line.Rate),
+			discount,
+			formatINR(line.Amount),
+			fmt.Sprintf("%d%%", line.GSTRate),
+			formatINR(line.GSTAmount),
+			formatINR(line.TotalAmount),
+		}
+		for c, col := range invoiceColumns {
+			if c == 1 {
+				for l, text := range description {
+					r.cell(col, r.y+9+float64(l)*pdfRowHeight, pdf.Helvetica, text)
+				}
+				continue
+			}
+			r.cell(col, r.y+9, pdf.Helvetica, values[c])
+		}
+
+		r.y += height + 2
+		r.page.SetStrokeGray(0.8)
+		r.page.Line(pdfMargin, r.y, pdfContentRight, r.y, 0.5)
+		r.page.SetStrokeGray(0)
+		r.y += 4
+	}
+}
+
+// cell draws text inside a table column, honouring its alignment
+func (r *invoiceRenderer) cell(col pdfColumn, y float64, font pdf.Font, text string) {
+	if col.right {
+		r.page.TextRight(col.x+col.width-2, y, font, pdfBodySize, text)
+		return
+	}
+	r.page.Text(col.x+2, y, font, pdfBodySize, text)
+}
+
+func (r *invoiceRenderer) drawTotals() {
+	inv := r.invoice
+	totals := [][2]string{{"Sub Total", formatINR(inv.SubTotal)}}
+	if inv.TotalDiscount > 0 {
+		totals = [][2]string{
+			{"Gross Amount", formatINR(inv.GrossAmount)},
+			{"Less: Discount", formatINR(inv.TotalDiscount)},
+			{"Taxable Value", formatINR(inv.SubTotal)},
+		}
+	}
+	if inv.SupplyType == constants.SupplyTypeInterState {
+		totals = append(totals, [2]string{"IGST", formatINR(inv.TotalIGST)})
+	} else {
+		totals = append(totals,
+			[2]string{"CGST", formatINR(inv.TotalCGST)},
+			[2]string{"SGST", formatINR(inv.TotalSGST)})
+	}
+	taxRows := len(totals)
+	totals = append(totals,
+		[2]string{"Total", formatINR(inv.TotalAmount)},
+		[2]string{"Amount Paid", formatINR(inv.AmountPaid)},
+		[2]string{"Amount Due", formatINR(inv.AmountDue)})
+
+	words := pdf.WrapText(pdf.Helvetica, pdfBodySize, amountInWords(inv.TotalAmount), 300)
+	r.ensureSpace(math.Max(float64(len(totals)), float64(len(words)+1))*pdfRowHeight + 10)
+	r.y += 6
+
+	top := r.y
+	p := r.page
+	p.Text(pdfMargin, r.y+9, pdf.HelveticaBold, pdfBodySize, "Amount in words")
+	for i, line := range words {
+		p.Text(pdfMargin, r.y+9+float64(i+1)*pdfRowHeight, pdf.Helvetica, pdfBodySize, line)
+	}
+
+	for i, t := range totals {
+		font := pdf.Helvetica
+		if t[0] == "Total" || t[0] == "Amount Due" {
+			font = pdf.HelveticaBold
+		}
+		p.Text(400, r.y+9, font, pdfBodySize, t[0])
+		p.TextRight(pdfContentRight, r.y+9, font, pdfBodySize, "Rs. "+t[1])
+		r.y += pdfRowHeight
+		if i == taxRows-1 {
+			p.Line(400, r.y, pdfContentRight, r.y, 0.5)
+			r.y += 2
+		}
+	}
+
+	r.y = math.Max(r.y, top+float64(len(words)+1)*pdfRowHeight) + 12
+}
+
+// drawUPI prints a QR code the buyer can scan with any UPI app to pay the
+// amount due, when the seller has a UPI ID
+func (r *invoiceRenderer) drawUPI() {
+	payment, err := invoiceUPIPayment(r.invoice)
+	if err != nil {
+		return
+	}
+	code, err := qr.Encode(payment.URI(), qr.Medium)
+	if err != nil {
+		return
+	}
+	module := math.Floor(pdfUPIQRSize/float64(code.Size)*4) / 4
+	side := module * float64(code.Size)
+	r.ensureSpace(side + 10)
+
+	p := r.page
+	top := r.y
+	drawQRCode(p, code, pdfMargin, top, module)
+
+	x := pdfMargin + side + 12
+	y := top
+	p.Text(x, y+10, pdf.HelveticaBold, 10, "Pay by UPI")
+	y += 16
+	for _, d := range [][2]string{
+		{"UPI ID", payment.VPA},
+		{"Amount", "Rs. " + formatINR(payment.Amount)},
+	} {
+		p.Text(x, y+9, pdf.HelveticaBold, pdfBodySize, d[0])
+		p.Text(x+50, y+9, pdf.Helvetica, pdfBodySize, d[1])
+		y += pdfRowHeight
+	}
+	p.Text(x, y+9, pdf.Helvetica, pdfSmallSize, "Scan with any UPI app to pay the amount due")
+
+	r.y = top + side + 12
+}
+
+func (r *invoiceRenderer) drawNotes() {
+	sections := [][2]string{
+		{"Notes", r.invoice.Notes},
+		{"Terms & Conditions", r.invoice.Terms},
+	}
+	for _, section := range sections {
+		if strings.TrimSpace(section[1]) == "" {
+			continue
+		}
+		lines := pdf.WrapText(pdf.Helvetica, pdfBodySize, section[1], pdfContentWidth)
+		r.ensureSpace(pdfRowHeight * 2)
+		r.page.Text(pdfMargin, r.y+9, pdf.HelveticaBold, pdfBodySize, section[0])
+		r.y += pdfRowHeight
+		for _, line := range lines {
+			r.ensureSpace(pdfRowHeight)
+			r.page.Text(pdfMargin, r.y+9, pdf.Helvetica, pdfBodySize, line)
+			r.y += pdfRowHeight
+		}
+		r.y += 8
+	}
+}
+
+func (r *invoiceRenderer) drawSignature() {
+	seller := invoiceSeller(r.invoice)
+	r.ensureSpace(50)
+	r.y += 10
+	r.page.TextRight(pdfContentRight, r.y+9, pdf.HelveticaBold, pdfBodySize, "For "+partyName(&seller))
+	r.y += 40
+	r.page.TextRight(pdfContentRight, r.y+9, pdf.Helvetica, pdfBodySize, "Authorised Signatory")
+	r.y += pdfRowHeight
+}
+
+func (r *invoiceRenderer) drawFooters() {
+	for i, p := range r.pages {
+		y := pdf.A4Height - 30
+		p.SetStrokeGray(0.8)
+		p.Line(pdfMargin, y-12, pdfContentRight, y-12, 0.5)
+		p.SetStrokeGray(0)
+		p.Text(pdfMargin, y, pdf.Helvetica, pdfSmallSize, "This is a computer generated invoice.")
+		p.TextRight(pdfContentRight, y, pdf.Helvetica, pdfSmallSize,
+			fmt.Sprintf("%s - Page %d of %d", r.invoice.InvoiceNumber, i+1, len(r.pages)))
+	}
+}
+
+// statusBanner returns the notice printed under the title for invoices
+// that are not in force
+func statusBanner(status string) string {
+	switch status {
+	case constants.InvoiceStatusDraft:
+		return "DRAFT - NOT VALID FOR TAX PURPOSES"
+	case constants.InvoiceStatusVoid:
+		return "VOID"
+	case constants.InvoiceStatusCancelled:
+		return "CANCELLED"
+	}
+	return ""
+}
+
+// invoiceSeller returns the seller as shown on an invoice: as at issue
+// once the invoice has been issued, otherwise as currently registered
+func invoiceSeller(invoice *models.Invoice) models.PartySnapshot {
+	if invoice.Seller.Name != "" {
+		return invoice.Seller
+	}
+	return snapshotParty(userAsParty(&invoice.GeneratedBy))
+}
+
+// invoiceBuyer returns the buyer as shown on an invoice: as at issue once
+// the invoice has been issued, otherwise the party or user's current details
+func invoiceBuyer(invoice *models.Invoice) models.PartySnapshot {
+	switch {
+	case invoice.Buyer.Name != "":
+		return invoice.Buyer
+	case invoice.Party != nil:
+		return snapshotParty(invoice.Party)
+	case invoice.GeneratedFor != nil:
+		return snapshotParty(userAsParty(invoice.GeneratedFor))
+	}
+	return models.PartySnapshot{}
+}
+
+// partyName returns the name printed for an invoice party
+func partyName(p *models.PartySnapshot) string {
+	if p.CompanyName != "" {
+		return p.CompanyName
+	}
+	return p.Name
+}
+
+// partyLines returns the address and tax registration lines for a party
+func partyLines(p *models.PartySnapshot) []string {
+	var lines []string
+	if p.CompanyName != "" && p.Name != "" && p.Name != p.CompanyName {
+		lines = append(lines, p.Name)
+	}
+	if p.Address.Address != "" {
+		lines = append(lines, pdf.WrapText(pdf.Helvetica, pdfBodySize, p.Address.Address, 240)...)
+	}
+
+	var place []string
+	for _, part := range []string{p.City, p.State} {
+		if part != "" {
+			place = append(place, part)
+		}
+	}
+	locality := strings.Join(place, ", ")
+	if p.Pincode != "" {
+		locality = strings.TrimSpace(locality + " - " + p.Pincode)
+	}
+	if locality != "" {
+		lines = append(lines, locality)
+	}
+
+	if p.GSTIN != "" {
+		lines = append(lines, "GSTIN: "+p.GSTIN)
+	}
+	if p.Phone != "" {
+		lines = append(lines, "Phone: "+p.Phone)
+	}
+	if p.Email != "" {
+		lines = append(lines, "Email: "+p.Email)
+	}
+	return lines
+}
+
+// placeOfSupplyLabel prints a state code together with its state name
+func placeOfSupplyLabel(code string) string {
+	if name := gst.StateName(code); name != "" {
+		return code + " - " + name
+	}
+	return code
+}
+
+// formatINR formats an amount with two decimals using Indian digit grouping
+// (e.g. 12,34,567.89)
+func formatINR(amount money.Amount) string {
+	s := amount.Abs().String()
+	whole, fraction := s[:len(s)-3], s[len(s)-2:]
+
+	if len(whole) > 3 {
+		head, tail := whole[:len(whole)-3], whole[len(whole)-3:]
+		var groups []string
+		for len(head) > 2 {
+			groups = append([]string{head[len(head)-2:]}, groups...)
+			head = head[:len(head)-2]
+		}
+		if head != "" {
+			groups = append([]string{head}, groups...)
+		}
+		whole = strings.Join(groups, ",") + "," + tail
+	}
+
+	if amount.IsNegative() {
+		return "-" + whole + "." + fraction
+	}
+	return whole + "." + fraction
+}
+
+// formatPDFDate formats a date as printed on documents
+func formatPDFDate(t time.Time) string {
+	return t.Format("02 Jan 2006")
+}
+
+var (
+	wordsOnes = []string{"", "One", "Two", "Three", "Four", "Five", "Six", "Seven", "Eight", "Nine",
+		"Ten", "Eleven", "Twelve", "Thirteen", "Fourteen", "Fifteen", "Sixteen", "Seventeen", "Eighteen", "Nineteen"}
+	wordsTens = []string{"", "", "Twenty", "Thirty", "Forty", "Fifty", "Sixty", "Seventy", "Eighty", "Ninety"}
+)
+
+// amountInWords spells out an amount in rupees and paise using the Indian
+// numbering system (lakh, crore)
+func amountInWords(amount money.Amount) string {
+	paiseTotal := amount.Abs().Paise()
+	rupees, paise := paiseTotal/100, paiseTotal%100
+
+	words := "Rupees " + integerInWords(rupees)
+	if paise > 0 {
+		words += " and " + integerInWords(paise) + " Paise"
+	}
+	return words + " Only"
+}
+
+// integerInWords spells out a non-negative integer in the Indian system
+func integerInWords(n int64) string {
+	if n == 0 {
+		return "Zero"
+	}
+
+	var parts []string
+	if n >= 10000000 {
+		parts = append(parts, integerInWords(n/10000000)+" Crore")
+		n %= 10000000
+	}
+	for _, unit := range []struct {
+		value int64
+		name  string
+	}{{100000, "Lakh"}, {1000, "Thousand"}, {100, "Hundred"}} {
+		if n >= unit.value {
+			parts = append(parts, belowHundredInWords(n/unit.value)+" "+unit.name)
+			n %= unit.value
+		}
+	}
+	if n > 0 {
+		parts = append(parts, belowHundredInWords(n))
+	}
+	return strings.Join(parts, " ")
+}
+
+func belowHundredInWords(n int64) string {
+	if n < 20 {
+		return wordsOnes[n]
+	}
+	if n%10 == 0 {
+		return wordsTens[n/10]
+	}
+	return wordsTens[n/10] + " " + wordsOnes[n%10]
+}
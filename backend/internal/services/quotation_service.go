@@ -0,0 +1,516 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"invoice-generator/internal/constants"
+	"invoice-generator/internal/database"
+	"invoice-generator/internal/gst"
+	"invoice-generator/internal/models"
+	"invoice-generator/internal/money"
+	"invoice-generator/internal/recurrence"
+)
+
+// QuotationService handles quotation business logic and conversion of
+// quotations into invoices
+type QuotationService struct {
+	invoiceService *InvoiceService
+}
+
+// NewQuotationService creates a new quotation service
+func NewQuotationService(invoiceService *InvoiceService) *QuotationService {
+	return &QuotationService{invoiceService: invoiceService}
+}
+
+// CreateQuotation numbers and stores a new quotation
+func (s *QuotationService) CreateQuotation(quotation *models.Quotation, userID uint) error {
+	if len(quotation.LineItems) == 0 {
+		return errors.New("quotation must have at least one line item")
+	}
+
+	quotation.ID = 0
+	quotation.GeneratedByID = userID
+	quotation.Status = constants.QuotationStatusOpen
+	quotation.AcceptedAt = nil
+	quotation.RejectedAt = nil
+	quotation.RejectionReason = ""
+
+	if quotation.QuotationDate.IsZero() {
+		quotation.QuotationDate = time.Now()
+	}
+	if quotation.ValidUntil.IsZero() {
+		quotation.ValidUntil = quotation.QuotationDate.AddDate(0, 0, constants.DefaultQuotationValidityDays)
+	}
+	quotation.ValidUntil = recurrence.Date(quotation.ValidUntil)
+	if quotation.ValidUntil.Before(recurrence.Date(quotation.QuotationDate)) {
+		return errors.New("valid until date cannot be before the quotation date")
+	}
+
+	for i := range quotation.LineItems {
+		line := &quotation.LineItems[i]
+		line.ID = 0
+		line.InvoicedQuantity = 0
+		if strings.TrimSpace(line.Description) == "" {
+			return fmt.Errorf("line %d: description is required", i+1)
+		}
+		if line.Quantity <= 0 {
+			return fmt.Errorf("line %d: quantity must be positive", i+1)
+		}
+		if line.Rate < 0 {
+			return fmt.Errorf("line %d: rate cannot be negative", i+1)
+		}
+		if err := checkLineHSNCode(i, &line.HSNCode); err != nil {
+			return err
+		}
+	}
+
+	grosses := make([]money.Amount, len(quotation.LineItems))
+	lineDiscounts := make([]models.Discount, len(quotation.LineItems))
+	for i, line := range quotation.LineItems {
+		grosses[i] = line.Quantity.MulAmount(line.Rate)
+		lineDiscounts[i] = line.Discount
+	}
+	if err := validateDiscounts(grosses, lineDiscounts, quotation.Discount); err != nil {
+		return err
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		buyer, err := resolveBuyer(tx, quotation.GeneratedByID, quotation.GeneratedForID, quotation.PartyID)
+		if err != nil {
+			return err
+		}
+		placeOfSupply, supplyType, err := resolvePlaceOfSupply(tx, quotation.GeneratedByID, buyer, gst.ResolveStateCode("", buyer.ShippingAddress.State), quotation.PlaceOfSupply)
+		if err != nil {
+			return err
+		}
+		quotation.PlaceOfSupply = placeOfSupply
+		quotation.SupplyType = supplyType
+		calculateQuotationTotals(quotation)
+
+		number, err := allocateDocumentNumber(tx, quotation.GeneratedByID, constants.DocumentTypeQuotation, quotation.Series, quotation.QuotationDate)
+		if err != nil {
+			return err
+		}
+		quotation.QuotationNumber = number.Number
+		quotation.SeriesID = &number.SeriesID
+		quotation.SequenceNumber = number.Sequence
+		quotation.FinancialYear = number.FinancialYear
+
+		return tx.Omit("GeneratedBy", "GeneratedFor", "Party").Create(quotation).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create quotation: %w", err)
+	}
+
+	database.GetDB().Preload("GeneratedBy").Preload("GeneratedFor").Preload("Party").
+		Preload("LineItems.Item").First(quotation, quotation.ID)
+	return nil
+}
+
+// GetQuotations returns quotations with pagination and access control
+func (s *QuotationService) GetQuotations(userID uint, isAdmin bool, status string, page, limit int) ([]models.Quotation, int64, error) {
+	if err := expireQuotations(database.GetDB()); err != nil {
+		return nil, 0, err
+	}
+
+	var quotations []models.Quotation
+	query := database.GetDB().Preload("GeneratedBy").Preload("GeneratedFor").Preload("Party").Preload("LineItems")
+
+	if !isAdmin {
+		query = query.Where("generated_by_id = ? OR generated_for_id = ?", userID, userID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	query.Model(&models.Quotation{}).Count(&total)
+
+	offset := (page - 1) * limit
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&quotations).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return quotations, total, nil
+}
+
+// GetQuotation returns a single quotation with the invoices converted from it
+func (s *QuotationService) GetQuotation(id uint, userID uint, isAdmin bool) (*models.Quotation, error) {
+	if err := expireQuotations(database.GetDB()); err != nil {
+		return nil, err
+	}
+
+	var quotation models.Quotation
+	query := database.GetDB().Preload("GeneratedBy").Preload("GeneratedFor").Preload("Party").
+		Preload("LineItems.Item").Preload("Invoices")
+
+	if !isAdmin {
+		query = query.Where("generated_by_id = ? OR generated_for_id = ?", userID, userID)
+	}
+
+	if err := query.First(&quotation, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("quotation not found")
+		}
+		return nil, err
+	}
+
+	return &quotation, nil
+}
+
+// AcceptQuotation marks an open quotation as accepted. The seller or the
+// buyer may accept.
+func (s *QuotationService) AcceptQuotation(id uint, userID uint, isAdmin bool) (*models.Quotation, error) {
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		quotation, err := s.lockQuotation(tx, id, userID, isAdmin, true)
+		if err != nil {
+			return err
+		}
+		if quotation.Status != constants.QuotationStatusOpen {
+			return fmt.Errorf("only open quotations can be accepted (status is %s)", quotation.Status)
+		}
+
+		now := time.Now()
+		return tx.Model(quotation).Updates(map[string]interface{}{
+			"status":      constants.QuotationStatusAccepted,
+			"accepted_at": now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetQuotation(id, userID, isAdmin)
+}
+
+// RejectQuotation marks an open or accepted quotation as rejected. The
+// seller or the buyer may reject; quotations already partly invoiced cannot
+// be rejected.
+func (s *QuotationService) RejectQuotation(id uint, reason string, userID uint, isAdmin bool) (*models.Quotation, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, errors.New("reason is required")
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		quotation, err := s.lockQuotation(tx, id, userID, isAdmin, true)
+		if err != nil {
+			return err
+		}
+		if quotation.Status != constants.QuotationStatusOpen && quotation.Status != constants.QuotationStatusAccepted {
+			return fmt.Errorf("quotation cannot be rejected (status is %s)", quotation.Status)
+		}
+
+		var invoiced int64
+		tx.Model(&models.QuotationLineItem{}).Where("quotation_id = ? AND invoiced_quantity > 0", id).Count(&invoiced)
+		if invoiced > 0 {
+			return errors.New("cannot reject a quotation that has been invoiced")
+		}
+
+		now := time.Now()
+		return tx.Model(quotation).Updates(map[string]interface{}{
+			"status":           constants.QuotationStatusRejected,
+			"rejected_at":      now,
+			"rejection_reason": reason,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetQuotation(id, userID, isAdmin)
+}
+
+// ConvertToInvoice creates an invoice from an open or accepted quotation.
+// Without lines in the request every line is invoiced for its remaining
+// quantity; otherwise only the given quantities are invoiced and the rest
+// can be converted later. Converting accepts the quotation, and once every
+// line is fully invoiced the quotation is marked as converted.
+func (s *QuotationService) ConvertToInvoice(id uint, request *models.QuotationConversionRequest, userID uint, isAdmin bool) (*models.Invoice, error) {
+	var invoice *models.Invoice
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		quotation, err := s.lockQuotation(tx, id, userID, isAdmin, false)
+		if err != nil {
+			return err
+		}
+		if quotation.Status != constants.QuotationStatusOpen && quotation.Status != constants.QuotationStatusAccepted {
+			return fmt.Errorf("quotation cannot be converted (status is %s)", quotation.Status)
+		}
+		if err := tx.Where("quotation_id = ?", quotation.ID).Order("id").Find(&quotation.LineItems).Error; err != nil {
+			return err
+		}
+
+		invoice, err = buildQuotationInvoice(quotation, request)
+		if err != nil {
+			return err
+		}
+		if err := s.invoiceService.createInvoice(tx, invoice, quotation.GeneratedByID); err != nil {
+			return err
+		}
+
+		if quotation.Status == constants.QuotationStatusOpen {
+			now := time.Now()
+			if err := tx.Model(quotation).Updates(map[string]interface{}{
+				"status":      constants.QuotationStatusAccepted,
+				"accepted_at": now,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		return syncQuotationProgress(tx, quotation.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.invoiceService.GetInvoice(invoice.ID, userID, isAdmin)
+}
+
+// lockQuotation loads a quotation for update. The seller always has
+// access; the buyer only when allowBuyer is set. Open quotations past their
+// validity date are expired first.
+func (s *QuotationService) lockQuotation(tx *gorm.DB, id uint, userID uint, isAdmin, allowBuyer bool) (*models.Quotation, error) {
+	var quotation models.Quotation
+	query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id)
+	if !isAdmin {
+		if allowBuyer {
+			query = query.Where("generated_by_id = ? OR generated_for_id = ?", userID, userID)
+		} else {
+			query = query.Where("generated_by_id = ?", userID)
+		}
+	}
+	if err := query.First(&quotation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("quotation not found")
+		}
+		return nil, err
+	}
+
+	if quotation.Status == constants.QuotationStatusOpen && isQuotationExpired(&quotation) {
+		if err := tx.Model(&quotation).Update("status", constants.QuotationStatusExpired).Error; err != nil {
+			return nil, err
+		}
+		quotation.Status = constants.QuotationStatusExpired
+	}
+	return &quotation, nil
+}
+
+// buildQuotationInvoice creates the invoice for the requested quotation
+// lines and quantities
+func buildQuotationInvoice(quotation *models.Quotation, request *models.QuotationConversionRequest) (*models.Invoice, error) {
+	invoiceType := request.InvoiceType
+	if invoiceType == "" {
+		invoiceType = constants.InvoiceTypeCredit
+	}
+	isValidType := false
+	for _, t := range constants.ValidInvoiceTypes {
+		if invoiceType == t {
+			isValidType = true
+			break
+		}
+	}
+	if !isValidType {
+		return nil, errors.New("invalid invoice type")
+	}
+
+	quotationID := quotation.ID
+	invoice := &models.Invoice{
+		GeneratedForID: quotation.GeneratedForID,
+		PartyID:        quotation.PartyID,
+		QuotationID:    &quotationID,
+		InvoiceType:    invoiceType,
+		PlaceOfSupply:  quotation.PlaceOfSupply,
+		Series:         request.Series,
+		InvoiceDate:    request.InvoiceDate,
+		DueDate:        request.DueDate,
+		Notes:          quotation.Notes,
+		Terms:          quotation.Terms,
+		Issue:          request.Issue,
+	}
+
+	lines := make(map[uint]models.QuotationLineItem, len(quotation.LineItems))
+	for _, line := range quotation.LineItems {
+		lines[line.ID] = line
+	}
+
+	// Flat discounts are carried over in proportion to the part invoiced
+	var quotedNet, invoicedNet money.Amount
+	addLine := func(line models.QuotationLineItem, quantity money.Quantity) {
+		lineID := line.ID
+		discount := line.Discount
+		if discount.DiscountType == constants.DiscountTypeFlat {
+			discount.DiscountValue = discount.DiscountValue.Prorate(quantity, line.Quantity)
+		}
+		invoice.LineItems = append(invoice.LineItems, models.InvoiceLineItem{
+			ItemID:              line.ItemID,
+			Description:         line.Description,
+			HSNCode:             line.HSNCode,
+			Quantity:            quantity,
+			Rate:                line.Rate,
+			GSTRate:             line.GSTRate,
+			Discount:            discount,
+			QuotationLineItemID: &lineID,
+		})
+		invoicedNet += (line.GrossAmount - line.DiscountAmount).Prorate(quantity, line.Quantity)
+	}
+	for _, line := range quotation.LineItems {
+		quotedNet += line.GrossAmount - line.DiscountAmount
+	}
+
+	if len(request.Lines) == 0 {
+		for _, line := range quotation.LineItems {
+			if remaining := line.Quantity - line.InvoicedQuantity; remaining > 0 {
+				addLine(line, remaining)
+			}
+		}
+	} else {
+		requested := make(map[uint]bool, len(request.Lines))
+		for i, requestLine := range request.Lines {
+			line, ok := lines[requestLine.QuotationLineItemID]
+			if !ok {
+				return nil, fmt.Errorf("line %d: quotation line item not found on this quotation", i+1)
+			}
+			if requested[line.ID] {
+				return nil, fmt.Errorf("line %d: quotation line item listed more than once", i+1)
+			}
+			requested[line.ID] = true
+
+			remaining := line.Quantity - line.InvoicedQuantity
+			quantity := requestLine.Quantity
+			if quantity == 0 {
+				quantity = remaining
+			}
+			if quantity <= 0 {
+				return nil, fmt.Errorf("line %d: nothing left to invoice for %q", i+1, line.Description)
+			}
+			if quantity > remaining {
+				return nil, fmt.Errorf("line %d: only %s of %q remains to be invoiced", i+1, remaining, line.Description)
+			}
+			addLine(line, quantity)
+		}
+	}
+
+	if len(invoice.LineItems) == 0 {
+		return nil, errors.New("quotation has been fully invoiced")
+	}
+
+	invoice.Discount = quotation.Discount
+	if invoice.DiscountType == constants.DiscountTypeFlat {
+		invoice.DiscountValue = invoice.DiscountValue.Allocate(invoicedNet, quotedNet)
+	}
+	return invoice, nil
+}
+
+// calculateQuotationTotals computes quotation line and total amounts,
+// including discounts, using the same per-line rounding and GST split as
+// invoices
+func calculateQuotationTotals(quotation *models.Quotation) {
+	var subTotal, totalCGST, totalSGST, totalIGST money.Amount
+	interState := quotation.SupplyType == constants.SupplyTypeInterState
+
+	grosses := make([]money.Amount, len(quotation.LineItems))
+	lineDiscounts := make([]models.Discount, len(quotation.LineItems))
+	for i, line := range quotation.LineItems {
+		grosses[i] = line.Quantity.MulAmount(line.Rate)
+		lineDiscounts[i] = line.Discount
+	}
+	discounts, shares := applyDiscounts(grosses, lineDiscounts, quotation.Discount)
+
+	var grossAmount, lineDiscount, quotationDiscount money.Amount
+	for i := range quotation.LineItems {
+		line := &quotation.LineItems[i]
+		line.GrossAmount = grosses[i]
+		line.DiscountAmount = discounts[i]
+		line.InvoiceDiscountAmount = shares[i]
+		line.Amount = line.GrossAmount - line.DiscountAmount - line.InvoiceDiscountAmount
+		line.CGSTAmount, line.SGSTAmount, line.IGSTAmount = calculateLineTax(line.Amount, line.GSTRate, interState)
+		line.GSTAmount = line.CGSTAmount + line.SGSTAmount + line.IGSTAmount
+		line.TotalAmount = line.Amount + line.GSTAmount
+
+		grossAmount += line.GrossAmount
+		lineDiscount += line.DiscountAmount
+		quotationDiscount += line.InvoiceDiscountAmount
+		subTotal += line.Amount
+		totalCGST += line.CGSTAmount
+		totalSGST += line.SGSTAmount
+		totalIGST += line.IGSTAmount
+	}
+
+	quotation.GrossAmount = grossAmount
+	quotation.DiscountAmount = quotationDiscount
+	quotation.TotalDiscount = lineDiscount + quotationDiscount
+	quotation.SubTotal = subTotal
+	quotation.TotalCGST = totalCGST
+	quotation.TotalSGST = totalSGST
+	quotation.TotalIGST = totalIGST
+	quotation.TotalGST = totalCGST + totalSGST + totalIGST
+	quotation.TotalAmount = subTotal + quotation.TotalGST
+}
+
+// syncQuotationProgress recomputes how much of each quotation line has
+// been invoiced, counting only invoices that are not void or cancelled, and
+// moves the quotation between accepted and converted accordingly. It is
+// called whenever an invoice linked to a quotation is created, edited,
+// voided, cancelled or deleted.
+func syncQuotationProgress(tx *gorm.DB, quotationID uint) error {
+	var quotation models.Quotation
+	if err := tx.Preload("LineItems").First(&quotation, quotationID).Error; err != nil {
+		return err
+	}
+
+	fullyInvoiced := true
+	for _, line := range quotation.LineItems {
+		var invoiced money.Quantity
+		if err := tx.Model(&models.InvoiceLineItem{}).
+			Joins("JOIN invoices ON invoices.id = invoice_line_items.invoice_id").
+			Where("invoice_line_items.quotation_line_item_id = ? AND invoices.status IN ?", line.ID,
+				[]string{constants.InvoiceStatusDraft, constants.InvoiceStatusIssued}).
+			Select("COALESCE(SUM(invoice_line_items.quantity), 0)").Row().Scan(&invoiced); err != nil {
+			return err
+		}
+		if invoiced > line.Quantity {
+			return fmt.Errorf("invoiced quantity of %q exceeds the quoted quantity", line.Description)
+		}
+		if invoiced < line.Quantity {
+			fullyInvoiced = false
+		}
+		if invoiced != line.InvoicedQuantity {
+			if err := tx.Model(&line).Update("invoiced_quantity", invoiced).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	status := quotation.Status
+	switch {
+	case fullyInvoiced:
+		status = constants.QuotationStatusConverted
+	case quotation.Status == constants.QuotationStatusConverted:
+		status = constants.QuotationStatusAccepted
+	}
+	if status != quotation.Status {
+		return tx.Model(&quotation).Update("status", status).Error
+	}
+	return nil
+}
+
+// expireQuotations marks open quotations past their validity date as
+// expired
+func expireQuotations(db *gorm.DB) error {
+	today := recurrence.Date(time.Now())
+	return db.Model(&models.Quotation{}).
+		Where("status = ? AND valid_until < ?", constants.QuotationStatusOpen, today).
+		Update("status", constants.QuotationStatusExpired).Error
+}
+
+// isQuotationExpired reports whether a quotation's validity date has passed
+func isQuotationExpired(quotation *models.Quotation) bool {
+	return recurrence.Date(quotation.ValidUntil).Before(recurrence.Date(time.Now()))
+}
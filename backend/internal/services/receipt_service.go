@@ -0,0 +1,334 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"invoice-generator/internal/constants"
+	"invoice-generator/internal/database"
+	"invoice-generator/internal/models"
+	"invoice-generator/internal/money"
+)
+
+// reallocatedReason is recorded on the payments reversed when a receipt is
+// reallocated
+const reallocatedReason = "Receipt reallocated"
+
+// ReceiptService records lump-sum receipts and allocates them across a
+// buyer's invoices
+type ReceiptService struct {
+	invoiceService *InvoiceService
+}
+
+// NewReceiptService creates a new receipt service
+func NewReceiptService(invoiceService *InvoiceService) *ReceiptService {
+	return &ReceiptService{invoiceService: invoiceService}
+}
+
+// CreateReceipt records an amount received from a registered user or one of
+// the seller's parties and allocates it to their issued invoices, either as
+// given or automatically, oldest due first. Every invoice's amount paid
+// and payment status are updated in the same transaction. Any amount left
+// over stays unallocated on the receipt and, for a party, is added to its
+// credit balance to be applied to later invoices.
+func (s *ReceiptService) CreateReceipt(receipt *models.Receipt, userID uint) error {
+	allocations, auto := receipt.Allocations, receipt.AutoAllocate
+	receipt.ID = 0
+	receipt.SellerID = userID
+	receipt.CreatedByID = userID
+	receipt.Reference = strings.TrimSpace(receipt.Reference)
+	receipt.AllocatedAmount = 0
+	receipt.UnallocatedAmount = receipt.Amount
+	receipt.Payments = nil
+
+	if receipt.Amount <= 0 {
+		return errors.New("receipt amount must be positive")
+	}
+	switch receipt.PaymentMethod {
+	case constants.PaymentMethodCash, constants.PaymentMethodBankTransfer, constants.PaymentMethodUPI, constants.PaymentMethodCard:
+	case constants.PaymentMethodCheque:
+		return errors.New("record cheques against each invoice so that they can be deposited, cleared or bounced")
+	default:
+		return errors.New("payment method must be CASH, BANK_TRANSFER, UPI or CARD")
+	}
+	if receipt.ReceiptDate.IsZero() {
+		receipt.ReceiptDate = time.Now()
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if _, err := resolveBuyer(tx, userID, receipt.GeneratedForID, receipt.PartyID); err != nil {
+			return err
+		}
+		if err := tx.Omit("Party", "Payments").Create(receipt).Error; err != nil {
+			return fmt.Errorf("failed to create receipt: %w", err)
+		}
+		return s.allocate(tx, receipt, allocations, auto, userID)
+	})
+	if err != nil {
+		return err
+	}
+
+	loaded, err := s.GetReceipt(receipt.ID, userID, false)
+	if err != nil {
+		return err
+	}
+	*receipt = *loaded
+	return nil
+}
+
+// UpdateAllocations replaces the allocation of a receipt. The payments of
+// the current allocation are reversed, keeping them in the invoices'
+// payment history, and new payments are recorded for the new one. A
+// party's credit balance is adjusted to the new unallocated amount.
+func (s *ReceiptService) UpdateAllocations(id uint, request *models.AllocationRequest, userID uint, isAdmin bool) (*models.Receipt, error) {
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var receipt models.Receipt
+		query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id)
+		if !isAdmin {
+			query = query.Where("seller_id = ?", userID)
+		}
+		if err := query.First(&receipt).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("receipt not found")
+			}
+			return err
+		}
+
+		var payments []models.Payment
+		if err := tx.Where("receipt_id = ? AND status NOT IN ?", receipt.ID, constants.InactivePaymentStatuses).
+			Order("invoice_id").Find(&payments).Error; err != nil {
+			return err
+		}
+		if err := s.lockReallocatedInvoices(tx, &receipt, payments, request); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for i := range payments {
+			payment := &payments[i]
+			if err := checkReversible(tx, payment); err != nil {
+				return err
+			}
+			from := payment.Status
+			payment.Status = constants.PaymentReversed
+			payment.ReversedAt = &now
+			payment.Reason = reallocatedReason
+			if err := tx.Save(payment).Error; err != nil {
+				return fmt.Errorf("failed to update payment: %w", err)
+			}
+			if err := recordPaymentEvent(tx, payment, constants.PaymentActionReversed, from, reallocatedReason, userID); err != nil {
+				return err
+			}
+			if err := updateInvoicePaymentStatus(tx, payment.InvoiceID); err != nil {
+				return err
+			}
+		}
+
+		return s.allocate(tx, &receipt, request.Allocations, request.AutoAllocate, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetReceipt(id, userID, isAdmin)
+}
+
+// GetReceipts returns receipts with pagination and access control, newest
+// first
+func (s *ReceiptService) GetReceipts(userID uint, isAdmin bool, page, limit int) ([]models.Receipt, int64, error) {
+	var receipts []models.Receipt
+	query := database.GetDB().Preload("Party")
+
+	if !isAdmin {
+		query = query.Where("seller_id = ?", userID)
+	}
+
+	var total int64
+	query.Model(&models.Receipt{}).Count(&total)
+
+	offset := (page - 1) * limit
+	if err := query.Order("receipt_date DESC, id DESC").Limit(limit).Offset(offset).Find(&receipts).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return receipts, total, nil
+}
+
+// GetReceipt returns a single receipt with its payments
+func (s *ReceiptService) GetReceipt(id uint, userID uint, isAdmin bool) (*models.Receipt, error) {
+	var receipt models.Receipt
+	query := database.GetDB().Preload("Party").Preload("Payments", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	})
+	if !isAdmin {
+		query = query.Where("seller_id = ?", userID)
+	}
+
+	if err := query.First(&receipt, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("receipt not found")
+		}
+		return nil, err
+	}
+
+	return &receipt, nil
+}
+
+// allocate records a payment on each allocated invoice and updates the
+// receipt's allocated and unallocated amounts, crediting the unallocated
+// amount to a party. Invoices are locked in ID order so that concurrent
+// allocations cannot deadlock; a reallocation locks them all beforehand.
+func (s *ReceiptService) allocate(tx *gorm.DB, receipt *models.Receipt, allocations []models.ReceiptAllocation, auto bool, userID uint) error {
+	if auto {
+		if len(allocations) > 0 {
+			return errors.New("give allocations or auto_allocate, not both")
+		}
+		var err error
+		if allocations, err = s.autoAllocations(tx, receipt); err != nil {
+			return err
+		}
+	}
+
+	allocations = append([]models.ReceiptAllocation(nil), allocations...)
+	sort.Slice(allocations, func(i, j int) bool { return allocations[i].InvoiceID < allocations[j].InvoiceID })
+
+	var allocated money.Amount
+	for i, allocation := range allocations {
+		if i > 0 && allocation.InvoiceID == allocations[i-1].InvoiceID {
+			return fmt.Errorf("invoice %d is allocated more than once", allocation.InvoiceID)
+		}
+		if allocation.Amount <= 0 {
+			return fmt.Errorf("allocation to invoice %d must be positive", allocation.InvoiceID)
+		}
+
+		invoice, err := s.invoiceService.lockInvoice(tx, allocation.InvoiceID, receipt.SellerID, false)
+		if err != nil {
+			return fmt.Errorf("invoice %d not found", allocation.InvoiceID)
+		}
+		if invoice.Status != constants.InvoiceStatusIssued {
+			return fmt.Errorf("invoice %s is not issued", invoice.InvoiceNumber)
+		}
+		if !sameID(invoice.GeneratedForID, receipt.GeneratedForID) || !sameID(invoice.PartyID, receipt.PartyID) {
+			return fmt.Errorf("invoice %s is billed to a different buyer", invoice.InvoiceNumber)
+		}
+		if allocation.Amount > invoice.AmountDue {
+			return fmt.Errorf("allocation to invoice %s exceeds its amount due of %s", invoice.InvoiceNumber, invoice.AmountDue)
+		}
+
+		payment := models.Payment{
+			InvoiceID:     invoice.ID,
+			ReceiptID:     &receipt.ID,
+			PaymentType:   constants.PaymentTypeReceipt,
+			Amount:        allocation.Amount,
+			PaymentMethod: receipt.PaymentMethod,
+			PaymentDate:   receipt.ReceiptDate,
+			Reference:     receipt.Reference,
+			Notes:         receipt.Notes,
+			Status:        constants.PaymentCleared,
+		}
+		if err := tx.Create(&payment).Error; err != nil {
+			return fmt.Errorf("failed to add payment: %w", err)
+		}
+		if err := recordPaymentEvent(tx, &payment, constants.PaymentActionRecorded, "", "", userID); err != nil {
+			return err
+		}
+		if err := updateInvoicePaymentStatus(tx, invoice.ID); err != nil {
+			return err
+		}
+		allocated += allocation.Amount
+	}
+
+	if allocated > receipt.Amount {
+		return fmt.Errorf("allocations of %s exceed the receipt amount of %s", allocated, receipt.Amount)
+	}
+	receipt.AllocatedAmount = allocated
+	receipt.UnallocatedAmount = receipt.Amount - allocated
+	if err := tx.Model(receipt).Updates(map[string]interface{}{
+		"allocated_amount":   receipt.AllocatedAmount,
+		"unallocated_amount": receipt.UnallocatedAmount,
+	}).Error; err != nil {
+		return err
+	}
+	return creditUnallocatedReceipt(tx, receipt, userID)
+}
+
+// lockReallocatedInvoices locks the invoices of a receipt's current
+// allocation and of its new one together, in ID order, before anything is
+// changed, so that concurrent reallocations sharing invoices cannot
+// deadlock. An automatic allocation may use any of the buyer's open
+// invoices, so all of them are locked.
+func (s *ReceiptService) lockReallocatedInvoices(tx *gorm.DB, receipt *models.Receipt, payments []models.Payment, request *models.AllocationRequest) error {
+	var ids []uint
+	for _, payment := range payments {
+		ids = append(ids, payment.InvoiceID)
+	}
+	for _, allocation := range request.Allocations {
+		ids = append(ids, allocation.InvoiceID)
+	}
+	if request.AutoAllocate {
+		var open []uint
+		if err := buyerOpenInvoices(tx, receipt).Pluck("id", &open).Error; err != nil {
+			return err
+		}
+		ids = append(ids, open...)
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for i, id := range ids {
+		if i > 0 && id == ids[i-1] {
+			continue
+		}
+		if _, err := s.invoiceService.lockInvoice(tx, id, receipt.SellerID, false); err != nil {
+			return fmt.Errorf("invoice %d not found", id)
+		}
+	}
+	return nil
+}
+
+// buyerOpenInvoices selects the seller's issued invoices to the buyer of a
+// receipt that have an amount due
+func buyerOpenInvoices(tx *gorm.DB, receipt *models.Receipt) *gorm.DB {
+	query := tx.Model(&models.Invoice{}).
+		Where("generated_by_id = ? AND status = ? AND amount_due > 0", receipt.SellerID, constants.InvoiceStatusIssued)
+	if receipt.PartyID != nil {
+		return query.Where("party_id = ?", *receipt.PartyID)
+	}
+	return query.Where("generated_for_id = ?", *receipt.GeneratedForID)
+}
+
+// autoAllocations spreads a receipt over the buyer's open invoices, the
+// one falling due first first, until the receipt is used up
+func (s *ReceiptService) autoAllocations(tx *gorm.DB, receipt *models.Receipt) ([]models.ReceiptAllocation, error) {
+	var open []models.Invoice
+	if err := buyerOpenInvoices(tx, receipt).Select("id, amount_due").Order("due_date, invoice_date, id").Find(&open).Error; err != nil {
+		return nil, err
+	}
+
+	allocations := []models.ReceiptAllocation{}
+	remaining := receipt.Amount
+	for _, invoice := range open {
+		if remaining <= 0 {
+			break
+		}
+		amount := money.Min(remaining, invoice.AmountDue)
+		allocations = append(allocations, models.ReceiptAllocation{InvoiceID: invoice.ID, Amount: amount})
+		remaining -= amount
+	}
+	if len(allocations) == 0 {
+		return nil, errors.New("buyer has no invoices with an amount due")
+	}
+	return allocations, nil
+}
+
+// sameID reports whether two optional IDs are both unset or equal
+func sameID(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
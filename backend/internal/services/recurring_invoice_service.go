@@ -0,0 +1,670 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"invoice-generator/internal/constants"
+	"invoice-generator/internal/database"
+	"invoice-generator/internal/models"
+	"invoice-generator/internal/money"
+	"invoice-generator/internal/recurrence"
+)
+
+// RecurringInvoiceService manages recurring invoice schedules and generates
+// their invoices
+type RecurringInvoiceService struct {
+	invoiceService *InvoiceService
+}
+
+// NewRecurringInvoiceService creates a new recurring invoice service
+func NewRecurringInvoiceService(invoiceService *InvoiceService) *RecurringInvoiceService {
+	return &RecurringInvoiceService{invoiceService: invoiceService}
+}
+
+// CreateSchedule validates and stores a new recurring invoice schedule
+func (s *RecurringInvoiceService) CreateSchedule(schedule *models.RecurringInvoice, userID uint) error {
+	schedule.ID = 0
+	schedule.GeneratedByID = userID
+	schedule.Status = constants.RecurringStatusActive
+	schedule.OccurrenceCount = 0
+	schedule.LastRunDate = nil
+	schedule.LastError = ""
+
+	if err := validateSchedule(schedule); err != nil {
+		return err
+	}
+	if _, err := resolveBuyer(database.GetDB(), userID, schedule.GeneratedForID, schedule.PartyID); err != nil {
+		return err
+	}
+	for i := range schedule.LineItems {
+		schedule.LineItems[i].ID = 0
+	}
+
+	next, err := nextOccurrence(schedule, nil)
+	if err != nil {
+		return err
+	}
+	if next == nil {
+		return errors.New("schedule does not produce any dates")
+	}
+	schedule.NextRunDate = next
+
+	if err := database.GetDB().Omit("GeneratedFor", "Party").Create(schedule).Error; err != nil {
+		return fmt.Errorf("failed to create schedule: %w", err)
+	}
+	return nil
+}
+
+// GetSchedules returns the recurring invoice schedules visible to a user
+func (s *RecurringInvoiceService) GetSchedules(userID uint, isAdmin bool, page, limit int) ([]models.RecurringInvoice, int64, error) {
+	var schedules []models.RecurringInvoice
+	query := database.GetDB().Preload("GeneratedFor").Preload("Party").Preload("LineItems")
+
+	if !isAdmin {
+		query = query.Where("generated_by_id = ?", userID)
+	}
+
+	var total int64
+	query.Model(&models.RecurringInvoice{}).Count(&total)
+
+	offset := (page - 1) * limit
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&schedules).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return schedules, total, nil
+}
+
+// GetSchedule returns a single schedule with access control
+func (s *RecurringInvoiceService) GetSchedule(id uint, userID uint, isAdmin bool) (*models.RecurringInvoice, error) {
+	var schedule models.RecurringInvoice
+	query := database.GetDB().Preload("GeneratedFor").Preload("Party").Preload("LineItems.Item")
+
+	if !isAdmin {
+		query = query.Where("generated_by_id = ?", userID)
+	}
+
+	if err := query.First(&schedule, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("schedule not found")
+		}
+		return nil, err
+	}
+
+	return &schedule, nil
+}
+
+// UpdateSchedule replaces the template and rule of a schedule. Invoices
+// already generated are not affected; the next date is recalculated from
+// the last generated period under the new rule.
+func (s *RecurringInvoiceService) UpdateSchedule(id uint, updateData *models.RecurringInvoice, userID uint, isAdmin bool) (*models.RecurringInvoice, error) {
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		schedule, err := s.lockSchedule(tx, id, userID, isAdmin)
+		if err != nil {
+			return err
+		}
+		if schedule.Status == constants.RecurringStatusCompleted {
+			return errors.New("completed schedules cannot be edited")
+		}
+
+		schedule.Name = updateData.Name
+		schedule.GeneratedForID = updateData.GeneratedForID
+		schedule.PartyID = updateData.PartyID
+		schedule.InvoiceType = updateData.InvoiceType
+		schedule.PlaceOfSupply = updateData.PlaceOfSupply
+		schedule.Series = updateData.Series
+		schedule.Notes = updateData.Notes
+		schedule.Terms = updateData.Terms
+		schedule.DueDays = updateData.DueDays
+		schedule.AutoIssue = updateData.AutoIssue
+		schedule.Discount = updateData.Discount
+		schedule.Frequency = updateData.Frequency
+		schedule.CronExpression = updateData.CronExpression
+		if !updateData.StartDate.IsZero() {
+			schedule.StartDate = updateData.StartDate
+		}
+		schedule.EndDate = updateData.EndDate
+		schedule.MaxOccurrences = updateData.MaxOccurrences
+		schedule.LineItems = updateData.LineItems
+		for i := range schedule.LineItems {
+			schedule.LineItems[i].ID = 0
+			schedule.LineItems[i].RecurringInvoiceID = schedule.ID
+		}
+
+		if err := validateSchedule(schedule); err != nil {
+			return err
+		}
+		if _, err := resolveBuyer(tx, schedule.GeneratedByID, schedule.GeneratedForID, schedule.PartyID); err != nil {
+			return err
+		}
+
+		// Periods before the last generated one are never revisited
+		after := schedule.LastRunDate
+		if schedule.Status == constants.RecurringStatusPaused {
+			after = skipUntilToday(after)
+		}
+		next, err := nextOccurrence(schedule, after)
+		if err != nil {
+			return err
+		}
+		schedule.NextRunDate = next
+		if next == nil {
+			schedule.Status = constants.RecurringStatusCompleted
+		}
+
+		if err := tx.Where("recurring_invoice_id = ?", schedule.ID).Delete(&models.RecurringInvoiceLineItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Omit("GeneratedFor", "Party").Save(schedule).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetSchedule(id, userID, isAdmin)
+}
+
+// PauseSchedule stops an active schedule from generating invoices
+func (s *RecurringInvoiceService) PauseSchedule(id uint, userID uint, isAdmin bool) (*models.RecurringInvoice, error) {
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		schedule, err := s.lockSchedule(tx, id, userID, isAdmin)
+		if err != nil {
+			return err
+		}
+		if schedule.Status != constants.RecurringStatusActive {
+			return errors.New("only active schedules can be paused")
+		}
+		return tx.Model(schedule).Update("status", constants.RecurringStatusPaused).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetSchedule(id, userID, isAdmin)
+}
+
+// ResumeSchedule reactivates a paused schedule. Periods that fell due
+// while the schedule was paused are skipped rather than back-filled.
+func (s *RecurringInvoiceService) ResumeSchedule(id uint, userID uint, isAdmin bool) (*models.RecurringInvoice, error) {
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		schedule, err := s.lockSchedule(tx, id, userID, isAdmin)
+		if err != nil {
+			return err
+		}
+		if schedule.Status != constants.RecurringStatusPaused {
+			return errors.New("only paused schedules can be resumed")
+		}
+
+		next, err := nextOccurrence(schedule, skipUntilToday(schedule.LastRunDate))
+		if err != nil {
+			return err
+		}
+		status := constants.RecurringStatusActive
+		if next == nil {
+			status = constants.RecurringStatusCompleted
+		}
+
+		return tx.Model(schedule).Updates(map[string]interface{}{
+			"status":        status,
+			"next_run_date": next,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetSchedule(id, userID, isAdmin)
+}
+
+// DeleteSchedule removes a schedule and its run history. Invoices it has
+// generated are kept.
+func (s *RecurringInvoiceService) DeleteSchedule(id uint, userID uint, isAdmin bool) error {
+	return database.GetDB().Transaction(func(tx *gorm.DB) error {
+		schedule, err := s.lockSchedule(tx, id, userID, isAdmin)
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("recurring_invoice_id = ?", schedule.ID).Delete(&models.RecurringInvoiceRun{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("recurring_invoice_id = ?", schedule.ID).Delete(&models.RecurringInvoiceLineItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(schedule).Error
+	})
+}
+
+// GetRuns returns the generation history of a schedule, newest first
+func (s *RecurringInvoiceService) GetRuns(id uint, userID uint, isAdmin bool) ([]models.RecurringInvoiceRun, error) {
+	if _, err := s.GetSchedule(id, userID, isAdmin); err != nil {
+		return nil, err
+	}
+
+	var runs []models.RecurringInvoiceRun
+	if err := database.GetDB().Where("recurring_invoice_id = ?", id).
+		Order("period_date DESC, id DESC").Find(&runs).Error; err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// PreviewSchedule returns the next dates on which a stored schedule will
+// generate invoices, without generating anything
+func (s *RecurringInvoiceService) PreviewSchedule(id uint, count int, userID uint, isAdmin bool) ([]time.Time, error) {
+	schedule, err := s.GetSchedule(id, userID, isAdmin)
+	if err != nil {
+		return nil, err
+	}
+	if schedule.Status != constants.RecurringStatusActive || schedule.NextRunDate == nil {
+		return []time.Time{}, nil
+	}
+
+	dates := []time.Time{*schedule.NextRunDate}
+	previewed := *schedule
+	previewed.OccurrenceCount++
+	more, err := previewDates(&previewed, schedule.NextRunDate, count-1)
+	if err != nil {
+		return nil, err
+	}
+	return append(dates, more...), nil
+}
+
+// PreviewRule returns the first dates an unsaved schedule would generate
+func (s *RecurringInvoiceService) PreviewRule(schedule *models.RecurringInvoice, count int) ([]time.Time, error) {
+	schedule.OccurrenceCount = 0
+	if err := validateRule(schedule); err != nil {
+		return nil, err
+	}
+	return previewDates(schedule, nil, count)
+}
+
+// StartScheduler runs due schedules in the background at the given
+// interval until ctx is cancelled. A pass also runs immediately so that
+// periods missed while the server was down are generated on startup.
+func (s *RecurringInvoiceService) StartScheduler(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			generated, err := s.RunDueSchedules(time.Now())
+			if err != nil {
+				log.Printf("Recurring invoice scheduler: %v", err)
+			} else if generated > 0 {
+				log.Printf("Recurring invoice scheduler: generated %d invoice(s)", generated)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunDueSchedules generates invoices for every active schedule whose next
+// date is on or before now, catching up on missed periods one at a time.
+// It returns the number of invoices generated.
+func (s *RecurringInvoiceService) RunDueSchedules(now time.Time) (int, error) {
+	today := recurrence.Date(now)
+
+	var ids []uint
+	if err := database.GetDB().Model(&models.RecurringInvoice{}).
+		Where("status = ? AND next_run_date <= ?", constants.RecurringStatusActive, today).
+		Order("next_run_date, id").Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	generated := 0
+	for _, id := range ids {
+		for i := 0; i < constants.MaxCatchUpRuns; i++ {
+			ran, err := s.runSchedule(id, today)
+			if err != nil {
+				log.Printf("Recurring invoice schedule %d: %v", id, err)
+				break
+			}
+			if !ran {
+				break
+			}
+			generated++
+		}
+	}
+
+	return generated, nil
+}
+
+// runSchedule generates the invoice for the next due period of a schedule.
+// The schedule row is locked and the invoice, run record and schedule
+// update are committed together, so each period is generated exactly once
+// even with several server processes. It reports whether an invoice was
+// generated.
+func (s *RecurringInvoiceService) runSchedule(id uint, today time.Time) (bool, error) {
+	var period time.Time
+	ran := false
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var schedule models.RecurringInvoice
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Preload("LineItems").
+			Where("id = ? AND status = ? AND next_run_date <= ?", id, constants.RecurringStatusActive, today).
+			First(&schedule).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Not due, no longer active, or being run by another process
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		period = *schedule.NextRunDate
+
+		invoice := buildScheduledInvoice(&schedule, period)
+		if err := s.invoiceService.createInvoice(tx, invoice, schedule.GeneratedByID); err != nil {
+			return err
+		}
+
+		run := models.RecurringInvoiceRun{
+			RecurringInvoiceID: schedule.ID,
+			PeriodDate:         period,
+			Status:             constants.RunStatusSuccess,
+			InvoiceID:          &invoice.ID,
+			Attempts:           1,
+		}
+		if err := tx.Create(&run).Error; err != nil {
+			return fmt.Errorf("failed to record run: %w", err)
+		}
+
+		schedule.OccurrenceCount++
+		schedule.LastRunDate = &period
+		next, err := nextOccurrence(&schedule, &period)
+		if err != nil {
+			return err
+		}
+		status := constants.RecurringStatusActive
+		if next == nil {
+			status = constants.RecurringStatusCompleted
+		}
+
+		ran = true
+		return tx.Model(&schedule).Updates(map[string]interface{}{
+			"occurrence_count": schedule.OccurrenceCount,
+			"last_run_date":    schedule.LastRunDate,
+			"next_run_date":    next,
+			"status":           status,
+			"last_error":       "",
+		}).Error
+	})
+	if err != nil {
+		s.recordFailure(id, period, err)
+		return false, err
+	}
+
+	return ran, nil
+}
+
+// recordFailure stores a failed attempt for a period. Repeated failures for
+// the same period update a single run record instead of adding new ones.
+func (s *RecurringInvoiceService) recordFailure(id uint, period time.Time, cause error) {
+	db := database.GetDB()
+	if period.IsZero() {
+		db.Model(&models.RecurringInvoice{}).Where("id = ?", id).Update("last_error", cause.Error())
+		return
+	}
+
+	var run models.RecurringInvoiceRun
+	err := db.Where("recurring_invoice_id = ? AND period_date = ? AND status = ?", id, period, constants.RunStatusFailed).
+		First(&run).Error
+	if err == nil {
+		db.Model(&run).Updates(map[string]interface{}{
+			"attempts": run.Attempts + 1,
+			"error":    cause.Error(),
+		})
+	} else {
+		db.Create(&models.RecurringInvoiceRun{
+			RecurringInvoiceID: id,
+			PeriodDate:         period,
+			Status:             constants.RunStatusFailed,
+			Attempts:           1,
+			Error:              cause.Error(),
+		})
+	}
+	db.Model(&models.RecurringInvoice{}).Where("id = ?", id).Update("last_error", cause.Error())
+}
+
+// lockSchedule loads a schedule for update, restricted to its owner unless
+// the user is an admin
+func (s *RecurringInvoiceService) lockSchedule(tx *gorm.DB, id uint, userID uint, isAdmin bool) (*models.RecurringInvoice, error) {
+	var schedule models.RecurringInvoice
+	query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id)
+	if !isAdmin {
+		query = query.Where("generated_by_id = ?", userID)
+	}
+	if err := query.First(&schedule).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("schedule not found")
+		}
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// buildScheduledInvoice creates the invoice for one period from a schedule
+func buildScheduledInvoice(schedule *models.RecurringInvoice, period time.Time) *models.Invoice {
+	// Without due days the invoice falls due on the buyer's payment terms
+	var dueDate time.Time
+	if schedule.DueDays > 0 {
+		dueDate = period.AddDate(0, 0, schedule.DueDays)
+	}
+
+	invoice := &models.Invoice{
+		GeneratedForID: schedule.GeneratedForID,
+		PartyID:        schedule.PartyID,
+		InvoiceType:    schedule.InvoiceType,
+		PlaceOfSupply:  schedule.PlaceOfSupply,
+		Series:         schedule.Series,
+		Notes:          schedule.Notes,
+		Terms:          schedule.Terms,
+		Discount:       schedule.Discount,
+		InvoiceDate:    period,
+		DueDate:        dueDate,
+		Issue:          schedule.AutoIssue,
+		LineItems:      make([]models.InvoiceLineItem, 0, len(schedule.LineItems)),
+	}
+	for _, line := range schedule.LineItems {
+		invoice.LineItems = append(invoice.LineItems, models.InvoiceLineItem{
+			ItemID:      line.ItemID,
+			Description: line.Description,
+			HSNCode:     line.HSNCode,
+			Quantity:    line.Quantity,
+			Rate:        line.Rate,
+			GSTRate:     line.GSTRate,
+			Discount:    line.Discount,
+		})
+	}
+	return invoice
+}
+
+// validateSchedule checks the invoice template and the recurrence rule
+func validateSchedule(schedule *models.RecurringInvoice) error {
+	schedule.Name = strings.TrimSpace(schedule.Name)
+	if schedule.Name == "" {
+		return errors.New("name is required")
+	}
+	if schedule.GeneratedForID == nil && schedule.PartyID == nil {
+		return errors.New("generated_for_id or party_id is required")
+	}
+
+	isValidType := false
+	for _, t := range constants.ValidInvoiceTypes {
+		if schedule.InvoiceType == t {
+			isValidType = true
+			break
+		}
+	}
+	if !isValidType {
+		return errors.New("invalid invoice type")
+	}
+
+	if len(schedule.LineItems) == 0 {
+		return errors.New("schedule must have at least one line item")
+	}
+	for i, line := range schedule.LineItems {
+		if strings.TrimSpace(line.Description) == "" {
+			return fmt.Errorf("line %d: description is required", i+1)
+		}
+		if line.Quantity <= 0 {
+			return fmt.Errorf("line %d: quantity must be positive", i+1)
+		}
+		if line.Rate < 0 {
+			return fmt.Errorf("line %d: rate cannot be negative", i+1)
+		}
+		if err := checkLineHSNCode(i, &schedule.LineItems[i].HSNCode); err != nil {
+			return err
+		}
+	}
+	if schedule.DueDays < 0 {
+		return errors.New("due_days cannot be negative")
+	}
+
+	grosses := make([]money.Amount, len(schedule.LineItems))
+	lineDiscounts := make([]models.Discount, len(schedule.LineItems))
+	for i, line := range schedule.LineItems {
+		grosses[i] = line.Quantity.MulAmount(line.Rate)
+		lineDiscounts[i] = line.Discount
+	}
+	if err := validateDiscounts(grosses, lineDiscounts, schedule.Discount); err != nil {
+		return err
+	}
+
+	return validateRule(schedule)
+}
+
+// validateRule checks the recurrence rule and normalises its dates
+func validateRule(schedule *models.RecurringInvoice) error {
+	schedule.Frequency = strings.ToUpper(strings.TrimSpace(schedule.Frequency))
+	isValidFrequency := false
+	for _, f := range constants.ValidFrequencies {
+		if schedule.Frequency == f {
+			isValidFrequency = true
+			break
+		}
+	}
+	if !isValidFrequency {
+		return errors.New("frequency must be MONTHLY, QUARTERLY, YEARLY or CUSTOM")
+	}
+
+	if schedule.Frequency == constants.FrequencyCustom {
+		cron, err := recurrence.ParseCron(schedule.CronExpression)
+		if err != nil {
+			return err
+		}
+		schedule.CronExpression = cron.String()
+	} else {
+		schedule.CronExpression = ""
+	}
+
+	if schedule.StartDate.IsZero() {
+		schedule.StartDate = time.Now()
+	}
+	schedule.StartDate = recurrence.Date(schedule.StartDate)
+	if schedule.EndDate != nil {
+		end := recurrence.Date(*schedule.EndDate)
+		if end.Before(schedule.StartDate) {
+			return errors.New("end date cannot be before start date")
+		}
+		schedule.EndDate = &end
+	}
+	if schedule.MaxOccurrences < 0 {
+		return errors.New("max_occurrences cannot be negative")
+	}
+
+	return nil
+}
+
+// nextOccurrence returns the first date of the schedule after the given
+// date, or the first date on or after the start date when after is nil.
+// It returns nil once the end date or the maximum number of occurrences
+// has been reached.
+func nextOccurrence(schedule *models.RecurringInvoice, after *time.Time) (*time.Time, error) {
+	if schedule.MaxOccurrences > 0 && schedule.OccurrenceCount >= schedule.MaxOccurrences {
+		return nil, nil
+	}
+
+	var next time.Time
+	switch schedule.Frequency {
+	case constants.FrequencyCustom:
+		cron, err := recurrence.ParseCron(schedule.CronExpression)
+		if err != nil {
+			return nil, err
+		}
+		from := schedule.StartDate.AddDate(0, 0, -1)
+		if after != nil && after.After(from) {
+			from = *after
+		}
+		var ok bool
+		if next, ok = cron.Next(from); !ok {
+			return nil, nil
+		}
+	default:
+		step := frequencyMonths(schedule.Frequency)
+		// Count periods from the start date so that month-end dates are
+		// not lost after a short month (31 Jan, 28 Feb, 31 Mar, ...)
+		next = schedule.StartDate
+		for k := 1; after != nil && !next.After(*after); k++ {
+			next = recurrence.AddMonths(schedule.StartDate, k*step)
+		}
+	}
+
+	if schedule.EndDate != nil && next.After(*schedule.EndDate) {
+		return nil, nil
+	}
+	return &next, nil
+}
+
+// previewDates lists up to count dates of a schedule after the given date
+func previewDates(schedule *models.RecurringInvoice, after *time.Time, count int) ([]time.Time, error) {
+	dates := []time.Time{}
+	for len(dates) < count {
+		next, err := nextOccurrence(schedule, after)
+		if err != nil {
+			return nil, err
+		}
+		if next == nil {
+			break
+		}
+		dates = append(dates, *next)
+		schedule.OccurrenceCount++
+		after = next
+	}
+	return dates, nil
+}
+
+// frequencyMonths returns the number of months between fixed-frequency
+// occurrences
+func frequencyMonths(frequency string) int {
+	switch frequency {
+	case constants.FrequencyQuarterly:
+		return 3
+	case constants.FrequencyYearly:
+		return 12
+	default:
+		return 1
+	}
+}
+
+// skipUntilToday returns the later of the last generated date and
+// yesterday, so that the next occurrence is not in the past
+func skipUntilToday(last *time.Time) *time.Time {
+	yesterday := recurrence.Date(time.Now()).AddDate(0, 0, -1)
+	if last != nil && last.After(yesterday) {
+		return last
+	}
+	return &yesterday
+}
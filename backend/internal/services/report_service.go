@@ -0,0 +1,307 @@
+package services
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"sort"
+	"time"
+
+	"invoice-generator/internal/constants"
+	"invoice-generator/internal/database"
+	"invoice-generator/internal/gst"
+	"invoice-generator/internal/models"
+	"invoice-generator/internal/money"
+	"invoice-generator/internal/validation"
+)
+
+// ReportService builds tax reports from a seller's invoices, notes and the
+// invoices the seller received
+type ReportService struct{}
+
+// NewReportService creates a new report service
+func NewReportService() *ReportService {
+	return &ReportService{}
+}
+
+// GetGSTR3BSummary computes the GSTR-3B figures of a user for a period:
+// outward supplies by type, input tax credit from invoices received from
+// other sellers, and how much of the output tax that credit pays
+func (s *ReportService) GetGSTR3BSummary(userID uint, period gst.ReturnPeriod) (*models.GSTR3BSummary, error) {
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	summary := &models.GSTR3BSummary{
+		GSTIN:                  validation.Normalise(user.GSTIN),
+		Period:                 period.FilingPeriod(),
+		From:                   period.From,
+		To:                     period.To.AddDate(0, 0, -1),
+		InterStateUnregistered: []models.StateSupplySummary{},
+	}
+	interState := make(map[string]*models.StateSupplySummary)
+
+	// Outward supplies
+	var invoices []models.Invoice
+	if err := issuedInvoices().Preload("GeneratedFor").Preload("Party").Preload("LineItems").
+		Where("generated_by_id = ? AND invoice_date >= ? AND invoice_date < ?", userID, period.From, period.To).
+		Find(&invoices).Error; err != nil {
+		return nil, err
+	}
+	for i := range invoices {
+		invoice := &invoices[i]
+		for _, line := range invoice.LineItems {
+			addOutwardSupply(summary, interState, invoice, line.GSTRate, line.Amount, line.IGSTAmount, line.CGSTAmount, line.SGSTAmount, 1)
+		}
+	}
+
+	var notes []models.AdjustmentNote
+	if err := db.Preload("LineItems").Preload("Invoice.GeneratedFor").Preload("Invoice.Party").
+		Where("generated_by_id = ? AND note_date >= ? AND note_date < ?", userID, period.From, period.To).
+		Find(&notes).Error; err != nil {
+		return nil, err
+	}
+	for _, note := range notes {
+		if note.Invoice == nil {
+			continue
+		}
+		sign := noteSign(note.NoteType)
+		for _, line := range note.LineItems {
+			addOutwardSupply(summary, interState, note.Invoice, *line.GSTRate, line.Amount, line.IGSTAmount, line.CGSTAmount, line.SGSTAmount, sign)
+		}
+	}
+
+	for _, state := range interState {
+		summary.InterStateUnregistered = append(summary.InterStateUnregistered, *state)
+	}
+	sort.Slice(summary.InterStateUnregistered, func(i, j int) bool {
+		return summary.InterStateUnregistered[i].PlaceOfSupply < summary.InterStateUnregistered[j].PlaceOfSupply
+	})
+
+	// Input tax credit is only available to registered users, on invoices
+	// received from other sellers and the notes against them
+	if summary.GSTIN != "" {
+		var received models.TaxSummary
+		if err := issuedInvoices().
+			Where("generated_for_id = ? AND generated_by_id != ? AND invoice_date >= ? AND invoice_date < ?",
+				userID, userID, period.From, period.To).
+			Select("COALESCE(SUM(sub_total), 0), COALESCE(SUM(total_igst), 0), COALESCE(SUM(total_cgst), 0), COALESCE(SUM(total_sgst), 0)").
+			Row().Scan(&received.TaxableValue, &received.IGST, &received.CGST, &received.SGST); err != nil {
+			return nil, err
+		}
+		summary.ITCAvailable = received
+
+		for _, noteType := range []string{constants.NoteTypeCredit, constants.NoteTypeDebit} {
+			var adjusted models.TaxSummary
+			if err := db.Model(&models.AdjustmentNote{}).
+				Where("generated_for_id = ? AND generated_by_id != ? AND note_type = ? AND note_date >= ? AND note_date < ?",
+					userID, userID, noteType, period.From, period.To).
+				Select("COALESCE(SUM(sub_total), 0), COALESCE(SUM(total_igst), 0), COALESCE(SUM(total_cgst), 0), COALESCE(SUM(total_sgst), 0)").
+				Row().Scan(&adjusted.TaxableValue, &adjusted.IGST, &adjusted.CGST, &adjusted.SGST); err != nil {
+				return nil, err
+			}
+			addTax(&summary.ITCAvailable, adjusted, noteSign(noteType))
+		}
+	}
+	summary.NetITC = summary.ITCAvailable
+	addTax(&summary.NetITC, summary.ITCReversed, -1)
+
+	summary.OutputTax = summary.OutwardTaxable
+	addTax(&summary.OutputTax, summary.OutwardZeroRated, 1)
+	addTax(&summary.OutputTax, summary.OutwardNilExempt, 1)
+	setOffITC(summary)
+
+	return summary, nil
+}
+
+// GetHSNSummary totals a seller's issued invoices, and the credit and
+// debit notes against them, by HSN or SAC code and GST rate for the dates
+// from to to inclusive
+func (s *ReportService) GetHSNSummary(sellerID uint, from, to time.Time) (*models.HSNSummary, error) {
+	db := database.GetDB()
+	end := to.AddDate(0, 0, 1)
+
+	var invoices []models.Invoice
+	if err := issuedInvoices().Preload("LineItems.Item").
+		Where("generated_by_id = ? AND invoice_date >= ? AND invoice_date < ?", sellerID, from, end).
+		Find(&invoices).Error; err != nil {
+		return nil, err
+	}
+	var notes []models.AdjustmentNote
+	if err := db.Preload("LineItems").Preload("Invoice.LineItems.Item").
+		Where("generated_by_id = ? AND note_date >= ? AND note_date < ?", sellerID, from, end).
+		Find(&notes).Error; err != nil {
+		return nil, err
+	}
+
+	summary := &models.HSNSummary{From: from, To: to, Rows: []models.HSNSummaryRow{}}
+	rows := make(map[hsnKey]*gst.HSNRow)
+	add := func(lines []taxLine, sign int64) {
+		for _, line := range lines {
+			if line.hsn == "" {
+				summary.LinesWithoutHSN++
+			}
+		}
+		addHSNLines(rows, lines, sign)
+	}
+	for i := range invoices {
+		lines := make([]taxLine, len(invoices[i].LineItems))
+		for j := range invoices[i].LineItems {
+			lines[j] = invoiceTaxLine(&invoices[i].LineItems[j])
+		}
+		add(lines, 1)
+	}
+	for i := range notes {
+		add(noteTaxLines(&notes[i]), noteSign(notes[i].NoteType))
+	}
+
+	for _, row := range hsnRows(rows) {
+		summary.Rows = append(summary.Rows, models.HSNSummaryRow{
+			HSNCode:      row.HSN,
+			Description:  row.Description,
+			UQC:          row.UQC,
+			Quantity:     row.Quantity,
+			GSTRate:      row.Rate,
+			TaxableValue: row.TaxableValue,
+			IGST:         row.IGST,
+			CGST:         row.CGST,
+			SGST:         row.SGST,
+			Cess:         row.Cess,
+			TotalValue:   row.TaxableValue + row.IGST + row.CGST + row.SGST + row.Cess,
+		})
+		addTax(&summary.Total, models.TaxSummary{
+			TaxableValue: row.TaxableValue,
+			IGST:         row.IGST,
+			CGST:         row.CGST,
+			SGST:         row.SGST,
+			Cess:         row.Cess,
+		}, 1)
+	}
+
+	return summary, nil
+}
+
+// addOutwardSupply adds a line of an invoice or note to the outward supply
+// it belongs to, negated for credit notes
+func addOutwardSupply(summary *models.GSTR3BSummary, interState map[string]*models.StateSupplySummary,
+	invoice *models.Invoice, rate int, taxableValue, igst, cgst, sgst money.Amount, sign int64) {
+	line := models.TaxSummary{TaxableValue: taxableValue, IGST: igst, CGST: cgst, SGST: sgst}
+
+	switch {
+	case invoice.PlaceOfSupply == gst.ForeignCountry:
+		addTax(&summary.OutwardZeroRated, line, sign)
+		return
+	case rate == 0:
+		addTax(&summary.OutwardNilExempt, line, sign)
+		return
+	}
+	addTax(&summary.OutwardTaxable, line, sign)
+
+	buyer := invoiceBuyer(invoice)
+	if invoice.SupplyType != constants.SupplyTypeInterState || buyer.GSTIN != "" {
+		return
+	}
+	state := interState[invoice.PlaceOfSupply]
+	if state == nil {
+		state = &models.StateSupplySummary{
+			PlaceOfSupply: invoice.PlaceOfSupply,
+			StateName:     gst.StateName(invoice.PlaceOfSupply),
+		}
+		interState[invoice.PlaceOfSupply] = state
+	}
+	state.TaxableValue += taxableValue * money.Amount(sign)
+	state.IGST += igst * money.Amount(sign)
+}
+
+// addTax adds sign times the amounts of b to a
+func addTax(a *models.TaxSummary, b models.TaxSummary, sign int64) {
+	a.TaxableValue += b.TaxableValue * money.Amount(sign)
+	a.IGST += b.IGST * money.Amount(sign)
+	a.CGST += b.CGST * money.Amount(sign)
+	a.SGST += b.SGST * money.Amount(sign)
+	a.Cess += b.Cess * money.Amount(sign)
+}
+
+// noteSign returns -1 for credit notes, which reduce the supplies they
+// adjust, and 1 for debit notes
+func noteSign(noteType string) int64 {
+	if noteType == constants.NoteTypeCredit {
+		return -1
+	}
+	return 1
+}
+
+// setOffITC pays the output tax from the net input tax credit in the order
+// the GST law requires: IGST credit is used first, against IGST and then
+// CGST and SGST; CGST and SGST credit are then used against their own head
+// and then IGST. CGST credit can never pay SGST or the other way round.
+func setOffITC(summary *models.GSTR3BSummary) {
+	liability := summary.OutputTax
+	credit := summary.NetITC
+	var paid models.TaxSummary
+
+	use := func(credit, liability, paid *money.Amount) {
+		amount := money.Min(*credit, *liability)
+		if !amount.IsPositive() {
+			return
+		}
+		*credit -= amount
+		*liability -= amount
+		*paid += amount
+	}
+	use(&credit.IGST, &liability.IGST, &paid.IGST)
+	use(&credit.IGST, &liability.CGST, &paid.CGST)
+	use(&credit.IGST, &liability.SGST, &paid.SGST)
+	use(&credit.CGST, &liability.CGST, &paid.CGST)
+	use(&credit.CGST, &liability.IGST, &paid.IGST)
+	use(&credit.SGST, &liability.SGST, &paid.SGST)
+	use(&credit.SGST, &liability.IGST, &paid.IGST)
+
+	summary.PaidThroughITC = paid
+	summary.PayableInCash = models.TaxSummary{
+		IGST: money.Max(liability.IGST, money.Zero),
+		CGST: money.Max(liability.CGST, money.Zero),
+		SGST: money.Max(liability.SGST, money.Zero),
+		Cess: money.Max(liability.Cess, money.Zero),
+	}
+	summary.ITCCarryForward = models.TaxSummary{
+		IGST: money.Max(credit.IGST, money.Zero),
+		CGST: money.Max(credit.CGST, money.Zero),
+		SGST: money.Max(credit.SGST, money.Zero),
+		Cess: money.Max(credit.Cess, money.Zero),
+	}
+}
+
+// WriteGSTR3BCSV writes a GSTR-3B summary as CSV, one row per figure in
+// the order of the return's tables
+func WriteGSTR3BCSV(w io.Writer, summary *models.GSTR3BSummary) error {
+	out := csv.NewWriter(w)
+	row := func(section, description string, t models.TaxSummary) {
+		out.Write([]string{section, description, t.TaxableValue.String(), t.IGST.String(), t.CGST.String(), t.SGST.String(), t.Cess.String()})
+	}
+
+	out.Write([]string{"GSTIN", summary.GSTIN})
+	out.Write([]string{"Period", summary.Period})
+	out.Write([]string{})
+	out.Write([]string{"Section", "Description", "Taxable Value", "IGST", "CGST", "SGST", "Cess"})
+	row("3.1(a)", "Outward taxable supplies (other than zero rated, nil rated and exempted)", summary.OutwardTaxable)
+	row("3.1(b)", "Outward taxable supplies (zero rated)", summary.OutwardZeroRated)
+	row("3.1(c)", "Other outward supplies (nil rated, exempted)", summary.OutwardNilExempt)
+	for _, state := range summary.InterStateUnregistered {
+		row("3.2", "Inter-state supplies to unregistered persons: "+state.PlaceOfSupply+"-"+state.StateName,
+			models.TaxSummary{TaxableValue: state.TaxableValue, IGST: state.IGST})
+	}
+	row("4(A)(5)", "All other ITC", summary.ITCAvailable)
+	row("4(B)", "ITC reversed", summary.ITCReversed)
+	row("4(C)", "Net ITC available", summary.NetITC)
+	row("6.1", "Output tax", summary.OutputTax)
+	row("6.1", "Paid through ITC", summary.PaidThroughITC)
+	row("6.1", "Payable in cash", summary.PayableInCash)
+	row("", "ITC carried forward", summary.ITCCarryForward)
+
+	out.Flush()
+	return out.Error()
+}
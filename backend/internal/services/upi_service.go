@@ -0,0 +1,75 @@
+package services
+
+import (
+	"errors"
+
+	"invoice-generator/internal/constants"
+	"invoice-generator/internal/models"
+	"invoice-generator/internal/qr"
+	"invoice-generator/internal/upi"
+)
+
+// UPIService prepares UPI payment requests for the amount due on invoices,
+// payable to the seller's UPI ID
+type UPIService struct {
+	invoiceService *InvoiceService
+}
+
+// NewUPIService creates a new UPI service
+func NewUPIService(invoiceService *InvoiceService) *UPIService {
+	return &UPIService{invoiceService: invoiceService}
+}
+
+// GetPaymentLink returns the UPI payment request of an issued invoice. The
+// buyer as well as the seller may fetch it.
+func (s *UPIService) GetPaymentLink(id uint, userID uint, isAdmin bool) (*models.UPIPaymentLink, error) {
+	invoice, err := s.invoiceService.GetInvoice(id, userID, isAdmin)
+	if err != nil {
+		return nil, err
+	}
+	payment, err := invoiceUPIPayment(invoice)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.UPIPaymentLink{
+		URI:       payment.URI(),
+		VPA:       payment.VPA,
+		PayeeName: payment.Name,
+		Amount:    payment.Amount,
+		Note:      payment.Note,
+	}, nil
+}
+
+// GetQRCode returns the UPI payment request of an issued invoice encoded
+// as a QR code
+func (s *UPIService) GetQRCode(id uint, userID uint, isAdmin bool) (*qr.Code, error) {
+	link, err := s.GetPaymentLink(id, userID, isAdmin)
+	if err != nil {
+		return nil, err
+	}
+	return qr.Encode(link.URI, qr.Medium)
+}
+
+// invoiceUPIPayment builds the UPI payment of an invoice's amount due, with
+// the invoice number as the note. The seller's current UPI ID is used,
+// even on invoices issued before it changed.
+func invoiceUPIPayment(invoice *models.Invoice) (*upi.Payment, error) {
+	if invoice.Status != constants.InvoiceStatusIssued {
+		return nil, errors.New("UPI payments can only be requested for issued invoices")
+	}
+	if !invoice.AmountDue.IsPositive() {
+		return nil, errors.New("invoice has nothing due")
+	}
+	if invoice.GeneratedBy.UPIVPA == "" {
+		return nil, errors.New("seller has not set a UPI ID on their profile")
+	}
+
+	seller := invoiceSeller(invoice)
+	return &upi.Payment{
+		VPA:    invoice.GeneratedBy.UPIVPA,
+		Name:   partyName(&seller),
+		Amount: invoice.AmountDue,
+		Note:   "Invoice " + invoice.InvoiceNumber,
+	}, nil
+}
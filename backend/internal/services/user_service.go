@@ -10,6 +10,7 @@ import (
 	"invoice-generator/internal/constants"
 	"invoice-generator/internal/database"
 	"invoice-generator/internal/models"
+	"invoice-generator/internal/validation"
 )
 
 // UserService handles user-related business logic
@@ -36,6 +37,14 @@ func (s *UserService) Register(user *models.User) error {
 		return errors.New("password must be at least 6 characters")
 	}
 
+	// Validate tax registration and payment details
+	user.GSTIN = validation.Normalise(user.GSTIN)
+	user.PAN = validation.Normalise(user.PAN)
+	user.UPIVPA = strings.TrimSpace(user.UPIVPA)
+	if err := validateProfile(user); err != nil {
+		return err
+	}
+
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -81,7 +90,7 @@ func (s *UserService) Login(loginData *models.LoginRequest) (string, *models.Use
 // GetUsers returns all users (with access control)
 func (s *UserService) GetUsers(isAdmin bool) ([]models.User, error) {
 	var users []models.User
-	query := database.GetDB().Select("id, email, name, company_name, gstin, address, city, state, pincode, phone, is_admin, created_at, updated_at")
+	query := database.GetDB().Select("id, email, name, company_name, gstin, pan, upi_vpa, address, city, state, pincode, phone, is_admin, created_at, updated_at")
 
 	// If not admin, only return basic info
 	if !isAdmin {
@@ -98,7 +107,7 @@ func (s *UserService) GetUsers(isAdmin bool) ([]models.User, error) {
 // GetProfile returns user profile by ID
 func (s *UserService) GetProfile(userID uint) (*models.User, error) {
 	var user models.User
-	if err := database.GetDB().Select("id, email, name, company_name, gstin, address, city, state, pincode, phone, is_admin, created_at, updated_at").
+	if err := database.GetDB().Select("id, email, name, company_name, gstin, pan, upi_vpa, address, city, state, pincode, phone, is_admin, created_at, updated_at").
 		First(&user, userID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("user not found")
@@ -117,9 +126,46 @@ func (s *UserService) UpdateProfile(userID uint, updateData *models.User) error
 	updateData.Password = ""
 	updateData.IsAdmin = false
 
+	// Validate the updated tax registration details against the ones kept
+	updateData.GSTIN = validation.Normalise(updateData.GSTIN)
+	updateData.PAN = validation.Normalise(updateData.PAN)
+	updateData.UPIVPA = strings.TrimSpace(updateData.UPIVPA)
+	var current models.User
+	if err := database.GetDB().First(&current, userID).Error; err != nil {
+		return errors.New("user not found")
+	}
+	if updateData.GSTIN != "" {
+		current.GSTIN = updateData.GSTIN
+	}
+	if updateData.PAN != "" {
+		current.PAN = updateData.PAN
+	}
+	if updateData.State != "" {
+		current.State = updateData.State
+	}
+	if updateData.UPIVPA != "" {
+		current.UPIVPA = updateData.UPIVPA
+	}
+	if err := validateProfile(&current); err != nil {
+		return err
+	}
+
 	if err := database.GetDB().Model(&models.User{}).Where("id = ?", userID).Updates(updateData).Error; err != nil {
 		return errors.New("failed to update profile")
 	}
 
 	return nil
 }
+
+// validateProfile checks a user's GSTIN, PAN and state and that they agree
+// with each other, and the UPI ID invoices are paid to
+func validateProfile(user *models.User) error {
+	var errs validation.Errors
+	validation.CheckRegistration(&errs, "gstin", user.GSTIN, "pan", user.PAN, "state", user.State)
+	if user.UPIVPA != "" {
+		if err := validation.ValidateVPA(user.UPIVPA); err != nil {
+			errs.Add("upi_vpa", err.Error())
+		}
+	}
+	return errs.Err()
+}
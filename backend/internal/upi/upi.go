@@ -0,0 +1,60 @@
+// Package upi builds UPI payment intent URIs (upi://pay) as defined in
+// NPCI's linking specification. Opened on a phone, or scanned from a QR
+// code, the URI starts a payment in any UPI app with the payee, amount and
+// note filled in.
+package upi
+
+import (
+	"net/url"
+	"strings"
+
+	"invoice-generator/internal/money"
+)
+
+// Currency is the only currency UPI accepts
+const Currency = "INR"
+
+// maxNoteLength is the longest transaction note apps show in full
+const maxNoteLength = 50
+
+// Payment is a request to pay a payee by UPI
+type Payment struct {
+	VPA    string       // Payee's virtual payment address, e.g. acme@okhdfcbank
+	Name   string       // Payee's name
+	Amount money.Amount // Zero lets the payer enter the amount
+	Note   string       // Transaction note shown to the payer, such as the invoice number
+}
+
+// URI returns the upi://pay intent URI of a payment. The merchant-only
+// parameters (mc, tr) are left out, since apps refuse them for addresses
+// not registered as merchants.
+func (p Payment) URI() string {
+	params := [][2]string{{"pa", p.VPA}}
+	if p.Name != "" {
+		params = append(params, [2]string{"pn", p.Name})
+	}
+	if p.Note != "" {
+		note := p.Note
+		if runes := []rune(note); len(runes) > maxNoteLength {
+			note = string(runes[:maxNoteLength])
+		}
+		params = append(params, [2]string{"tn", note})
+	}
+	if p.Amount.IsPositive() {
+		params = append(params, [2]string{"am", p.Amount.String()})
+	}
+	params = append(params, [2]string{"cu", Currency})
+
+	parts := make([]string, len(params))
+	for i, param := range params {
+		parts[i] = param[0] + "=" + escape(param[1])
+	}
+	return "upi://pay?" + strings.Join(parts, "&")
+}
+
+// escape percent-encodes a parameter value. Spaces are written as %20 and
+// the @ of an address is left as is, since some UPI apps read neither a
+// + nor %40 back.
+func escape(value string) string {
+	return strings.NewReplacer("+", "%20", "%40", "@").Replace(url.QueryEscape(value))
+}
@@ -0,0 +1,142 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"invoice-generator/internal/gst"
+)
+
+// gstinCharset is the alphabet of the GSTIN check digit calculation; each
+// character's value is its position
+const gstinCharset = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// panEntityTypes are the holder types allowed in the fourth character of a
+// PAN, e.g. P for an individual and C for a company
+const panEntityTypes = "ABCEFGHJLPT"
+
+// ValidateGSTIN checks the structure of a normalised GSTIN: the state
+// code, the embedded PAN, the entity number, the fixed "Z" and the mod-36
+// check digit. The message says which part is wrong.
+func ValidateGSTIN(gstin string) error {
+	if len(gstin) != 15 {
+		return fmt.Errorf("must be 15 characters, got %d", len(gstin))
+	}
+	for _, r := range gstin {
+		if !strings.ContainsRune(gstinCharset, r) {
+			return errors.New("may only contain digits and capital letters")
+		}
+	}
+
+	stateCode := gstin[:2]
+	if !gst.IsValidStateCode(stateCode) {
+		return fmt.Errorf("starts with %q, which is not a valid GST state code", stateCode)
+	}
+	if err := ValidatePAN(gstin[2:12]); err != nil {
+		return fmt.Errorf("characters 3 to 12 must be the holder's PAN: %w", err)
+	}
+	if gstin[12] == '0' {
+		return errors.New("the 13th character (registration number) cannot be 0")
+	}
+	if gstin[13] != 'Z' {
+		return errors.New("the 14th character must be Z")
+	}
+	if expected := GSTINCheckDigit(gstin[:14]); gstin[14] != expected {
+		return fmt.Errorf("check digit is %c but should be %c; please check the GSTIN for typos", gstin[14], expected)
+	}
+	return nil
+}
+
+// GSTINCheckDigit computes the check digit of the first 14 characters of a
+// GSTIN. Characters are valued by their position in 0-9A-Z and weighted
+// alternately by 1 and 2; each product is reduced to the sum of its base-36
+// digits, and the check digit makes the total a multiple of 36.
+func GSTINCheckDigit(base string) byte {
+	sum := 0
+	for i := 0; i < len(base); i++ {
+		value := strings.IndexByte(gstinCharset, base[i])
+		product := value * (1 + i%2)
+		sum += product/36 + product%36
+	}
+	return gstinCharset[(36-sum%36)%36]
+}
+
+// ValidatePAN checks the structure of a normalised PAN: five letters of
+// which the fourth is the holder type, four digits and a letter
+func ValidatePAN(pan string) error {
+	if len(pan) != 10 {
+		return fmt.Errorf("must be 10 characters, got %d", len(pan))
+	}
+	for i := 0; i < 10; i++ {
+		c := pan[i]
+		isLetter := c >= 'A' && c <= 'Z'
+		isDigit := c >= '0' && c <= '9'
+		switch {
+		case i < 5 || i == 9:
+			if !isLetter {
+				return errors.New("must be five letters, four digits and a letter, e.g. ABCDE1234F")
+			}
+		default:
+			if !isDigit {
+				return errors.New("must be five letters, four digits and a letter, e.g. ABCDE1234F")
+			}
+		}
+	}
+	if !strings.ContainsRune(panEntityTypes, rune(pan[3])) {
+		return fmt.Errorf("the 4th character %q is not a valid holder type", pan[3])
+	}
+	return nil
+}
+
+// ValidateState checks that a free-text state names an Indian state or
+// union territory, by name or by GST state code
+func ValidateState(state string) error {
+	if gst.StateCodeByName(state) == "" {
+		return fmt.Errorf("%q is not a recognised Indian state or union territory", state)
+	}
+	return nil
+}
+
+// CheckRegistration validates the GSTIN, PAN and state of a party and
+// that they agree with each other, adding failures to errs under the given
+// field names. Empty values are not checked; callers decide what is
+// required.
+func CheckRegistration(errs *Errors, gstinField, gstin, panField, pan, stateField, state string) {
+	gstinValid := false
+	if gstin != "" {
+		if err := ValidateGSTIN(gstin); err != nil {
+			errs.Add(gstinField, err.Error())
+		} else {
+			gstinValid = true
+		}
+	}
+
+	panValid := false
+	if pan != "" {
+		if err := ValidatePAN(pan); err != nil {
+			errs.Add(panField, err.Error())
+		} else {
+			panValid = true
+		}
+	}
+
+	stateCode := ""
+	if strings.TrimSpace(state) != "" {
+		if err := ValidateState(state); err != nil {
+			errs.Add(stateField, err.Error())
+		} else {
+			stateCode = gst.StateCodeByName(state)
+		}
+	}
+
+	if !gstinValid {
+		return
+	}
+	if panValid && gstin[2:12] != pan {
+		errs.Add(panField, "does not match the PAN in the GSTIN")
+	}
+	if stateCode != "" && gstin[:2] != stateCode {
+		errs.Add(stateField, fmt.Sprintf("is %s but the GSTIN is registered in %s", gst.StateName(stateCode), gst.StateName(gstin[:2])))
+	}
+}
@@ -0,0 +1,19 @@
+package validation
+
+import (
+	"errors"
+)
+
+// ValidateHSN checks that an HSN or SAC code has 4, 6 or 8 digits
+func ValidateHSN(code string) error {
+	for i := 0; i < len(code); i++ {
+		if code[i] < '0' || code[i] > '9' {
+			return errors.New("may only contain digits")
+		}
+	}
+	switch len(code) {
+	case 4, 6, 8:
+		return nil
+	}
+	return errors.New("must have 4, 6 or 8 digits")
+}
@@ -0,0 +1,18 @@
+package validation
+
+import (
+	"errors"
+	"regexp"
+)
+
+// vpaPattern is the format of a UPI virtual payment address: a handle, @
+// and the payment service provider's name, e.g. acme.traders@okhdfcbank
+var vpaPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{1,255}@[A-Za-z][A-Za-z0-9]{1,63}$`)
+
+// ValidateVPA checks a UPI virtual payment address
+func ValidateVPA(vpa string) error {
+	if !vpaPattern.MatchString(vpa) {
+		return errors.New("is not a valid UPI ID, e.g. acme@okhdfcbank")
+	}
+	return nil
+}
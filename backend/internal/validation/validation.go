@@ -0,0 +1,55 @@
+// Package validation checks Indian tax registration details: GSTINs, PANs
+// and states, and collects failures per input field so that clients can
+// show each message next to the field it belongs to.
+package validation
+
+import (
+	"strings"
+)
+
+// FieldError is a validation failure of a single input field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error implements the error interface
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// Errors collects the field errors found in one input
+type Errors []FieldError
+
+// Error implements the error interface, listing every field error
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldErr := range e {
+		messages[i] = fieldErr.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Add records a failure of a field
+func (e *Errors) Add(field, message string) {
+	*e = append(*e, FieldError{Field: field, Message: message})
+}
+
+// Err returns the collected errors, or nil if there are none
+func (e Errors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Normalise upper-cases an identifier such as a GSTIN or PAN and removes
+// the spaces and dashes people tend to type into it
+func Normalise(id string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' || r == '\t' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(strings.TrimSpace(id)))
+}
@@ -0,0 +1,25 @@
+package validation
+
+import (
+	"errors"
+	"regexp"
+)
+
+// vehicleNumberPatterns are the registration number formats the e-way bill
+// portal accepts, for a normalised number without spaces or dashes
+var vehicleNumberPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^[A-Z]{2}[0-9]{1,3}[A-Z]{0,3}[0-9]{4}$`), // State, RTO, series and number, e.g. KA01AB1234
+	regexp.MustCompile(`^[A-Z]{3}[0-9]{4}$`),                     // Older three-letter series, e.g. ABC1234
+	regexp.MustCompile(`^[0-9]{2}BH[0-9]{4}[A-Z]{1,2}$`),         // Bharat series, e.g. 22BH1234AB
+	regexp.MustCompile(`^TR[A-Z0-9]{6,13}$`),                     // Temporary registration
+}
+
+// ValidateVehicleNumber checks a normalised vehicle registration number
+func ValidateVehicleNumber(number string) error {
+	for _, pattern := range vehicleNumberPatterns {
+		if pattern.MatchString(number) {
+			return nil
+		}
+	}
+	return errors.New("is not a valid vehicle registration number, e.g. KA01AB1234")
+}
@@ -13,6 +13,7 @@ import (
 	"golang.org/x/term"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"invoice-generator/internal/validation"
 )
 
 type User struct {
@@ -55,9 +56,20 @@ func main() {
 	companyName, _ := reader.ReadString('\n')
 	companyName = strings.TrimSpace(companyName)
 
-	fmt.Print("Enter GSTIN (optional): ")
-	gstin, _ := reader.ReadString('\n')
-	gstin = strings.TrimSpace(strings.ToUpper(gstin))
+	var gstin string
+	for {
+		fmt.Print("Enter GSTIN (optional): ")
+		gstin, _ = reader.ReadString('\n')
+		gstin = validation.Normalise(gstin)
+		if gstin == "" {
+			break
+		}
+		if err := validation.ValidateGSTIN(gstin); err != nil {
+			fmt.Printf("Invalid GSTIN: %v\n", err)
+			continue
+		}
+		break
+	}
 
 	fmt.Print("Enter phone: ")
 	phone, _ := reader.ReadString('\n')